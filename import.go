@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhaskew/rx/internal/catalog"
+)
+
+const importUsage = "usage: rx [-envfile file] import [-dry-run] [-format csv|json] file|-"
+
+// runImport is the import command, the CLI twin of POST
+// /v1/admin/films:import for files of any size. It writes the report to
+// stdout and returns the exit status: 1 when rows were invalid and nothing was
+// written, 2 when the file or the database failed.
+func runImport(db *sql.DB, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, importUsage)
+		fs.PrintDefaults()
+	}
+	dryRun := fs.Bool("dry-run", false, "validate and match the rows without writing them")
+	format := fs.String("format", "", "csv or json, by default from the file's extension")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	path := fs.Arg(0)
+	in := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		defer f.Close()
+		in = f
+	}
+	if *format == "" {
+		*format = catalog.CSV
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			*format = catalog.JSON
+		}
+	}
+
+	rows, err := catalog.Parse(in, *format)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not read %s: %v\n", path, err)
+		return 2
+	}
+	report, err := catalog.NewPostgresImporter(db).Import(context.Background(), rows, *dryRun)
+	if err != nil {
+		fmt.Fprintln(stderr, "import failed:", err)
+		return 2
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package apiv2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		d    Duration
+		want string
+	}{
+		{Days(3), "P3D"},
+		{Minutes(86), "PT1H26M"},
+		{Minutes(60), "PT1H"},
+		{Duration(90 * time.Second), "PT1M30S"},
+		{0, "PT0S"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.d.String())
+	}
+}
+
+func TestFilmOf(t *testing.T) {
+	t.Parallel()
+	got, err := json.Marshal(FilmOf(films.Film{
+		FilmID: 1, Title: "ACADEMY DINOSAUR", ReleaseYear: 2006, Rating: "PG",
+		Length: 86, RentalDuration: 6, RentalRate: 99, ReplacementCost: 2099,
+	}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": 1, "title": "ACADEMY DINOSAUR", "description": "", "releaseYear": 2006, "rating": "PG",
+		"length": "PT1H26M", "rentalDuration": "P6D",
+		"rentalRate": {"amount": "0.99", "currency": "USD"},
+		"replacementCost": {"amount": "20.99", "currency": "USD"}
+	}`, string(got))
+
+	// unknown lengths are left out
+	got, _ = json.Marshal(FilmOf(films.Film{FilmID: 2}))
+	assert.NotContains(t, string(got), "length")
+}
+
+func TestWriteProblem(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	p := problem.New(http.StatusBadRequest, "The request has invalid parameters")
+	p.InvalidParams = []problem.InvalidParam{{In: "query", Name: "limit", Reason: "must be an integer"}}
+	WriteProblem(rr, httptest.NewRequest("GET", "/v2/films", nil), p)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"errors": [{
+		"title": "Bad Request", "status": 400, "detail": "The request has invalid parameters",
+		"invalidParams": [{"in": "query", "name": "limit", "reason": "must be an integer"}]
+	}]}`, rr.Body.String())
+}
@@ -0,0 +1,80 @@
+// Package apiv2 holds the representations of API version 2. Every response
+// is a Document enveloping its data, meta, links or errors; fields are
+// camelCase and money and durations are typed.
+package apiv2
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// Document is the envelope of every v2 response. Successful responses have
+// Data, failed ones Errors.
+type Document struct {
+	Data   interface{} `json:"data,omitempty"`
+	Meta   *Meta       `json:"meta,omitempty"`
+	Links  Links       `json:"links,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Meta describes a page of a collection.
+type Meta struct {
+	// Total is the size of the whole collection.
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Links are related URLs by relation, e.g. "self" or "next".
+type Links map[string]string
+
+// Error is a problem details object with camelCase fields.
+type Error struct {
+	Type          string         `json:"type,omitempty"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []InvalidParam `json:"invalidParams,omitempty"`
+}
+
+type InvalidParam struct {
+	In     string `json:"in"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ErrorOf converts a problem.
+func ErrorOf(p problem.Problem) Error {
+	e := Error{Type: p.Type, Title: p.Title, Status: p.Status, Detail: p.Detail, Instance: p.Instance}
+	for _, ip := range p.InvalidParams {
+		e.InvalidParams = append(e.InvalidParams, InvalidParam(ip))
+	}
+	return e
+}
+
+// Encode renders doc as JSON indented with tabs, like v1.
+func Encode(doc Document) ([]byte, error) {
+	return json.MarshalIndent(doc, "", "\t")
+}
+
+// Write sends an encoded document.
+func Write(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// WriteProblem sends p as a document with a single error. It is the
+// problem.Renderer of the v2 routes.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p problem.Problem) {
+	body, err := Encode(Document{Errors: []Error{ErrorOf(p)}})
+	if err != nil {
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	Write(w, p.Status, body)
+}
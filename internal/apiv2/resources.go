@@ -0,0 +1,121 @@
+package apiv2
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/openapi"
+)
+
+// Currency is the currency of every amount, the rental stores'.
+const Currency = "USD"
+
+// Money is an amount, as a decimal string so that it survives clients
+// parsing JSON numbers as floats.
+type Money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func MoneyOf(c films.Cents) Money {
+	return Money{Amount: c.String(), Currency: Currency}
+}
+
+func (Money) Schema() *openapi.Schema {
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"amount":   {Type: "string", Pattern: `^-?\d+\.\d{2}$`, Example: "4.99"},
+			"currency": {Type: "string", Description: "ISO 4217 currency code.", Example: Currency},
+		},
+		Required: []string{"amount", "currency"},
+	}
+}
+
+// Duration is an ISO 8601 duration, e.g. "P3D" or "PT1H26M".
+type Duration time.Duration
+
+func Days(n int) Duration {
+	return Duration(time.Duration(n) * 24 * time.Hour)
+}
+
+func Minutes(n int) Duration {
+	return Duration(time.Duration(n) * time.Minute)
+}
+
+// String formats d in whole days when it is some, and in hours, minutes
+// and seconds otherwise.
+func (d Duration) String() string {
+	td := time.Duration(d)
+	day := 24 * time.Hour
+	if td != 0 && td%day == 0 {
+		return "P" + strconv.FormatInt(int64(td/day), 10) + "D"
+	}
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := td / time.Hour; h != 0 {
+		b.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+	}
+	if m := td % time.Hour / time.Minute; m != 0 {
+		b.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+	}
+	if s := td % time.Minute / time.Second; s != 0 || b.Len() == 2 {
+		b.WriteString(strconv.FormatInt(int64(s), 10) + "S")
+	}
+	return b.String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (Duration) Schema() *openapi.Schema {
+	return &openapi.Schema{Type: "string", Format: "duration", Description: "ISO 8601 duration.", Example: "P3D"}
+}
+
+type Film struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ReleaseYear int    `json:"releaseYear"`
+	Rating      string `json:"rating"`
+	// Length is missing when unknown.
+	Length          *Duration `json:"length,omitempty"`
+	RentalDuration  Duration  `json:"rentalDuration"`
+	RentalRate      Money     `json:"rentalRate"`
+	ReplacementCost Money     `json:"replacementCost"`
+}
+
+func FilmOf(f films.Film) Film {
+	film := Film{
+		ID:              f.FilmID,
+		Title:           f.Title,
+		Description:     f.Description,
+		ReleaseYear:     f.ReleaseYear,
+		Rating:          f.Rating,
+		RentalDuration:  Days(f.RentalDuration),
+		RentalRate:      MoneyOf(f.RentalRate),
+		ReplacementCost: MoneyOf(f.ReplacementCost),
+	}
+	if f.Length > 0 {
+		length := Minutes(f.Length)
+		film.Length = &length
+	}
+	return film
+}
+
+type Comment struct {
+	ID        int       `json:"id"`
+	FilmID    int       `json:"filmId"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func CommentOf(c comments.Comment) Comment {
+	return Comment{ID: c.CommentID, FilmID: c.FilmID, Author: c.Author, Body: c.Body, CreatedAt: c.CreatedAt}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader carries static API keys.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by a static key in the X-API-Key header.
+type APIKeys struct {
+	// keyed by the SHA-256 of the key so lookups do not compare secrets
+	// byte by byte
+	keys map[[sha256.Size]byte]Principal
+}
+
+// ParseAPIKeys reads keys in the AUTH_API_KEYS format, one entry per key:
+//
+//	<name>|<key>|<scope> <scope> ...
+func ParseAPIKeys(entries []string) (*APIKeys, error) {
+	a := &APIKeys{keys: make(map[[sha256.Size]byte]Principal)}
+	for i, entry := range entries {
+		parts := strings.Split(entry, "|")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("api key %d: want <name>|<key>|<scopes>", i+1)
+		}
+		a.Add(parts[1], Principal{
+			ID:     parts[0],
+			Method: "api_key",
+			Scopes: ParseScopes(parts[2]),
+		})
+	}
+	return a, nil
+}
+
+// Add registers key for p.
+func (a *APIKeys) Add(key string, p Principal) {
+	a.keys[sha256.Sum256([]byte(key))] = p
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	p, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	return &p, nil
+}
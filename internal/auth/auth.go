@@ -0,0 +1,150 @@
+// Package auth identifies API clients and checks what they may do.
+//
+// Authenticate runs the configured Authenticators and stores the resulting
+// Principal in the request context without rejecting anything, so the request
+// logger can report who called. Require then enforces per-route scopes.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// Scope is a permission granted to a principal.
+type Scope string
+
+const (
+	FilmsRead     Scope = "films:read"
+	FilmsWrite    Scope = "films:write"
+	CommentsWrite Scope = "comments:write"
+	ReportsRead   Scope = "reports:read"
+)
+
+// AllScopes lists every scope the API knows about.
+var AllScopes = []Scope{FilmsRead, FilmsWrite, CommentsWrite, ReportsRead}
+
+// ParseScopes splits a space separated scope list, as used by OAuth scope
+// claims and the API key config.
+func ParseScopes(s string) []Scope {
+	var scopes []Scope
+	for _, f := range strings.Fields(s) {
+		scopes = append(scopes, Scope(f))
+	}
+	return scopes
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// ID is the API key name or the JWT subject.
+	ID string
+	// Method is how the principal authenticated, e.g. "api_key" or "jwt".
+	Method string
+	Scopes []Scope
+}
+
+// HasScope reports whether p was granted scope.
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request
+	// carries no credentials it understands, so the next one is tried.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for credentials that were understood
+	// but rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator identifies the caller of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// Anonymous authenticates every request as an anonymous principal with the
+// given scopes. It is used when authentication is disabled.
+func Anonymous(scopes ...Scope) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{ID: "anonymous", Method: "none", Scopes: scopes}, nil
+	})
+}
+
+type principalKey struct{}
+type errorKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored by Authenticate, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Authenticate tries each authenticator in order and stores the first
+// principal found in the request context. Failures are stored too and
+// reported by Require, so routes without requirements stay open.
+func Authenticate(authenticators ...Authenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			for _, a := range authenticators {
+				p, err := a.Authenticate(r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					ctx = context.WithValue(ctx, errorKey{}, err)
+				} else {
+					ctx = WithPrincipal(ctx, p)
+				}
+				break
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Require rejects requests without a principal holding every scope with 401
+// or 403 problem responses.
+func Require(scopes ...Scope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			if !ok {
+				detail := "authentication required"
+				if err, _ := r.Context().Value(errorKey{}).(error); err != nil {
+					detail = err.Error()
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="rx"`)
+				problem.Error(w, r, http.StatusUnauthorized, detail)
+				return
+			}
+			for _, scope := range scopes {
+				if !p.HasScope(scope) {
+					problem.Error(w, r, http.StatusForbidden, "missing scope "+string(scope))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := FromContext(r.Context())
+		_, _ = w.Write([]byte(p.ID))
+	})
+}
+
+func TestAuthenticateAndRequire(t *testing.T) {
+	t.Parallel()
+
+	keys, err := ParseAPIKeys([]string{
+		"kiosk|kiosk-key|films:read",
+		"ops|ops-key|films:read reports:read",
+	})
+	assert.NoError(t, err)
+
+	handler := Authenticate(keys)(Require(FilmsRead, ReportsRead)(okHandler()))
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+		body   string
+	}{
+		{name: "no key", status: http.StatusUnauthorized},
+		{name: "unknown key", key: "nope", status: http.StatusUnauthorized},
+		{name: "missing scope", key: "kiosk-key", status: http.StatusForbidden},
+		{name: "all scopes", key: "ops-key", status: http.StatusOK, body: "ops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/reports", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			} else {
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAuthenticateLeavesOpenRoutesOpen(t *testing.T) {
+	t.Parallel()
+
+	keys, _ := ParseAPIKeys(nil)
+	handler := Authenticate(keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := FromContext(r.Context())
+		assert.False(t, ok)
+	}))
+	req := httptest.NewRequest("GET", "/ping", nil)
+	req.Header.Set(APIKeyHeader, "nope")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseAPIKeys([]string{"missing-scopes|key"})
+	assert.Error(t, err)
+
+	_, err = ParseAPIKeys([]string{"|key|films:read"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Supported JWT signing algorithms. Anything else, including "none", is
+// rejected.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// Key is a JWT verification key.
+type Key struct {
+	ID     string
+	Alg    string
+	Secret []byte         // HS256
+	Public *rsa.PublicKey // RS256
+}
+
+// KeySet holds the keys tokens may be signed with.
+type KeySet struct {
+	Keys []Key
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS reads a JSON Web Key Set file. RSA keys are used for RS256 and
+// symmetric ("oct") keys for HS256; keys for other uses are skipped.
+func LoadJWKS(path string) (*KeySet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(raw)
+}
+
+// ParseJWKS parses a JSON Web Key Set document.
+func ParseJWKS(raw []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parsing jwks: %w", err)
+	}
+	ks := &KeySet{}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("jwks key %d: modulus: %w", i, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("jwks key %d: exponent: %w", i, err)
+			}
+			ks.Keys = append(ks.Keys, Key{
+				ID:  k.Kid,
+				Alg: RS256,
+				Public: &rsa.PublicKey{
+					N: new(big.Int).SetBytes(n),
+					E: int(new(big.Int).SetBytes(e).Int64()),
+				},
+			})
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("jwks key %d: secret: %w", i, err)
+			}
+			ks.Keys = append(ks.Keys, Key{ID: k.Kid, Alg: HS256, Secret: secret})
+		}
+	}
+	return ks, nil
+}
+
+// JWT authenticates "Authorization: Bearer" tokens signed with one of its keys.
+type JWT struct {
+	Keys     *KeySet
+	Issuer   string // checked when set
+	Audience string // checked when set
+	Leeway   time.Duration
+	Now      func() time.Time
+}
+
+func NewJWT(keys *KeySet, issuer, audience string) *JWT {
+	return &JWT{
+		Keys:     keys,
+		Issuer:   issuer,
+		Audience: audience,
+		Leeway:   30 * time.Second,
+		Now:      time.Now,
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       []string        `json:"scp"`
+}
+
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return nil, ErrNoCredentials
+	}
+	p, err := j.Verify(strings.TrimSpace(header[7:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return p, nil
+}
+
+// Verify checks the token signature and claims and returns its principal.
+func (j *JWT) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("token signature: %w", err)
+	}
+	if !j.verifySignature(header, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, errors.New("bad signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("token claims: %w", err)
+	}
+	if err := j.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	scopes := ParseScopes(claims.Scope)
+	for _, s := range claims.Scp {
+		scopes = append(scopes, Scope(s))
+	}
+	return &Principal{ID: claims.Subject, Method: "jwt", Scopes: scopes}, nil
+}
+
+// verifySignature only accepts a key whose algorithm matches the header, so
+// an RSA public key can never be used as an HMAC secret.
+func (j *JWT) verifySignature(header jwtHeader, signed, sig []byte) bool {
+	if j.Keys == nil {
+		return false
+	}
+	digest := sha256.Sum256(signed)
+	for _, k := range j.Keys.Keys {
+		if k.Alg != header.Alg || (header.Kid != "" && k.ID != "" && k.ID != header.Kid) {
+			continue
+		}
+		switch k.Alg {
+		case HS256:
+			mac := hmac.New(sha256.New, k.Secret)
+			mac.Write(signed)
+			if hmac.Equal(mac.Sum(nil), sig) {
+				return true
+			}
+		case RS256:
+			if rsa.VerifyPKCS1v15(k.Public, crypto.SHA256, digest[:], sig) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (j *JWT) checkClaims(c jwtClaims) error {
+	now := j.Now()
+	if c.ExpiresAt == nil {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*c.ExpiresAt, 0).Add(j.Leeway)) {
+		return errors.New("token expired")
+	}
+	if c.NotBefore != nil && now.Add(j.Leeway).Before(time.Unix(*c.NotBefore, 0)) {
+		return errors.New("token not yet valid")
+	}
+	if j.Issuer != "" && c.Issuer != j.Issuer {
+		return errors.New("unexpected issuer")
+	}
+	if j.Audience != "" && !audienceContains(c.Audience, j.Audience) {
+		return errors.New("unexpected audience")
+	}
+	if c.Subject == "" {
+		return errors.New("token has no subject")
+	}
+	return nil
+}
+
+// audienceContains handles aud being either a string or a list of strings.
+func audienceContains(raw json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == want
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, aud := range many {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
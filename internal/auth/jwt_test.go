@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2023, 8, 24, 12, 0, 0, 0, time.UTC)
+
+func segment(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signHS256(t *testing.T, secret []byte, header, claims map[string]interface{}) string {
+	t.Helper()
+	signed := segment(t, header) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	t.Helper()
+	signed := segment(t, header) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func claims(overrides map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"sub":   "user-1",
+		"iss":   "https://issuer.example",
+		"aud":   []string{"rx"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": "films:read comments:write",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func TestJWTHS256(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("s3cr3t")
+	j := NewJWT(&KeySet{Keys: []Key{{Alg: HS256, Secret: secret}}}, "https://issuer.example", "rx")
+	j.Now = func() time.Time { return now }
+
+	hs := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: signHS256(t, secret, hs, claims(nil))},
+		{name: "wrong secret", token: signHS256(t, []byte("other"), hs, claims(nil)), wantErr: true},
+		{name: "expired", token: signHS256(t, secret, hs, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), wantErr: true},
+		{name: "no expiry", token: signHS256(t, secret, hs, claims(map[string]interface{}{"exp": nil})), wantErr: true},
+		{name: "not yet valid", token: signHS256(t, secret, hs, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), wantErr: true},
+		{name: "wrong issuer", token: signHS256(t, secret, hs, claims(map[string]interface{}{"iss": "evil"})), wantErr: true},
+		{name: "wrong audience", token: signHS256(t, secret, hs, claims(map[string]interface{}{"aud": "other"})), wantErr: true},
+		{name: "alg none", token: segment(t, map[string]interface{}{"alg": "none"}) + "." + segment(t, claims(nil)) + ".", wantErr: true},
+		{name: "malformed", token: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := j.Verify(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "user-1", p.ID)
+			assert.True(t, p.HasScope(FilmsRead))
+			assert.True(t, p.HasScope(CommentsWrite))
+			assert.False(t, p.HasScope(ReportsRead))
+		})
+	}
+}
+
+func TestJWTRS256FromJWKS(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","use":"sig","n":%q,"e":%q}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	ks, err := ParseJWKS([]byte(jwks))
+	assert.NoError(t, err)
+	assert.Len(t, ks.Keys, 1)
+
+	j := NewJWT(ks, "", "")
+	j.Now = func() time.Time { return now }
+
+	token := signRS256(t, key, map[string]interface{}{"alg": "RS256", "kid": "k1"}, claims(map[string]interface{}{"scope": nil, "scp": []string{"reports:read"}}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	p, err := j.Authenticate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt", p.Method)
+	assert.True(t, p.HasScope(ReportsRead))
+
+	// the RSA public key must not be accepted as an HMAC secret
+	forged := signHS256(t, key.N.Bytes(), map[string]interface{}{"alg": "HS256", "kid": "k1"}, claims(nil))
+	_, err = j.Verify(forged)
+	assert.Error(t, err)
+
+	// unknown kid
+	token = signRS256(t, key, map[string]interface{}{"alg": "RS256", "kid": "k2"}, claims(nil))
+	_, err = j.Verify(token)
+	assert.Error(t, err)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	_, err = j.Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
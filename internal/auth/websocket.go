@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Browsers cannot set headers on a WebSocket handshake other than
+// Sec-WebSocket-Protocol, so they offer their credentials as subprotocols
+// with these prefixes, along with the protocol of the socket itself:
+//
+//	new WebSocket(url, ["rx.comments", "bearer." + token])
+const (
+	WebSocketBearerPrefix = "bearer."
+	WebSocketAPIKeyPrefix = "apikey."
+)
+
+// WebSocketCredentials moves credentials offered as WebSocket subprotocols
+// to the Authorization or X-API-Key header, where the authenticators look,
+// and out of Sec-WebSocket-Protocol so they are never chosen as the
+// protocol. It runs before Authenticate and leaves requests that carry
+// credentials in headers alone.
+func WebSocketCredentials(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offered := r.Header.Values("Sec-WebSocket-Protocol")
+		if len(offered) == 0 || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var protocols []string
+		var bearer, key string
+		for _, value := range offered {
+			for _, protocol := range strings.Split(value, ",") {
+				protocol = strings.TrimSpace(protocol)
+				switch {
+				case strings.HasPrefix(protocol, WebSocketBearerPrefix):
+					bearer = strings.TrimPrefix(protocol, WebSocketBearerPrefix)
+				case strings.HasPrefix(protocol, WebSocketAPIKeyPrefix):
+					key = strings.TrimPrefix(protocol, WebSocketAPIKeyPrefix)
+				case protocol != "":
+					protocols = append(protocols, protocol)
+				}
+			}
+		}
+		if bearer == "" && key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Header.Del("Sec-WebSocket-Protocol")
+		if len(protocols) > 0 {
+			r.Header.Set("Sec-WebSocket-Protocol", strings.Join(protocols, ", "))
+		}
+		switch {
+		case r.Header.Get("Authorization") != "" || r.Header.Get(APIKeyHeader) != "":
+		case bearer != "":
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		default:
+			r.Header.Set(APIKeyHeader, key)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketCredentials(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		upgrade   string
+		protocols string
+		apiKey    string
+		want      http.Header
+	}{
+		{name: "bearer", upgrade: "websocket", protocols: "rx.comments, bearer.abc.def",
+			want: http.Header{"Authorization": {"Bearer abc.def"}, "Sec-Websocket-Protocol": {"rx.comments"}}},
+		{name: "api key", upgrade: "WebSocket", protocols: "apikey.secret",
+			want: http.Header{"X-Api-Key": {"secret"}}},
+		{name: "headers win", upgrade: "websocket", protocols: "rx.comments, bearer.abc", apiKey: "secret",
+			want: http.Header{"X-Api-Key": {"secret"}, "Sec-Websocket-Protocol": {"rx.comments"}}},
+		{name: "no credentials", upgrade: "websocket", protocols: "rx.comments",
+			want: http.Header{"Sec-Websocket-Protocol": {"rx.comments"}}},
+		{name: "not an upgrade", protocols: "bearer.abc",
+			want: http.Header{"Sec-Websocket-Protocol": {"bearer.abc"}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got http.Header
+			handler := WebSocketCredentials(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = http.Header{}
+				for _, name := range []string{"Authorization", APIKeyHeader, "Sec-WebSocket-Protocol"} {
+					if v := r.Header.Values(name); len(v) > 0 {
+						got[http.CanonicalHeaderKey(name)] = v
+					}
+				}
+			}))
+			req := httptest.NewRequest("GET", "/v1/films/1/comments/ws", nil)
+			if tt.upgrade != "" {
+				req.Header.Set("Upgrade", tt.upgrade)
+			}
+			req.Header.Set("Sec-WebSocket-Protocol", tt.protocols)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
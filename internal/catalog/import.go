@@ -0,0 +1,81 @@
+package catalog
+
+import (
+	"context"
+	"sort"
+)
+
+// The actions taken on valid rows.
+const (
+	Inserted = "inserted"
+	Updated  = "updated"
+)
+
+// RowError is why a row cannot be imported. Row is its Line; Field is empty
+// when the row as a whole is wrong.
+type RowError struct {
+	Row    int    `json:"row"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Result is what was done with a row. Films inserted by a dry run have no
+// ID.
+type Result struct {
+	Row    int    `json:"row"`
+	FilmID int    `json:"film_id,omitempty"`
+	Action string `json:"action"`
+}
+
+// Report is the outcome of an import: the results of every row when all of
+// them were valid, or the errors of those that were not, in which case
+// nothing was written.
+type Report struct {
+	DryRun   bool       `json:"dry_run"`
+	Rows     int        `json:"rows"`
+	Inserted int        `json:"inserted"`
+	Updated  int        `json:"updated"`
+	Results  []Result   `json:"results"`
+	Errors   []RowError `json:"errors"`
+}
+
+// FilmIDs are the films the import wrote, none for a dry run.
+func (r Report) FilmIDs() []int {
+	if r.DryRun {
+		return nil
+	}
+	ids := make([]int, len(r.Results))
+	for i, result := range r.Results {
+		ids[i] = result.FilmID
+	}
+	return ids
+}
+
+// Importer writes rows to the catalog in one transaction, rolled back when
+// any row is invalid or for a dry run. Invalid rows are reported, not
+// returned as errors.
+type Importer interface {
+	Import(ctx context.Context, rows []Row, dryRun bool) (Report, error)
+}
+
+// newReport is the report of rows that failed with errs, or of the films
+// written when there are none.
+func newReport(rows []Row, dryRun bool, valid []film, errs []RowError) Report {
+	report := Report{DryRun: dryRun, Rows: len(rows), Results: []Result{}, Errors: []RowError{}}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+		report.Errors = errs
+		return report
+	}
+	for _, f := range valid {
+		result := Result{Row: f.line, FilmID: f.id, Action: Inserted}
+		if f.matched {
+			result.Action = Updated
+			report.Updated++
+		} else {
+			report.Inserted++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
@@ -0,0 +1,221 @@
+// Package catalog imports films in bulk from CSV or JSON files, as kept in
+// spreadsheets: every row is checked against the film schema and the
+// reference data, and the rows are written together or not at all.
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The formats rows are read from.
+const (
+	CSV  = "csv"
+	JSON = "json"
+)
+
+// Columns are the CSV columns of a Row, named like its JSON fields.
+var Columns = []string{
+	"film_id", "title", "description", "release_year", "language", "category",
+	"rating", "length", "rental_duration", "rental_rate", "replacement_cost",
+}
+
+// Row is a film to import. It is matched with a film by FilmID when set, and
+// by Title and ReleaseYear otherwise, and replaces the matched film or adds
+// a new one. Language and Category are names; zero fields are left empty or
+// take the defaults of the film table. A row replacing a film must have all
+// of its columns, see partial.
+type Row struct {
+	FilmID      int    `json:"film_id,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ReleaseYear int    `json:"release_year,omitempty"`
+	Language    string `json:"language"`
+	Category    string `json:"category,omitempty"`
+	Rating      string `json:"rating,omitempty"`
+	// Length is in minutes and RentalDuration in days.
+	Length          int    `json:"length,omitempty"`
+	RentalDuration  int    `json:"rental_duration,omitempty"`
+	RentalRate      Amount `json:"rental_rate,omitempty"`
+	ReplacementCost Amount `json:"replacement_cost,omitempty"`
+
+	// Line is where the row was read, to report its errors by: its line in
+	// a CSV file, counting the header, or its position in a JSON array.
+	Line int `json:"-"`
+	// errs are the cells that could not be read
+	errs []RowError
+	// columns are those the row was read with: the header of a CSV file or
+	// the fields of a JSON object. nil is every column.
+	columns map[string]bool
+}
+
+// Amount is a decimal amount of money, e.g. 4.99, which JSON files may have
+// as a number or a string. It is checked by Import.
+type Amount string
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Amount(s)
+	} else if string(b) != "null" {
+		*a = Amount(b)
+	}
+	return nil
+}
+
+// Parse reads the rows of a file in format. A file that cannot be read is
+// an error; rows with cells that cannot be are returned with their errors,
+// reported by Import.
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case CSV:
+		return parseCSV(r)
+	case JSON:
+		return parseJSON(r)
+	}
+	return nil, fmt.Errorf("catalog: unknown format %q", format)
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	} else if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i, name := range header {
+		// spreadsheets tend to save UTF-8 with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !contains(Columns, name) {
+			return nil, fmt.Errorf("unknown column %q, columns are %s", name, strings.Join(Columns, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q is repeated", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+
+	rows := []Row{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line, columns: seen}
+		if len(record) != len(header) {
+			row.errs = append(row.errs, RowError{Row: line, Reason: fmt.Sprintf("has %d cells, the header %d", len(record), len(header))})
+			rows = append(rows, row)
+			continue
+		}
+		for i, cell := range record {
+			row.set(header[i], strings.TrimSpace(cell))
+		}
+		rows = append(rows, row)
+	}
+}
+
+// set reads a CSV cell into the field of column.
+func (row *Row) set(column, cell string) {
+	integer := func(dst *int) {
+		if cell == "" {
+			return
+		}
+		n, err := strconv.Atoi(cell)
+		if err != nil {
+			row.errs = append(row.errs, RowError{Row: row.Line, Field: column, Reason: "must be an integer"})
+			return
+		}
+		*dst = n
+	}
+	switch column {
+	case "film_id":
+		integer(&row.FilmID)
+	case "title":
+		row.Title = cell
+	case "description":
+		row.Description = cell
+	case "release_year":
+		integer(&row.ReleaseYear)
+	case "language":
+		row.Language = cell
+	case "category":
+		row.Category = cell
+	case "rating":
+		row.Rating = cell
+	case "length":
+		integer(&row.Length)
+	case "rental_duration":
+		integer(&row.RentalDuration)
+	case "rental_rate":
+		row.RentalRate = Amount(cell)
+	case "replacement_cost":
+		row.ReplacementCost = Amount(cell)
+	}
+}
+
+func parseJSON(r io.Reader) ([]Row, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, errors.New("the file must be a JSON array of films")
+	}
+	rows := make([]Row, len(raw))
+	for i, msg := range raw {
+		row := Row{}
+		dec := json.NewDecoder(bytes.NewReader(msg))
+		dec.DisallowUnknownFields()
+		// fields of the wrong type are skipped, the others decoded
+		if err := dec.Decode(&row); err != nil {
+			row.errs = []RowError{jsonError(i+1, err)}
+		}
+		row.Line = i + 1
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(msg, &fields) == nil {
+			row.columns = map[string]bool{}
+			for name := range fields {
+				// the decoder matches field names in any case
+				row.columns[strings.ToLower(name)] = true
+			}
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// jsonError reports why the element at position could not be decoded.
+func jsonError(position int, err error) RowError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		switch typeErr.Type.Kind() {
+		case reflect.Int:
+			return RowError{Row: position, Field: typeErr.Field, Reason: "must be an integer"}
+		case reflect.String:
+			return RowError{Row: position, Field: typeErr.Field, Reason: "must be a string"}
+		}
+		return RowError{Row: position, Reason: "must be an object"}
+	}
+	return RowError{Row: position, Reason: strings.TrimPrefix(err.Error(), "json: ")}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
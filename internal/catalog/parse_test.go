@@ -0,0 +1,79 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// columns is a set of column names.
+func columns(names ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	header := columns("title", "language", "rental_rate", "length")
+
+	tests := []struct {
+		name   string
+		format string
+		file   string
+		want   []Row
+		err    string
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			file: "\ufeffTitle,language,rental_rate,length\n" +
+				"ACADEMY DINOSAUR, English,0.99,86\n" +
+				"\"ACE, GOLDFINGER\",English,,\n",
+			want: []Row{
+				{Title: "ACADEMY DINOSAUR", Language: "English", RentalRate: "0.99", Length: 86, Line: 2, columns: header},
+				{Title: "ACE, GOLDFINGER", Language: "English", Line: 3, columns: header},
+			},
+		},
+		{
+			name:   "csv cells",
+			format: CSV,
+			file:   "title,length\nA,long\nB\n",
+			want: []Row{
+				{Title: "A", Line: 2, errs: []RowError{{Row: 2, Field: "length", Reason: "must be an integer"}}, columns: columns("title", "length")},
+				{Line: 3, errs: []RowError{{Row: 3, Reason: "has 1 cells, the header 2"}}, columns: columns("title", "length")},
+			},
+		},
+		{name: "unknown column", format: CSV, file: "title,budget\n", err: `unknown column "budget"`},
+		{name: "repeated column", format: CSV, file: "title,Title\n", err: `column "title" is repeated`},
+		{name: "empty", format: CSV, file: "", err: "the file is empty"},
+		{
+			name:   "json",
+			format: JSON,
+			file:   `[{"Title": "ACADEMY DINOSAUR", "language": "English", "rental_rate": 0.99, "replacement_cost": "20.99"}, {"title": 1, "length": 5}, {"budget": 1}]`,
+			want: []Row{
+				{Title: "ACADEMY DINOSAUR", Language: "English", RentalRate: "0.99", ReplacementCost: "20.99", Line: 1, columns: columns("title", "language", "rental_rate", "replacement_cost")},
+				{Length: 5, Line: 2, errs: []RowError{{Row: 2, Field: "title", Reason: "must be a string"}}, columns: columns("title", "length")},
+				{Line: 3, errs: []RowError{{Row: 3, Reason: `unknown field "budget"`}}, columns: columns("budget")},
+			},
+		},
+		{name: "not an array", format: JSON, file: `{"title": "x"}`, err: "the file must be a JSON array of films"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rows, err := Parse(strings.NewReader(tt.file), tt.format)
+			if tt.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, rows)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// SQL_LOCK keeps concurrent imports from inserting the same films,
+	// letting films be read meanwhile.
+	SQL_LOCK       = `LOCK TABLE film IN SHARE ROW EXCLUSIVE MODE`
+	SQL_LANGUAGES  = `SELECT language_id, name FROM language`
+	SQL_CATEGORIES = `SELECT category_id, name FROM category`
+	// SQL_MATCH finds the film of each row, by ID or by title and year.
+	SQL_MATCH = `SELECT DISTINCT ON (t.ord) t.ord, f.film_id
+FROM unnest($1::int[], $2::text[], $3::int[]) WITH ORDINALITY AS t(film_id, title, release_year, ord)
+JOIN film f ON CASE WHEN t.film_id > 0 THEN f.film_id = t.film_id
+	ELSE upper(f.title) = upper(t.title) AND f.release_year IS NOT DISTINCT FROM NULLIF(t.release_year, 0) END
+ORDER BY t.ord, f.film_id`
+	// SQL_NEXT_IDS numbers new films up front, so that they are upserted
+	// with the others.
+	SQL_NEXT_IDS = `SELECT nextval(pg_get_serial_sequence('film', 'film_id')) FROM generate_series(1, $1)`
+	SQL_UPSERT   = `INSERT INTO film (film_id, title, description, release_year, language_id, rental_duration, rental_rate, length, replacement_cost, rating)
+SELECT t.film_id, t.title, NULLIF(t.description, ''), NULLIF(t.release_year, 0), t.language_id, t.rental_duration, t.rental_rate, NULLIF(t.length, 0), t.replacement_cost, t.rating::mpaa_rating
+FROM unnest($1::int[], $2::text[], $3::text[], $4::int[], $5::int[], $6::int[], $7::numeric[], $8::int[], $9::numeric[], $10::text[])
+	AS t(film_id, title, description, release_year, language_id, rental_duration, rental_rate, length, replacement_cost, rating)
+ON CONFLICT (film_id) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description, release_year = EXCLUDED.release_year,
+	language_id = EXCLUDED.language_id, rental_duration = EXCLUDED.rental_duration, rental_rate = EXCLUDED.rental_rate,
+	length = EXCLUDED.length, replacement_cost = EXCLUDED.replacement_cost, rating = EXCLUDED.rating`
+	SQL_CLEAR_CATEGORIES = `DELETE FROM film_category WHERE film_id = ANY($1)`
+	SQL_ADD_CATEGORIES   = `INSERT INTO film_category (film_id, category_id) SELECT * FROM unnest($1::int[], $2::int[])`
+)
+
+// importBatch is how many rows are written by each statement.
+const importBatch = 500
+
+type postgresImporter struct {
+	db *sql.DB
+}
+
+func NewPostgresImporter(db *sql.DB) Importer {
+	return &postgresImporter{db: db}
+}
+
+// Import matches every valid row with its film before writing any, so that
+// rows naming missing films are reported like the invalid ones, and a dry
+// run writes none. Rows with a category replace the film's categories with
+// it.
+func (im *postgresImporter) Import(ctx context.Context, rows []Row, dryRun bool) (Report, error) {
+	tx, err := im.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Report{}, err
+	}
+	// a no-op once committed
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, SQL_LOCK); err != nil {
+		return Report{}, err
+	}
+	ref, err := reference(ctx, tx)
+	if err != nil {
+		return Report{}, err
+	}
+	valid, errs := validate(rows, ref)
+	for start := 0; start < len(valid); start += importBatch {
+		missing, err := match(ctx, tx, valid[start:min(start+importBatch, len(valid))])
+		if err != nil {
+			return Report{}, err
+		}
+		errs = append(errs, missing...)
+	}
+	errs = append(errs, repeated(valid)...)
+	errs = append(errs, partial(valid)...)
+	if len(errs) > 0 {
+		return newReport(rows, dryRun, nil, errs), nil
+	}
+	// a dry run stops here, not to number films it does not add
+	if dryRun {
+		return newReport(rows, dryRun, valid, nil), nil
+	}
+
+	for start := 0; start < len(valid); start += importBatch {
+		if err := upsert(ctx, tx, valid[start:min(start+importBatch, len(valid))]); err != nil {
+			return Report{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Report{}, err
+	}
+	return newReport(rows, dryRun, valid, nil), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// reference loads the languages and categories rows refer to.
+func reference(ctx context.Context, tx *sql.Tx) (Reference, error) {
+	ref := Reference{}
+	var err error
+	if ref.Languages, err = names(ctx, tx, SQL_LANGUAGES); err != nil {
+		return Reference{}, err
+	}
+	if ref.Categories, err = names(ctx, tx, SQL_CATEGORIES); err != nil {
+		return Reference{}, err
+	}
+	return ref, nil
+}
+
+// names maps the upper case names selected by query to their IDs.
+func names(ctx context.Context, tx *sql.Tx, query string) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]int{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		// language names are blank padded
+		ids[strings.ToUpper(strings.TrimSpace(name))] = id
+	}
+	return ids, rows.Err()
+}
+
+// match sets the ID of the films matching batch, reporting the rows that
+// name a film ID no film has.
+func match(ctx context.Context, tx *sql.Tx, batch []film) ([]RowError, error) {
+	ids, titles, years := make([]int, len(batch)), make([]string, len(batch)), make([]int, len(batch))
+	for i, f := range batch {
+		ids[i], titles[i], years[i] = f.id, f.title, f.releaseYear
+	}
+	rows, err := tx.QueryContext(ctx, SQL_MATCH, pq.Array(ids), pq.Array(titles), pq.Array(years))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ord, id int
+		if err := rows.Scan(&ord, &id); err != nil {
+			return nil, err
+		}
+		batch[ord-1].id, batch[ord-1].matched = id, true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var errs []RowError
+	for _, f := range batch {
+		if f.id > 0 && !f.matched {
+			errs = append(errs, RowError{Row: f.line, Field: "film_id", Reason: "is not the ID of a film"})
+		}
+	}
+	return errs, nil
+}
+
+// upsert writes batch, numbering the new films first.
+func upsert(ctx context.Context, tx *sql.Tx, batch []film) error {
+	var inserted []int
+	for i, f := range batch {
+		if !f.matched {
+			inserted = append(inserted, i)
+		}
+	}
+	if len(inserted) > 0 {
+		rows, err := tx.QueryContext(ctx, SQL_NEXT_IDS, len(inserted))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for _, i := range inserted {
+			if !rows.Next() {
+				return sql.ErrNoRows
+			}
+			if err := rows.Scan(&batch[i].id); err != nil {
+				return err
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+	}
+
+	n := len(batch)
+	ids, titles, descriptions, years := make([]int, n), make([]string, n), make([]string, n), make([]int, n)
+	languages, durations, rates, lengths := make([]int, n), make([]int, n), make([]string, n), make([]int, n)
+	costs, ratings := make([]string, n), make([]string, n)
+	var categorized, categories []int
+	for i, f := range batch {
+		ids[i], titles[i], descriptions[i], years[i] = f.id, f.title, f.description, f.releaseYear
+		languages[i], durations[i], rates[i], lengths[i] = f.languageID, f.rentalDuration, f.rentalRate.String(), f.length
+		costs[i], ratings[i] = f.replacementCost.String(), f.rating
+		if f.categoryID > 0 {
+			categorized, categories = append(categorized, f.id), append(categories, f.categoryID)
+		}
+	}
+	_, err := tx.ExecContext(ctx, SQL_UPSERT, pq.Array(ids), pq.Array(titles), pq.Array(descriptions), pq.Array(years),
+		pq.Array(languages), pq.Array(durations), pq.Array(rates), pq.Array(lengths), pq.Array(costs), pq.Array(ratings))
+	if err != nil {
+		return err
+	}
+
+	if len(categorized) == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, SQL_CLEAR_CATEGORIES, pq.Array(categorized)); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, SQL_ADD_CATEGORIES, pq.Array(categorized), pq.Array(categories))
+	return err
+}
@@ -0,0 +1,147 @@
+package catalog
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectReference expects the import to start, and load the languages and
+// categories.
+func expectReference(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(SQL_LOCK)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_LANGUAGES)).
+		WillReturnRows(sqlmock.NewRows([]string{"language_id", "name"}).AddRow(1, "English             "))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_CATEGORIES)).
+		WillReturnRows(sqlmock.NewRows([]string{"category_id", "name"}).AddRow(14, "Sci-Fi"))
+}
+
+var importRows = []Row{
+	{Title: "NEW RELEASE", ReleaseYear: 2024, Language: "English", Category: "Sci-Fi", Line: 2},
+	{FilmID: 7, Title: "AIRPLANE SIERRA", Language: "English", Rating: "PG-13", RentalRate: "0.99", Line: 3},
+	{Title: "ACADEMY DINOSAUR", ReleaseYear: 2006, Language: "English", Line: 4},
+}
+
+func TestPostgresImport(t *testing.T) {
+	t.Parallel()
+	for _, dryRun := range []bool{false, true} {
+		dryRun := dryRun
+		t.Run(map[bool]string{false: "import", true: "dry run"}[dryRun], func(t *testing.T) {
+			t.Parallel()
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			expectReference(mock)
+			mock.ExpectQuery(regexp.QuoteMeta(SQL_MATCH)).
+				WithArgs(pq.Array([]int{0, 7, 0}), pq.Array([]string{"NEW RELEASE", "AIRPLANE SIERRA", "ACADEMY DINOSAUR"}), pq.Array([]int{2024, 0, 2006})).
+				WillReturnRows(sqlmock.NewRows([]string{"ord", "film_id"}).AddRow(2, 7).AddRow(3, 1))
+			if dryRun {
+				// nothing is written, nor are the new films numbered
+				mock.ExpectRollback()
+			} else {
+				expectUpsert(mock)
+				mock.ExpectCommit()
+			}
+
+			report, err := NewPostgresImporter(db).Import(context.Background(), importRows, dryRun)
+			assert.NoError(t, err)
+			inserted := 1001
+			if dryRun {
+				inserted = 0
+			}
+			assert.Equal(t, Report{
+				DryRun:   dryRun,
+				Rows:     3,
+				Inserted: 1,
+				Updated:  2,
+				Results: []Result{
+					{Row: 2, FilmID: inserted, Action: Inserted},
+					{Row: 3, FilmID: 7, Action: Updated},
+					{Row: 4, FilmID: 1, Action: Updated},
+				},
+				Errors: []RowError{},
+			}, report)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// expectUpsert expects importRows to be written, numbering the new film 1001.
+func expectUpsert(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_NEXT_IDS)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(1001))
+	mock.ExpectExec(regexp.QuoteMeta(SQL_UPSERT)).
+		WithArgs(
+			pq.Array([]int{1001, 7, 1}),
+			pq.Array([]string{"NEW RELEASE", "AIRPLANE SIERRA", "ACADEMY DINOSAUR"}),
+			pq.Array([]string{"", "", ""}),
+			pq.Array([]int{2024, 0, 2006}),
+			pq.Array([]int{1, 1, 1}),
+			pq.Array([]int{3, 3, 3}),
+			pq.Array([]string{"4.99", "0.99", "4.99"}),
+			pq.Array([]int{0, 0, 0}),
+			pq.Array([]string{"19.99", "19.99", "19.99"}),
+			pq.Array([]string{"G", "PG-13", "G"}),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(SQL_CLEAR_CATEGORIES)).WithArgs(pq.Array([]int{1001})).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(SQL_ADD_CATEGORIES)).WithArgs(pq.Array([]int{1001}), pq.Array([]int{14})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestPostgresImportErrors(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows := append([]Row{{Title: "BAD", Language: "Klingon", Line: 1}}, importRows...)
+	expectReference(mock)
+	// film 7 is gone
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_MATCH)).
+		WillReturnRows(sqlmock.NewRows([]string{"ord", "film_id"}).AddRow(3, 1))
+	mock.ExpectRollback()
+
+	report, err := NewPostgresImporter(db).Import(context.Background(), rows, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Report{
+		Rows:    4,
+		Results: []Result{},
+		Errors: []RowError{
+			{Row: 1, Field: "language", Reason: "is not a known language"},
+			{Row: 3, Field: "film_id", Reason: "is not the ID of a film"},
+		},
+	}, report)
+	assert.Empty(t, report.FilmIDs())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresImportPartialRows(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows, err := Parse(strings.NewReader("title,release_year,language,description,rating,length,rental_duration,rental_rate\n"+
+		"ACADEMY DINOSAUR,2006,English,,,,,\n"+
+		"NEW RELEASE,2024,English,,,,,\n"), CSV)
+	assert.NoError(t, err)
+	expectReference(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_MATCH)).
+		WillReturnRows(sqlmock.NewRows([]string{"ord", "film_id"}).AddRow(1, 1))
+	mock.ExpectRollback()
+
+	// the new film may leave replacement_cost out, not the one it would reset
+	report, err := NewPostgresImporter(db).Import(context.Background(), rows, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []RowError{{Row: 2, Field: "replacement_cost", Reason: "is required to update a film"}}, report.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
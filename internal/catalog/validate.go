@@ -0,0 +1,211 @@
+package catalog
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhaskew/rx/internal/films"
+)
+
+// The bounds and defaults of the film table.
+const (
+	maxTitleLength        = 255
+	minReleaseYear        = 1901
+	maxReleaseYear        = 2155
+	maxSmallint           = 32767
+	maxRentalRate         = films.Cents(9999)  // numeric(4,2)
+	maxReplacementCost    = films.Cents(99999) // numeric(5,2)
+	defaultRating         = "G"
+	defaultRentalDuration = 3
+	defaultRentalRate     = films.Cents(499)
+	defaultReplacement    = films.Cents(1999)
+)
+
+// Reference is the data rows refer to by name.
+type Reference struct {
+	// Languages and Categories map names, in upper case, to IDs.
+	Languages  map[string]int
+	Categories map[string]int
+}
+
+// film is a valid row, ready to be written.
+type film struct {
+	line            int
+	id              int
+	title           string
+	description     string
+	releaseYear     int
+	languageID      int
+	categoryID      int
+	rating          string
+	length          int
+	rentalDuration  int
+	rentalRate      films.Cents
+	replacementCost films.Cents
+	// matched is whether the row matched an existing film
+	matched bool
+	// missing are the columns the row was read without, see partial
+	missing []string
+}
+
+// key identifies films without an ID in the file, see Row.
+func (f film) key() string {
+	return strings.ToUpper(f.title) + "|" + strconv.Itoa(f.releaseYear)
+}
+
+// validate checks rows against the film schema and ref, returning the valid
+// ones as films and the errors of the others. Rows repeating an earlier one
+// are errors, they could only overwrite it.
+func validate(rows []Row, ref Reference) ([]film, []RowError) {
+	var valid []film
+	var errs []RowError
+	seen := map[string]int{}
+	for _, row := range rows {
+		f, rowErrs := validateRow(row, ref)
+		if len(rowErrs) == 0 {
+			key := f.key()
+			if f.id > 0 {
+				key = "#" + strconv.Itoa(f.id)
+			}
+			if line, ok := seen[key]; ok {
+				rowErrs = append(rowErrs, RowError{Row: row.Line, Reason: "repeats the film of row " + strconv.Itoa(line)})
+			}
+			seen[key] = row.Line
+		}
+		if len(rowErrs) > 0 {
+			errs = append(errs, rowErrs...)
+			continue
+		}
+		valid = append(valid, f)
+	}
+	return valid, errs
+}
+
+// repeated reports the rows matching the film an earlier row matched, e.g.
+// one by its ID and the other by its title.
+func repeated(valid []film) []RowError {
+	var errs []RowError
+	seen := map[int]int{}
+	for _, f := range valid {
+		if !f.matched {
+			continue
+		}
+		if line, ok := seen[f.id]; ok {
+			errs = append(errs, RowError{Row: f.line, Reason: "repeats the film of row " + strconv.Itoa(line)})
+			continue
+		}
+		seen[f.id] = f.line
+	}
+	return errs
+}
+
+// partial reports the missing columns of the rows matching a film, which
+// they could only set to their defaults. A missing category leaves the
+// film's categories be.
+func partial(valid []film) []RowError {
+	var errs []RowError
+	for _, f := range valid {
+		if !f.matched {
+			continue
+		}
+		for _, column := range f.missing {
+			errs = append(errs, RowError{Row: f.line, Field: column, Reason: "is required to update a film"})
+		}
+	}
+	return errs
+}
+
+func validateRow(row Row, ref Reference) (film, []RowError) {
+	for _, err := range row.errs {
+		// the row as a whole could not be read
+		if err.Field == "" {
+			return film{}, row.errs
+		}
+	}
+	errs := append([]RowError(nil), row.errs...)
+	invalid := func(field, reason string) {
+		errs = append(errs, RowError{Row: row.Line, Field: field, Reason: reason})
+	}
+	f := film{
+		line:           row.Line,
+		id:             row.FilmID,
+		title:          strings.TrimSpace(row.Title),
+		description:    strings.TrimSpace(row.Description),
+		releaseYear:    row.ReleaseYear,
+		rating:         strings.ToUpper(strings.TrimSpace(row.Rating)),
+		length:         row.Length,
+		rentalDuration: row.RentalDuration,
+	}
+	if row.columns != nil {
+		for _, column := range Columns {
+			if column != "film_id" && column != "category" && !row.columns[column] {
+				f.missing = append(f.missing, column)
+			}
+		}
+	}
+
+	if f.id < 0 {
+		invalid("film_id", "must be positive")
+	}
+	switch n := utf8.RuneCountInString(f.title); {
+	case n == 0:
+		invalid("title", "is required")
+	case n > maxTitleLength:
+		invalid("title", "must be at most 255 characters")
+	}
+	if f.releaseYear != 0 && (f.releaseYear < minReleaseYear || f.releaseYear > maxReleaseYear) {
+		invalid("release_year", "must be between 1901 and 2155")
+	}
+
+	language := strings.ToUpper(strings.TrimSpace(row.Language))
+	if language == "" {
+		invalid("language", "is required")
+	} else if id, ok := ref.Languages[language]; ok {
+		f.languageID = id
+	} else {
+		invalid("language", "is not a known language")
+	}
+	if category := strings.ToUpper(strings.TrimSpace(row.Category)); category != "" {
+		if id, ok := ref.Categories[category]; ok {
+			f.categoryID = id
+		} else {
+			invalid("category", "is not a known category")
+		}
+	}
+
+	if f.rating == "" {
+		f.rating = defaultRating
+	} else if !contains(films.Ratings, f.rating) {
+		invalid("rating", "must be one of "+strings.Join(films.Ratings, ", "))
+	}
+	if f.length < 0 || f.length > maxSmallint {
+		invalid("length", "must be between 0 and 32767 minutes")
+	}
+	if f.rentalDuration == 0 {
+		f.rentalDuration = defaultRentalDuration
+	} else if f.rentalDuration < 0 || f.rentalDuration > maxSmallint {
+		invalid("rental_duration", "must be between 1 and 32767 days")
+	}
+
+	var ok bool
+	if f.rentalRate, ok = amount(row.RentalRate, defaultRentalRate, maxRentalRate); !ok {
+		invalid("rental_rate", "must be an amount from 0 to 99.99")
+	}
+	if f.replacementCost, ok = amount(row.ReplacementCost, defaultReplacement, maxReplacementCost); !ok {
+		invalid("replacement_cost", "must be an amount from 0 to 999.99")
+	}
+	return f, errs
+}
+
+// amount parses a, which is def when empty and must not exceed max.
+func amount(a Amount, def, max films.Cents) (films.Cents, bool) {
+	if strings.TrimSpace(string(a)) == "" {
+		return def, true
+	}
+	c, err := films.ParseCents(string(a))
+	if err != nil || c < 0 || c > max {
+		return 0, false
+	}
+	return c, true
+}
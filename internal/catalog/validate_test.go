@@ -0,0 +1,155 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ref = Reference{
+	Languages:  map[string]int{"ENGLISH": 1, "ITALIAN": 2},
+	Categories: map[string]int{"ACTION": 1, "SCI-FI": 14},
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		row  Row
+		want film
+		errs []RowError
+	}{
+		{
+			name: "defaults",
+			row:  Row{Title: " ACADEMY DINOSAUR ", Language: "english", Line: 2},
+			want: film{line: 2, title: "ACADEMY DINOSAUR", languageID: 1, rating: "G", rentalDuration: 3, rentalRate: 499, replacementCost: 1999},
+		},
+		{
+			name: "every field",
+			row: Row{
+				FilmID: 7, Title: "AIRPLANE SIERRA", Description: "A touching saga", ReleaseYear: 2006, Language: "Italian",
+				Category: "sci-fi", Rating: "pg-13", Length: 62, RentalDuration: 6, RentalRate: "4.99", ReplacementCost: "28", Line: 2,
+			},
+			want: film{
+				line: 2, id: 7, title: "AIRPLANE SIERRA", description: "A touching saga", releaseYear: 2006, languageID: 2,
+				categoryID: 14, rating: "PG-13", length: 62, rentalDuration: 6, rentalRate: 499, replacementCost: 2800,
+			},
+		},
+		{
+			name: "schema",
+			row: Row{
+				FilmID: -1, Title: strings.Repeat("x", 256), ReleaseYear: 1900, Language: "English", Rating: "X",
+				Length: -1, RentalDuration: 40000, RentalRate: "100", ReplacementCost: "1.999", Line: 2,
+			},
+			errs: []RowError{
+				{Row: 2, Field: "film_id", Reason: "must be positive"},
+				{Row: 2, Field: "title", Reason: "must be at most 255 characters"},
+				{Row: 2, Field: "release_year", Reason: "must be between 1901 and 2155"},
+				{Row: 2, Field: "rating", Reason: "must be one of G, PG, PG-13, R, NC-17"},
+				{Row: 2, Field: "length", Reason: "must be between 0 and 32767 minutes"},
+				{Row: 2, Field: "rental_duration", Reason: "must be between 1 and 32767 days"},
+				{Row: 2, Field: "rental_rate", Reason: "must be an amount from 0 to 99.99"},
+				{Row: 2, Field: "replacement_cost", Reason: "must be an amount from 0 to 999.99"},
+			},
+		},
+		{
+			name: "reference data",
+			row:  Row{Title: "x", Language: "Klingon", Category: "Musical", Line: 2},
+			errs: []RowError{
+				{Row: 2, Field: "language", Reason: "is not a known language"},
+				{Row: 2, Field: "category", Reason: "is not a known category"},
+			},
+		},
+		{
+			name: "required",
+			row:  Row{Line: 2, errs: []RowError{{Row: 2, Field: "length", Reason: "must be an integer"}}},
+			errs: []RowError{
+				{Row: 2, Field: "length", Reason: "must be an integer"},
+				{Row: 2, Field: "title", Reason: "is required"},
+				{Row: 2, Field: "language", Reason: "is required"},
+			},
+		},
+		{
+			name: "unreadable",
+			row:  Row{Line: 2, errs: []RowError{{Row: 2, Reason: "has 1 cells, the header 2"}}},
+			errs: []RowError{{Row: 2, Reason: "has 1 cells, the header 2"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			valid, errs := validate([]Row{tt.row}, ref)
+			assert.Equal(t, tt.errs, errs)
+			if tt.errs == nil {
+				assert.Equal(t, []film{tt.want}, valid)
+			}
+		})
+	}
+}
+
+func TestValidateLength(t *testing.T) {
+	t.Parallel()
+	for _, length := range []int{0, maxSmallint} {
+		valid, errs := validate([]Row{{Title: "A", Language: "English", Length: length, Line: 2}}, ref)
+		assert.Empty(t, errs, length)
+		assert.Equal(t, length, valid[0].length)
+	}
+	for _, length := range []int{-1, maxSmallint + 1} {
+		_, errs := validate([]Row{{Title: "A", Language: "English", Length: length, Line: 2}}, ref)
+		assert.Equal(t, []RowError{{Row: 2, Field: "length", Reason: "must be between 0 and 32767 minutes"}}, errs, length)
+	}
+}
+
+func TestValidateRepeatedRows(t *testing.T) {
+	t.Parallel()
+	rows := []Row{
+		{Title: "A", Language: "English", Line: 2},
+		{Title: "a", Language: "English", Line: 3},
+		{Title: "A", ReleaseYear: 2006, Language: "English", Line: 4},
+		{FilmID: 1, Title: "B", Language: "English", Line: 5},
+		{FilmID: 1, Title: "C", Language: "English", Line: 6},
+	}
+	valid, errs := validate(rows, ref)
+	assert.Len(t, valid, 3)
+	assert.Equal(t, []RowError{
+		{Row: 3, Reason: "repeats the film of row 2"},
+		{Row: 6, Reason: "repeats the film of row 5"},
+	}, errs)
+
+	// the film of row 5 matched by title too
+	valid[0].id, valid[0].matched = 1, true
+	valid[2].matched = true
+	assert.Equal(t, []RowError{{Row: 5, Reason: "repeats the film of row 2"}}, repeated(valid))
+}
+
+func TestPartialRows(t *testing.T) {
+	t.Parallel()
+	every := columns(Columns...)
+	rows := []Row{
+		{Title: "A", Language: "English", Line: 2, columns: columns("title", "language", "length")},
+		{Title: "B", Language: "English", Line: 3, columns: columns("title", "language")},
+		{Title: "C", Language: "English", Line: 4, columns: every},
+		{Title: "D", Language: "English", Line: 5},
+	}
+	delete(rows[2].columns, "category")
+	valid, errs := validate(rows, ref)
+	assert.Empty(t, errs)
+
+	// new films may leave columns out
+	assert.Empty(t, partial(valid))
+
+	for i := range valid {
+		valid[i].matched = true
+	}
+	var want []RowError
+	for _, column := range []string{"description", "release_year", "rating", "rental_duration", "rental_rate", "replacement_cost"} {
+		want = append(want, RowError{Row: 2, Field: column, Reason: "is required to update a film"})
+	}
+	errs = partial(valid)
+	assert.Len(t, errs, 13)
+	assert.Equal(t, want, errs[:6])
+	assert.Equal(t, RowError{Row: 3, Field: "length", Reason: "is required to update a film"}, errs[9])
+}
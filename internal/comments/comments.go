@@ -0,0 +1,56 @@
+// Package comments stores customer comments on films and fans new ones out to
+// live subscribers.
+package comments
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxBodyLength is the longest comment accepted, in characters.
+const MaxBodyLength = 2000
+
+var (
+	// ErrFilmNotFound is returned when commenting on a film that does not
+	// exist.
+	ErrFilmNotFound = errors.New("film not found")
+	// ErrNotFound is returned for a comment that does not exist.
+	ErrNotFound    = errors.New("comment not found")
+	ErrEmptyBody   = errors.New("comment body is empty")
+	ErrBodyTooLong = errors.New("comment body is too long")
+)
+
+type Comment struct {
+	CommentID int       `json:"comment_id" xml:"comment_id"`
+	FilmID    int       `json:"film_id" xml:"film_id"`
+	Author    string    `json:"author" xml:"author"`
+	Body      string    `json:"body" xml:"body"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+}
+
+// Validate checks a comment before it is created.
+func (c Comment) Validate() error {
+	if strings.TrimSpace(c.Body) == "" {
+		return ErrEmptyBody
+	}
+	if utf8.RuneCountInString(c.Body) > MaxBodyLength {
+		return ErrBodyTooLong
+	}
+	return nil
+}
+
+type CommentRepository interface {
+	// GetByFilm returns the comments on a film, oldest first.
+	GetByFilm(ctx context.Context, filmID int) ([]Comment, error)
+	// GetByFilmIDs returns the comments on several films keyed by film ID,
+	// oldest first. Films without comments are missing from the map.
+	GetByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Comment, error)
+	// GetByID returns a comment on a film, or ErrNotFound if the film has no
+	// such comment.
+	GetByID(ctx context.Context, filmID, commentID int) (Comment, error)
+	// Create stores c and returns it with its ID and creation time set.
+	Create(ctx context.Context, c Comment) (Comment, error)
+}
@@ -0,0 +1,174 @@
+package comments
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrTooManySubscribers is returned by Subscribe when the hub or the
+	// film's topic is full.
+	ErrTooManySubscribers = errors.New("too many subscribers")
+	// ErrHubClosed is returned by Subscribe once the hub is closed.
+	ErrHubClosed = errors.New("comment hub closed")
+)
+
+// Subscriber receives new comments on one film on C. C is closed when the
+// hub is closed or the subscriber falls more than its queue behind; Cancel
+// must be called either way once the subscriber is done.
+type Subscriber struct {
+	C <-chan Comment
+
+	c         chan Comment
+	filmID    int
+	hub       *Hub
+	closed    bool
+	cancelled bool
+	lagged    bool
+}
+
+// Lagged reports whether the subscriber was dropped for falling behind rather
+// than the hub closing. Only meaningful once C is closed.
+func (s *Subscriber) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+// Cancel unsubscribes. It is safe to call more than once.
+func (s *Subscriber) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.cancelled {
+		return
+	}
+	s.cancelled = true
+	s.hub.remove(s)
+	s.hub.active.Done()
+}
+
+// HubStats is a snapshot of the hub's subscribers.
+type HubStats struct {
+	Subscribers int `json:"subscribers"`
+	Films       int `json:"films"`
+	// Dropped counts subscribers closed for falling behind.
+	Dropped uint64 `json:"dropped"`
+}
+
+// Hub is an in-process publish/subscribe hub with a topic per film. It is
+// safe for concurrent use.
+type Hub struct {
+	mu         sync.Mutex
+	topics     map[int]map[*Subscriber]struct{}
+	count      int
+	dropped    uint64
+	closed     bool
+	maxTotal   int
+	maxPerFilm int
+	queue      int
+	// active counts subscribers that have not been cancelled
+	active sync.WaitGroup
+}
+
+// NewHub returns a hub accepting up to maxTotal subscribers, at most
+// maxPerFilm of them on the same film; 0 means no limit. Each subscriber may
+// fall up to queue comments behind before it is dropped.
+func NewHub(maxTotal, maxPerFilm, queue int) *Hub {
+	if queue < 1 {
+		queue = 1
+	}
+	return &Hub{
+		topics:     make(map[int]map[*Subscriber]struct{}),
+		maxTotal:   maxTotal,
+		maxPerFilm: maxPerFilm,
+		queue:      queue,
+	}
+}
+
+// Subscribe returns a subscriber to new comments on filmID.
+func (h *Hub) Subscribe(filmID int) (*Subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	topic := h.topics[filmID]
+	if (h.maxTotal > 0 && h.count >= h.maxTotal) || (h.maxPerFilm > 0 && len(topic) >= h.maxPerFilm) {
+		return nil, ErrTooManySubscribers
+	}
+	if topic == nil {
+		topic = make(map[*Subscriber]struct{})
+		h.topics[filmID] = topic
+	}
+
+	c := make(chan Comment, h.queue)
+	s := &Subscriber{C: c, c: c, filmID: filmID, hub: h}
+	topic[s] = struct{}{}
+	h.count++
+	h.active.Add(1)
+	return s, nil
+}
+
+// Publish delivers c to the subscribers of its film without blocking.
+func (h *Hub) Publish(c Comment) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.topics[c.FilmID] {
+		select {
+		case s.c <- c:
+		default:
+			s.lagged = true
+			h.dropped++
+			h.remove(s)
+		}
+	}
+}
+
+func (h *Hub) Stats() HubStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HubStats{Subscribers: h.count, Films: len(h.topics), Dropped: h.dropped}
+}
+
+// Close closes every subscriber and rejects new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, topic := range h.topics {
+		for s := range topic {
+			h.remove(s)
+		}
+	}
+}
+
+// Wait blocks until every subscriber has been cancelled or ctx is done.
+func (h *Hub) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// remove closes s and drops it from its topic. Callers must hold mu.
+func (h *Hub) remove(s *Subscriber) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.c)
+	topic := h.topics[s.filmID]
+	delete(topic, s)
+	if len(topic) == 0 {
+		delete(h.topics, s.filmID)
+	}
+	h.count--
+}
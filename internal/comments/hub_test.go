@@ -0,0 +1,77 @@
+package comments
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubPublish(t *testing.T) {
+	t.Parallel()
+
+	h := NewHub(0, 0, 10)
+	film1, _ := h.Subscribe(1)
+	film2, _ := h.Subscribe(2)
+	assert.Equal(t, HubStats{Subscribers: 2, Films: 2}, h.Stats())
+
+	h.Publish(Comment{CommentID: 1, FilmID: 1})
+	h.Publish(Comment{CommentID: 2, FilmID: 3})
+
+	assert.Equal(t, 1, (<-film1.C).CommentID)
+	assert.Len(t, film1.C, 0)
+	assert.Len(t, film2.C, 0)
+
+	film1.Cancel()
+	film1.Cancel()
+	_, ok := <-film1.C
+	assert.False(t, ok)
+	assert.Equal(t, HubStats{Subscribers: 1, Films: 1}, h.Stats())
+}
+
+func TestHubLimits(t *testing.T) {
+	t.Parallel()
+
+	h := NewHub(3, 2, 1)
+	_, _ = h.Subscribe(1)
+	_, err := h.Subscribe(1)
+	assert.NoError(t, err)
+	_, err = h.Subscribe(1)
+	assert.Equal(t, ErrTooManySubscribers, err)
+	s, err := h.Subscribe(2)
+	assert.NoError(t, err)
+	_, err = h.Subscribe(3)
+	assert.Equal(t, ErrTooManySubscribers, err)
+
+	// a slow subscriber is dropped and frees its slot
+	h.Publish(Comment{FilmID: 2})
+	h.Publish(Comment{FilmID: 2})
+	<-s.C
+	_, ok := <-s.C
+	assert.False(t, ok)
+	assert.True(t, s.Lagged())
+	assert.Equal(t, uint64(1), h.Stats().Dropped)
+	_, err = h.Subscribe(3)
+	assert.NoError(t, err)
+}
+
+func TestHubClose(t *testing.T) {
+	t.Parallel()
+
+	h := NewHub(0, 0, 1)
+	s, _ := h.Subscribe(1)
+	h.Close()
+	_, ok := <-s.C
+	assert.False(t, ok)
+	assert.False(t, s.Lagged())
+	_, err := h.Subscribe(1)
+	assert.Equal(t, ErrHubClosed, err)
+
+	// Wait returns once the subscriber is done with its connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, h.Wait(ctx))
+	s.Cancel()
+	assert.NoError(t, h.Wait(context.Background()))
+}
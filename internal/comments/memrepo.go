@@ -0,0 +1,79 @@
+package comments
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memCommentRepository struct {
+	sync.Mutex
+	comments []Comment
+	// films are the IDs that can be commented on, any film if nil
+	films map[int]bool
+	now   func() time.Time
+}
+
+// NewMemCommentRepository returns an in-memory repository accepting comments
+// on filmIDs, or on any film if none are given.
+func NewMemCommentRepository(filmIDs ...int) CommentRepository {
+	r := &memCommentRepository{now: time.Now}
+	if len(filmIDs) > 0 {
+		r.films = make(map[int]bool, len(filmIDs))
+		for _, id := range filmIDs {
+			r.films[id] = true
+		}
+	}
+	return r
+}
+
+func (r *memCommentRepository) GetByFilm(ctx context.Context, filmID int) ([]Comment, error) {
+	r.Lock()
+	defer r.Unlock()
+	comments := []Comment{}
+	for _, c := range r.comments {
+		if c.FilmID == filmID {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (r *memCommentRepository) GetByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Comment, error) {
+	r.Lock()
+	defer r.Unlock()
+	wanted := make(map[int]bool, len(filmIDs))
+	for _, id := range filmIDs {
+		wanted[id] = true
+	}
+	comments := make(map[int][]Comment)
+	for _, c := range r.comments {
+		if wanted[c.FilmID] {
+			comments[c.FilmID] = append(comments[c.FilmID], c)
+		}
+	}
+	return comments, nil
+}
+
+func (r *memCommentRepository) GetByID(ctx context.Context, filmID, commentID int) (Comment, error) {
+	r.Lock()
+	defer r.Unlock()
+	for _, c := range r.comments {
+		if c.CommentID == commentID && c.FilmID == filmID {
+			return c, nil
+		}
+	}
+	return Comment{}, ErrNotFound
+}
+
+func (r *memCommentRepository) Create(ctx context.Context, c Comment) (Comment, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.films != nil && !r.films[c.FilmID] {
+		return Comment{}, ErrFilmNotFound
+	}
+	c.CommentID = len(r.comments) + 1
+	c.CreatedAt = r.now().UTC()
+	r.comments = append(r.comments, c)
+	return c, nil
+}
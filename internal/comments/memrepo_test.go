@@ -0,0 +1,57 @@
+package comments
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemCommentRepository(t *testing.T) {
+	t.Parallel()
+
+	repo := NewMemCommentRepository(1, 2)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	repo.(*memCommentRepository).now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c, err := repo.Create(ctx, Comment{FilmID: 1, Author: "a", Body: "great"})
+	assert.NoError(t, err)
+	assert.Equal(t, Comment{CommentID: 1, FilmID: 1, Author: "a", Body: "great", CreatedAt: now}, c)
+	_, _ = repo.Create(ctx, Comment{FilmID: 2, Author: "a", Body: "meh"})
+
+	_, err = repo.Create(ctx, Comment{FilmID: 3, Author: "a", Body: "?"})
+	assert.Equal(t, ErrFilmNotFound, err)
+
+	comments, err := repo.GetByFilm(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Comment{c}, comments)
+
+	comments, err = repo.GetByFilm(ctx, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+
+	byFilm, err := repo.GetByFilmIDs(ctx, []int{1, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]Comment{1: {c}}, byFilm)
+
+	got, err := repo.GetByID(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, c, got)
+	_, err = repo.GetByID(ctx, 2, 1)
+	assert.Equal(t, ErrNotFound, err, "comment 1 is on film 1")
+}
+
+func TestCommentValidate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, Comment{Body: "ok"}.Validate())
+	assert.Equal(t, ErrEmptyBody, Comment{Body: " \n"}.Validate())
+	long := make([]rune, MaxBodyLength+1)
+	for i := range long {
+		long[i] = 'é'
+	}
+	assert.Equal(t, ErrBodyTooLong, Comment{Body: string(long)}.Validate())
+	assert.NoError(t, Comment{Body: string(long[1:])}.Validate())
+}
@@ -0,0 +1,88 @@
+package comments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	SQL_GET_BY_FILM  = `SELECT comment_id, film_id, author, body, created_at FROM film_comment WHERE film_id = $1 ORDER BY comment_id ASC`
+	SQL_GET_BY_FILMS = `SELECT comment_id, film_id, author, body, created_at FROM film_comment WHERE film_id = ANY($1) ORDER BY film_id, comment_id ASC`
+	SQL_GET_BY_ID    = `SELECT comment_id, film_id, author, body, created_at FROM film_comment WHERE film_id = $1 AND comment_id = $2`
+	SQL_CREATE       = `INSERT INTO film_comment (film_id, author, body) VALUES ($1, $2, $3) RETURNING comment_id, created_at`
+)
+
+// foreignKeyViolation is the Postgres error code for a missing referenced row.
+const foreignKeyViolation = "23503"
+
+type postgresCommentRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCommentRepository(db *sql.DB) CommentRepository {
+	return &postgresCommentRepository{
+		db: db,
+	}
+}
+
+func (r *postgresCommentRepository) GetByFilm(ctx context.Context, filmID int) ([]Comment, error) {
+	rows, err := r.db.QueryContext(ctx, SQL_GET_BY_FILM, filmID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.CommentID, &c.FilmID, &c.Author, &c.Body, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+func (r *postgresCommentRepository) GetByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Comment, error) {
+	rows, err := r.db.QueryContext(ctx, SQL_GET_BY_FILMS, pq.Array(filmIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make(map[int][]Comment)
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.CommentID, &c.FilmID, &c.Author, &c.Body, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments[c.FilmID] = append(comments[c.FilmID], c)
+	}
+	return comments, rows.Err()
+}
+
+func (r *postgresCommentRepository) GetByID(ctx context.Context, filmID, commentID int) (Comment, error) {
+	var c Comment
+	err := r.db.QueryRowContext(ctx, SQL_GET_BY_ID, filmID, commentID).Scan(&c.CommentID, &c.FilmID, &c.Author, &c.Body, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Comment{}, ErrNotFound
+	}
+	return c, err
+}
+
+func (r *postgresCommentRepository) Create(ctx context.Context, c Comment) (Comment, error) {
+	err := r.db.QueryRowContext(ctx, SQL_CREATE, c.FilmID, c.Author, c.Body).Scan(&c.CommentID, &c.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return Comment{}, ErrFilmNotFound
+		}
+		return Comment{}, err
+	}
+	return c, nil
+}
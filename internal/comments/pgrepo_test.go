@@ -0,0 +1,98 @@
+package comments
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresGetByFilm(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_BY_FILM)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "film_id", "author", "body", "created_at"}).
+			AddRow(7, 1, "a", "great", created))
+
+	comments, err := NewPostgresCommentRepository(db).GetByFilm(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Comment{{CommentID: 7, FilmID: 1, Author: "a", Body: "great", CreatedAt: created}}, comments)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresGetByFilmIDs(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_BY_FILMS)).
+		WithArgs(pq.Array([]int{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "film_id", "author", "body", "created_at"}).
+			AddRow(7, 1, "a", "great", created).
+			AddRow(8, 2, "b", "meh", created))
+
+	comments, err := NewPostgresCommentRepository(db).GetByFilmIDs(context.Background(), []int{1, 2})
+	assert.NoError(t, err)
+	assert.Len(t, comments, 2)
+	assert.Equal(t, "meh", comments[2][0].Body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresGetByID(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_BY_ID)).
+		WithArgs(1, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "film_id", "author", "body", "created_at"}).
+			AddRow(7, 1, "a", "great", created))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_BY_ID)).
+		WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "film_id", "author", "body", "created_at"}))
+
+	repo := NewPostgresCommentRepository(db)
+	comment, err := repo.GetByID(context.Background(), 1, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, Comment{CommentID: 7, FilmID: 1, Author: "a", Body: "great", CreatedAt: created}, comment)
+	_, err = repo.GetByID(context.Background(), 2, 7)
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresCreate(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_CREATE)).
+		WithArgs(1, "a", "great").
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "created_at"}).AddRow(7, created))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_CREATE)).
+		WithArgs(999, "a", "great").
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+
+	repo := NewPostgresCommentRepository(db)
+	c, err := repo.Create(context.Background(), Comment{FilmID: 1, Author: "a", Body: "great"})
+	assert.NoError(t, err)
+	assert.Equal(t, Comment{CommentID: 7, FilmID: 1, Author: "a", Body: "great", CreatedAt: created}, c)
+
+	_, err = repo.Create(context.Background(), Comment{FilmID: 999, Author: "a", Body: "great"})
+	assert.Equal(t, ErrFilmNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,128 @@
+// Package compress compresses responses in a content coding the client
+// accepts, and decompresses gzip request bodies.
+//
+// Responses are buffered until they reach Options.MinSize, so that small
+// ones go out as they are, and then compressed as they are written. Flushing
+// a smaller response, e.g. a stream, compresses it too.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The supported content codings.
+const (
+	Brotli = "br"
+	Zstd   = "zstd"
+	Gzip   = "gzip"
+)
+
+// Encodings are the supported content codings, in the default order of
+// preference.
+var Encodings = []string{Brotli, Zstd, Gzip}
+
+// Options configures response compression.
+type Options struct {
+	// Encodings are the content codings offered, most preferred first.
+	// Compression is off without any.
+	Encodings []string
+	// MinSize is the size in bytes below which bodies are not compressed.
+	MinSize int
+	// Types are the media types compressed, e.g. "application/json".
+	Types []string
+}
+
+// encoder is what the gzip, brotli and zstd writers have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// zstdEncoder adapts the zstd encoder, whose Reset has no result.
+type zstdEncoder struct {
+	*zstd.Encoder
+}
+
+func (e zstdEncoder) Reset(w io.Writer) {
+	e.Encoder.Reset(w)
+}
+
+// pools keep encoders for reuse, they are costly to allocate.
+var pools = map[string]*sync.Pool{
+	Gzip: {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+	Brotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	Zstd: {New: func() interface{} {
+		// one goroutine per response rather than GOMAXPROCS
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			panic("compress: " + err.Error())
+		}
+		return zstdEncoder{enc}
+	}},
+}
+
+// Supported reports whether coding is one of Encodings.
+func Supported(coding string) bool {
+	_, ok := pools[coding]
+	return ok
+}
+
+func getEncoder(coding string, w io.Writer) encoder {
+	enc := pools[coding].Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func putEncoder(coding string, enc encoder) {
+	pools[coding].Put(enc)
+}
+
+// Negotiate picks the content coding of offers the Accept-Encoding header
+// prefers, the earliest offer among equals. It returns "" when the client
+// accepts none, or did not say.
+func Negotiate(acceptEncoding string, offers []string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return ""
+	}
+	q := make(map[string]float64)
+	wildcard := 0.0
+	for _, entry := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		weight := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				weight = f
+			}
+		}
+		if coding == "*" {
+			wildcard = weight
+		} else {
+			q[coding] = weight
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		weight, ok := q[offer]
+		if !ok {
+			weight = wildcard
+		}
+		if weight > bestQ {
+			best, bestQ = offer, weight
+		}
+	}
+	return best
+}
@@ -0,0 +1,225 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", Gzip},
+		{"gzip, deflate, br", Brotli},
+		{"gzip;q=1.0, br;q=0.5", Gzip},
+		{"zstd, gzip", Zstd},
+		{"*", Brotli},
+		{"*;q=0.5, br;q=0", Zstd},
+		{"br;q=0, zstd;q=0, gzip;q=0", ""},
+		{"identity", ""},
+		{"deflate", ""},
+		{"GZIP", Gzip},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Negotiate(tt.accept, Encodings), tt.accept)
+	}
+}
+
+var options = Options{Encodings: Encodings, MinSize: 100, Types: []string{"application/json", "text/plain"}}
+
+func serve(h http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	Middleware(func() Options { return options })(h).ServeHTTP(rr, req)
+	return rr
+}
+
+func decode(t *testing.T, coding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch coding {
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case Brotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return string(body)
+	}
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	large := `{"films": "` + strings.Repeat("ACADEMY DINOSAUR ", 20) + `"}`
+	small := `{"films": []}`
+
+	tests := []struct {
+		name           string
+		contentType    string
+		status         int
+		body           string
+		acceptEncoding string
+		encoding       string
+		vary           bool
+	}{
+		{"gzip", "application/json", http.StatusOK, large, "gzip", Gzip, true},
+		{"brotli", "application/json; charset=utf-8", http.StatusOK, large, "gzip, br", Brotli, true},
+		{"zstd", "application/json", http.StatusCreated, large, "zstd", Zstd, true},
+		{"not accepted", "application/json", http.StatusOK, large, "", "", true},
+		{"too small", "application/json", http.StatusOK, small, "gzip", "", true},
+		{"other type", "image/png", http.StatusOK, large, "gzip", "", false},
+		{"sniffed type", "", http.StatusOK, strings.Repeat("plain text ", 20), "gzip", Gzip, true},
+	}
+	for _, tt := range tests {
+		// twice, the second time with pooled encoders
+		for i := 0; i < 2; i++ {
+			rr := serve(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("Content-Length", "1")
+				w.WriteHeader(tt.status)
+				// in pieces, across the minimum size
+				_, _ = io.WriteString(w, tt.body[:10])
+				_, _ = io.WriteString(w, tt.body[10:])
+			}, tt.acceptEncoding)
+
+			assert.Equal(t, tt.status, rr.Code, tt.name)
+			assert.Equal(t, tt.encoding, rr.Header().Get("Content-Encoding"), tt.name)
+			assert.Equal(t, tt.vary, varies(rr.Header(), "Accept-Encoding"), tt.name)
+			if tt.encoding != "" {
+				assert.Empty(t, rr.Header().Get("Content-Length"), tt.name)
+			}
+			assert.Equal(t, tt.body, decode(t, tt.encoding, rr.Body.Bytes()), tt.name)
+		}
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	t.Parallel()
+	large := strings.Repeat("a", 200)
+
+	// strong validators of compressed responses are weakened
+	rr := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Vary", "Accept")
+		_, _ = io.WriteString(w, large)
+	}, "gzip")
+	assert.Equal(t, `W/"abc"`, rr.Header().Get("ETag"))
+	assert.Equal(t, []string{"Accept", "Accept-Encoding"}, rr.Header().Values("Vary"))
+
+	// already encoded
+	rr = serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = io.WriteString(w, large)
+	}, "br")
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, rr.Body.String())
+
+	// not modified
+	rr = serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusNotModified)
+	}, "gzip")
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	t.Parallel()
+	flushed := make(chan string, 1)
+	rr := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, "[1")
+		assert.NoError(t, http.NewResponseController(w).Flush())
+		flushed <- w.Header().Get("Content-Encoding")
+		_, _ = io.WriteString(w, ",2]")
+	}, "gzip")
+
+	// flushed responses are compressed however small
+	assert.Equal(t, Gzip, <-flushed)
+	assert.True(t, rr.Flushed)
+	assert.Equal(t, "[1,2]", decode(t, Gzip, rr.Body.Bytes()))
+}
+
+func TestMiddlewareOff(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	Middleware(func() Options { return Options{} })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, strings.Repeat("a", 200))
+	})).ServeHTTP(rr, req)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Empty(t, rr.Header().Get("Vary"))
+}
+
+func TestDecompress(t *testing.T) {
+	t.Parallel()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = io.WriteString(zw, `{"body": "great"}`)
+	_ = zw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		want     string
+	}{
+		{"gzip", "gzip", gz.Bytes(), http.StatusOK, `{"body": "great"}`},
+		{"identity", "", []byte(`{"body": "great"}`), http.StatusOK, `{"body": "great"}`},
+		{"invalid gzip", "gzip", []byte("not gzip"), http.StatusBadRequest, ""},
+		{"unsupported", "br", []byte("x"), http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		var got string
+		h := Decompress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("Content-Encoding"), tt.name)
+			b, err := io.ReadAll(r.Body)
+			assert.NoError(t, err, tt.name)
+			got = string(b)
+		}))
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, tt.status, rr.Code, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		if tt.status == http.StatusUnsupportedMediaType {
+			assert.Equal(t, Gzip, rr.Header().Get("Accept-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// Middleware compresses responses in the coding the request accepts, when
+// their media type is one of Options.Types and they are large enough.
+// Options are read per request, so they can be reloaded. Upgrade requests,
+// e.g. WebSockets, are left alone.
+func Middleware(options func() Options) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			opts := options()
+			if len(opts.Encodings) == 0 || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &responseWriter{
+				ResponseWriter: w,
+				opts:           opts,
+				coding:         Negotiate(r.Header.Get("Accept-Encoding"), opts.Encodings),
+			}
+			next.ServeHTTP(cw, r)
+			// not deferred: a response cut short by a panic must not be
+			// completed with a valid trailer
+			cw.close()
+		})
+	}
+}
+
+// responseWriter holds back the status and the start of the body until it
+// knows whether to compress.
+type responseWriter struct {
+	http.ResponseWriter
+	opts   Options
+	coding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if status < 200 {
+		// informational responses go out as they are
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.opts.MinSize {
+			return len(p), nil
+		}
+		return len(p), w.decide(true)
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends what was written so far, compressed if it would be once
+// large enough: a flushed response is taken to be a stream.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the media type of the response is one of
+// Options.Types.
+func (w *responseWriter) compressible() bool {
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range w.opts.Types {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide sends the header, compressing the body if it is large enough and
+// of the right type, and what was buffered of the body.
+func (w *responseWriter) decide(largeEnough bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// net/http would sniff the compressed body otherwise
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if w.compressible() {
+		// the representation depends on Accept-Encoding even when this one
+		// is not compressed
+		if !varies(h, "Accept-Encoding") {
+			h.Add("Vary", "Accept-Encoding")
+		}
+		if largeEnough && w.coding != "" && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+			w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+			h.Set("Content-Encoding", w.coding)
+			h.Del("Content-Length")
+			// the compressed bytes differ, strong validators must not match
+			// them; weak comparison, used for If-None-Match, still does
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.enc = getEncoder(w.coding, w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// close sends a response too small to have been sent yet and completes a
+// compressed one.
+func (w *responseWriter) close() {
+	if !w.decided {
+		if w.status == 0 {
+			// nothing was written, net/http answers 200
+			return
+		}
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		putEncoder(w.coding, w.enc)
+		w.enc = nil
+	}
+}
+
+func varies(h http.Header, field string) bool {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gzipBody is a decompressed request body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (b gzipBody) Close() error {
+	_ = b.Reader.Close()
+	return b.body.Close()
+}
+
+// Decompress replaces gzip encoded request bodies by their content, so that
+// handlers need not care. Other content codings are answered with 415 and an
+// Accept-Encoding header saying what is supported, as RFC 7694 suggests.
+func Decompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch coding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); coding {
+		case "", "identity":
+		case Gzip, "x-gzip":
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				problem.Error(w, r, http.StatusBadRequest, "The request body is not valid gzip")
+				return
+			}
+			r.Body = gzipBody{Reader: zr, body: r.Body}
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+		default:
+			w.Header().Set("Accept-Encoding", Gzip)
+			problem.Error(w, r, http.StatusUnsupportedMediaType, "Content-Encoding "+coding+" is not supported")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
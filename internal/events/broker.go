@@ -0,0 +1,249 @@
+// Package events fans out catalog and inventory changes to long-lived
+// subscribers such as the /v1/events stream.
+//
+// Every published event gets an ID one higher than the last and is kept in a
+// bounded replay buffer, so a subscriber that reconnects with the last ID it
+// saw receives what it missed as long as that is still buffered. IDs are
+// local to the process.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	FilmCreated       = "film.created"
+	FilmUpdated       = "film.updated"
+	InventoryRented   = "inventory.rented"
+	InventoryReturned = "inventory.returned"
+	CommentAdded      = "comment.added"
+)
+
+// Types lists every event type in the order they are documented.
+var Types = []string{FilmCreated, FilmUpdated, InventoryRented, InventoryReturned, CommentAdded}
+
+// Event is a single change.
+type Event struct {
+	ID   uint64
+	Type string
+	// StoreID is the store the event happened in, 0 if it is not store
+	// specific.
+	StoreID int
+	Time    time.Time
+	Data    json.RawMessage
+}
+
+// Filter selects events for a subscriber. The zero Filter selects everything.
+type Filter struct {
+	// Types selects events of these types, all types if empty.
+	Types []string
+	// StoreID selects events of that store and those not specific to any
+	// store, all stores if 0.
+	StoreID int
+}
+
+func (f Filter) Matches(e Event) bool {
+	if f.StoreID != 0 && e.StoreID != 0 && e.StoreID != f.StoreID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives the events matching its filter on C. C is closed when
+// the subscription is cancelled, the broker is closed, or the subscriber fell
+// more than its buffer behind; Lagged tells the last case apart.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	filter Filter
+	broker *Broker
+	lagged bool
+	closed bool
+}
+
+// Lagged reports whether the subscription was dropped for falling behind.
+// Only meaningful once C is closed.
+func (s *Subscription) Lagged() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.lagged
+}
+
+// Cancel stops the subscription. It is safe to call more than once.
+func (s *Subscription) Cancel() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Stats counts the broker's activity since it was created.
+type Stats struct {
+	Published   uint64 `json:"published"`
+	Subscribers int    `json:"subscribers"`
+	// Dropped counts subscriptions closed for falling behind.
+	Dropped uint64 `json:"dropped"`
+}
+
+// Broker publishes events to subscribers. It is safe for concurrent use.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	replay      []Event // ring of the last len(replay) events
+	next        int     // index in replay the next event is stored at
+	buffered    int
+	clientQueue int
+	subscribers map[*Subscription]struct{}
+	dropped     uint64
+	closed      bool
+	now         func() time.Time
+}
+
+// NewBroker returns a broker keeping the last replaySize events for resuming
+// subscribers. Each subscriber may fall up to clientQueue events behind before
+// it is dropped.
+func NewBroker(replaySize, clientQueue int) *Broker {
+	if replaySize < 0 {
+		replaySize = 0
+	}
+	if clientQueue < 1 {
+		clientQueue = 1
+	}
+	return &Broker{
+		replay:      make([]Event, replaySize),
+		clientQueue: clientQueue,
+		subscribers: make(map[*Subscription]struct{}),
+		now:         time.Now,
+	}
+}
+
+// Publish assigns the next ID to an event and delivers it to every matching
+// subscriber. data is marshalled to JSON.
+func (b *Broker) Publish(eventType string, storeID int, data interface{}) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	e := Event{ID: b.lastID, Type: eventType, StoreID: storeID, Time: b.now(), Data: raw}
+	if len(b.replay) > 0 {
+		b.replay[b.next] = e
+		b.next = (b.next + 1) % len(b.replay)
+		if b.buffered < len(b.replay) {
+			b.buffered++
+		}
+	}
+
+	for s := range b.subscribers {
+		if !s.filter.Matches(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			// never block publishers on a slow client, it can resume from
+			// the replay buffer
+			s.lagged = true
+			b.dropped++
+			b.remove(s)
+		}
+	}
+	return e, nil
+}
+
+// Subscribe returns a subscription to events matching filter. When lastID is
+// not 0, the buffered events after it are delivered first; the second result
+// is false if some of them are no longer buffered, or lastID was not issued by
+// this broker, e.g. before a restart.
+func (b *Broker) Subscribe(filter Filter, lastID uint64) (*Subscription, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	complete := lastID <= b.lastID
+	if lastID != 0 && lastID < b.lastID {
+		buffered := b.bufferedEvents()
+		if len(buffered) == 0 || buffered[0].ID > lastID+1 {
+			complete = false
+		}
+		for _, e := range buffered {
+			if e.ID > lastID && filter.Matches(e) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	c := make(chan Event, b.clientQueue+len(missed))
+	for _, e := range missed {
+		c <- e
+	}
+	s := &Subscription{C: c, c: c, filter: filter, broker: b}
+	if b.closed {
+		s.closed = true
+		close(c)
+		return s, complete
+	}
+	b.subscribers[s] = struct{}{}
+	return s, complete
+}
+
+// LastID is the ID of the most recently published event.
+func (b *Broker) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+func (b *Broker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Stats{Published: b.lastID, Subscribers: len(b.subscribers), Dropped: b.dropped}
+}
+
+// Close ends every subscription, e.g. on shutdown so that streaming handlers
+// return. Later subscriptions are closed immediately.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
+
+// bufferedEvents returns the replay buffer oldest first. Callers must hold mu.
+func (b *Broker) bufferedEvents() []Event {
+	if b.buffered == 0 {
+		return nil
+	}
+	events := make([]Event, 0, b.buffered)
+	start := (b.next - b.buffered + len(b.replay)) % len(b.replay)
+	for i := 0; i < b.buffered; i++ {
+		events = append(events, b.replay[(start+i)%len(b.replay)])
+	}
+	return events
+}
+
+// remove closes s. Callers must hold mu.
+func (b *Broker) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subscribers, s)
+	close(s.c)
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(s *Subscription) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestBrokerPublish(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(10, 10)
+	all, _ := b.Subscribe(Filter{}, 0)
+	store1, _ := b.Subscribe(Filter{StoreID: 1}, 0)
+	rented, _ := b.Subscribe(Filter{Types: []string{InventoryRented}}, 0)
+
+	_, _ = b.Publish(FilmCreated, 0, map[string]int{"film_id": 1})
+	_, _ = b.Publish(InventoryRented, 1, nil)
+	_, _ = b.Publish(InventoryRented, 2, nil)
+	e, err := b.Publish(InventoryReturned, 2, map[string]int{"film_id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), e.ID)
+	assert.JSONEq(t, `{"film_id":1}`, string(e.Data))
+
+	assert.Equal(t, []uint64{1, 2, 3, 4}, receive(all))
+	// events that are not store specific reach every store
+	assert.Equal(t, []uint64{1, 2}, receive(store1))
+	assert.Equal(t, []uint64{2, 3}, receive(rented))
+	assert.Equal(t, Stats{Published: 4, Subscribers: 3}, b.Stats())
+}
+
+func TestBrokerResume(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(3, 10)
+	for i := 0; i < 5; i++ {
+		_, _ = b.Publish(FilmUpdated, 0, nil)
+	}
+
+	s, complete := b.Subscribe(Filter{}, 3)
+	assert.True(t, complete)
+	assert.Equal(t, []uint64{4, 5}, receive(s))
+
+	// 2 has been evicted from the buffer
+	s, complete = b.Subscribe(Filter{}, 1)
+	assert.False(t, complete)
+	assert.Equal(t, []uint64{3, 4, 5}, receive(s))
+
+	// already up to date
+	s, complete = b.Subscribe(Filter{}, 5)
+	assert.True(t, complete)
+	assert.Empty(t, receive(s))
+	_, _ = b.Publish(FilmUpdated, 0, nil)
+	assert.Equal(t, []uint64{6}, receive(s))
+
+	// issued before a restart
+	_, complete = b.Subscribe(Filter{}, 100)
+	assert.False(t, complete)
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(10, 2)
+	slow, _ := b.Subscribe(Filter{}, 0)
+	for i := 0; i < 3; i++ {
+		_, _ = b.Publish(FilmUpdated, 0, nil)
+	}
+
+	assert.Equal(t, []uint64{1, 2}, receive(slow))
+	_, ok := <-slow.C
+	assert.False(t, ok)
+	assert.True(t, slow.Lagged())
+	assert.Equal(t, Stats{Published: 3, Dropped: 1}, b.Stats())
+
+	// it catches up from the replay buffer
+	slow, complete := b.Subscribe(Filter{}, 2)
+	assert.True(t, complete)
+	assert.Equal(t, []uint64{3}, receive(slow))
+}
+
+func TestBrokerClose(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(10, 10)
+	s, _ := b.Subscribe(Filter{}, 0)
+	s.Cancel()
+	s.Cancel()
+	_, ok := <-s.C
+	assert.False(t, ok)
+	assert.False(t, s.Lagged())
+
+	s, _ = b.Subscribe(Filter{}, 0)
+	b.Close()
+	_, ok = <-s.C
+	assert.False(t, ok)
+
+	s, _ = b.Subscribe(Filter{}, 0)
+	_, ok = <-s.C
+	assert.False(t, ok)
+}
@@ -0,0 +1,59 @@
+package films
+
+import "context"
+
+// Batch is the answer to a request for several films: those found, in the
+// order asked for, and the IDs of no film.
+type Batch struct {
+	Films   []Film `json:"films" xml:"film"`
+	Missing []int  `json:"missing" xml:"missing"`
+}
+
+// GetBatch loads the selection of the films with ids from repo, asking for
+// each ID once. Relations cannot be included.
+func GetBatch(ctx context.Context, repo FilmRepository, ids []int, sel Selection) (Batch, error) {
+	if len(sel.Include) > 0 {
+		return Batch{}, ErrNoRelations
+	}
+	ids = unique(ids)
+	list, err := repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return Batch{}, err
+	}
+	batch := Batch{Films: make([]Film, len(list)), Missing: []int{}}
+	found := make(map[int]bool, len(list))
+	for i, film := range list {
+		batch.Films[i] = sel.Project(film)
+		found[film.FilmID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			batch.Missing = append(batch.Missing, id)
+		}
+	}
+	return batch, nil
+}
+
+// unique drops repeated IDs, keeping the first of each.
+func unique(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var list []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// inOrder lists the films found by ID in the order of ids.
+func inOrder(ids []int, found map[int]Film) []Film {
+	films := make([]Film, 0, len(ids))
+	for _, id := range ids {
+		if film, ok := found[id]; ok {
+			films = append(films, film)
+		}
+	}
+	return films
+}
@@ -0,0 +1,43 @@
+package films
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBatch(t *testing.T) {
+	t.Parallel()
+	repo := NewMemFilmRepository(data2)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ids  []int
+		sel  Selection
+		want Batch
+	}{
+		{name: "in the order asked for", ids: []int{2, 1}, want: Batch{Films: []Film{data2[1], data2[0]}, Missing: []int{}}},
+		{name: "missing", ids: []int{3, 1, 4}, want: Batch{Films: []Film{data2[0]}, Missing: []int{3, 4}}},
+		{name: "repeated", ids: []int{1, 3, 1, 3}, want: Batch{Films: []Film{data2[0]}, Missing: []int{3}}},
+		{
+			name: "projected",
+			ids:  []int{1},
+			sel:  Selection{Fields: []string{"title"}},
+			want: Batch{Films: []Film{{FilmID: 1, Title: "Film 1"}}, Missing: []int{}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			batch, err := GetBatch(ctx, repo, tt.ids, tt.sel)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, batch)
+		})
+	}
+
+	_, err := GetBatch(ctx, repo, []int{1}, Selection{Include: []string{IncludeActors}})
+	assert.Equal(t, ErrNoRelations, err)
+}
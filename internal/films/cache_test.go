@@ -0,0 +1,221 @@
+package films
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 8, 24, 12, 0, 0, 0, time.UTC)
+	c := NewLRUCache(2)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	_, _ = c.Get("a") // b is now least recently used
+	c.Set("c", 3, time.Minute)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "b should have been evicted")
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, c.Len())
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "a should have expired")
+	assert.Equal(t, 1, c.Len())
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+// countingRepository counts GetAll/GetByID/GetByIDs calls and blocks them on gate.
+type countingRepository struct {
+	FilmRepository
+	calls int32
+	gate  chan struct{}
+	err   error
+}
+
+func (r *countingRepository) GetAll(ctx context.Context) ([]Film, error) {
+	atomic.AddInt32(&r.calls, 1)
+	if r.gate != nil {
+		<-r.gate
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.FilmRepository.GetAll(ctx)
+}
+
+func (r *countingRepository) GetByID(ctx context.Context, id int) (Film, error) {
+	atomic.AddInt32(&r.calls, 1)
+	return r.FilmRepository.GetByID(ctx, id)
+}
+
+func (r *countingRepository) GetByIDs(ctx context.Context, ids []int) ([]Film, error) {
+	atomic.AddInt32(&r.calls, 1)
+	return r.FilmRepository.GetByIDs(ctx, ids)
+}
+
+var ttls = CacheTTLs{GetAll: time.Minute, GetByID: time.Minute, Filtered: time.Minute}
+
+func TestCachedRepositoryHitsAndMisses(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository(data2)}
+	cached := NewCachedRepository(repo, NewLRUCache(10), ttls)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		films, err := cached.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, data2, films)
+	}
+	film, err := cached.GetByID(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, data2[1], film)
+	_, _ = cached.GetByID(ctx, 2)
+
+	assert.Equal(t, int32(2), repo.calls)
+	assert.Equal(t, CacheStats{Hits: 3, Misses: 2, Entries: 2}, cached.Stats())
+
+	// not found is not cached
+	_, err = cached.GetByID(ctx, 3)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = cached.GetByID(ctx, 3)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int32(4), repo.calls)
+}
+
+func TestCachedRepositoryGetByIDs(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository(data2)}
+	cached := NewCachedRepository(repo, NewLRUCache(10), ttls)
+	ctx := context.Background()
+
+	_, _ = cached.GetByID(ctx, 2)
+	films, err := cached.GetByIDs(ctx, []int{2, 3, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Film{data2[1], data2[0]}, films)
+	assert.Equal(t, int32(2), repo.calls, "film 2 is served from the cache")
+
+	// film 1 was cached with the batch, 3 is still missing
+	film, err := cached.GetByID(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, data2[0], film)
+	_, _ = cached.GetByIDs(ctx, []int{1, 2, 3})
+	assert.Equal(t, int32(3), repo.calls)
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 4, Entries: 2}, cached.Stats())
+}
+
+func TestCachedRepositoryInvalidate(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository(data2)}
+	cached := NewCachedRepository(repo, NewLRUCache(10), ttls)
+	ctx := context.Background()
+
+	_, _ = cached.GetAll(ctx)
+	_, _ = cached.GetByID(ctx, 1)
+	_, _ = cached.GetByID(ctx, 2)
+	cached.Invalidate(1)
+	_, _ = cached.GetAll(ctx)
+	_, _ = cached.GetByID(ctx, 1)
+	_, _ = cached.GetByID(ctx, 2)
+	assert.Equal(t, int32(5), repo.calls, "collections and film 1 are refetched, film 2 is not")
+
+	cached.Purge()
+	_, _ = cached.GetByID(ctx, 2)
+	assert.Equal(t, int32(6), repo.calls)
+
+	// a zero TTL bypasses the cache
+	cached.SetTTLs(CacheTTLs{})
+	_, _ = cached.GetByID(ctx, 2)
+	_, _ = cached.GetByID(ctx, 2)
+	assert.Equal(t, int32(8), repo.calls)
+}
+
+func TestCachedRepositoryCollapsesMisses(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository(data2), gate: make(chan struct{})}
+	cached := NewCachedRepository(repo, NewLRUCache(10), ttls)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			films, err := cached.GetAll(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, films, 2)
+		}()
+	}
+	// let the callers pile up behind the first query
+	for atomic.LoadInt32(&repo.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(repo.gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), repo.calls)
+	stats := cached.Stats()
+	assert.Equal(t, stats.Misses-1, stats.Shared, "every other miss shared the first query")
+}
+
+func TestCachedRepositoryDoesNotCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository(data2), err: errors.New("boom")}
+	cached := NewCachedRepository(repo, NewLRUCache(10), ttls)
+	_, err := cached.GetAll(context.Background())
+	assert.Error(t, err)
+	repo.err = nil
+	films, err := cached.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, films, 2)
+}
+
+func TestCachedRepositorySelection(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository([]Film{selectFilm})}
+	cached := NewCachedRepository(repo, NewLRUCache(10), ttls)
+	ctx := context.Background()
+	sel := Selection{Fields: []string{"title"}}
+
+	for i := 0; i < 2; i++ {
+		film, err := Get(ctx, cached, 1, sel)
+		assert.NoError(t, err)
+		assert.Equal(t, Film{FilmID: 1, Title: selectFilm.Title}, film)
+		err = Stream(ctx, cached, Filter{}, sel, func(f Film) error {
+			assert.Equal(t, Film{FilmID: 1, Title: selectFilm.Title}, f)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), repo.calls)
+
+	// relations are not cached, they come from the repository, which here
+	// cannot load them
+	_, err := Get(ctx, cached, 1, Selection{Include: []string{IncludeActors}})
+	assert.Equal(t, ErrNoRelations, err)
+
+	withRelations := NewCachedRepository(NewMemFilmRepository([]Film{selectFilm}), NewLRUCache(10), ttls)
+	film, err := Get(ctx, withRelations, 1, Selection{Fields: []string{"film_id"}, Include: []string{IncludeActors}})
+	assert.NoError(t, err)
+	assert.Equal(t, Film{FilmID: 1, Actors: selectFilm.Actors}, film)
+}
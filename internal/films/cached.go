@@ -0,0 +1,241 @@
+package films
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheTTLs is how long CachedRepository keeps each kind of result. A zero
+// TTL turns caching off for that kind.
+type CacheTTLs struct {
+	GetAll   time.Duration
+	GetByID  time.Duration
+	Filtered time.Duration
+}
+
+// CacheStats counts cache outcomes since the repository was created.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Shared counts misses answered by a query another caller already had
+	// in flight.
+	Shared  uint64 `json:"shared"`
+	Entries int    `json:"entries"`
+}
+
+// CachedRepository is a FilmRepository that caches the results of another.
+// Concurrent misses for the same key share a single query.
+//
+// Collection keys include a generation that Invalidate bumps, so every cached
+// collection is dropped at once without the backend having to enumerate keys.
+// Cached slices are shared between callers and must not be modified.
+type CachedRepository struct {
+	// accessed atomically, first for 64-bit alignment
+	generation uint64
+	hits       uint64
+	misses     uint64
+	shared     uint64
+
+	repo  FilmRepository
+	cache Cache
+	group singleflight.Group
+	ttls  atomic.Value // CacheTTLs
+}
+
+func NewCachedRepository(repo FilmRepository, cache Cache, ttls CacheTTLs) *CachedRepository {
+	c := &CachedRepository{
+		repo:  repo,
+		cache: cache,
+	}
+	c.ttls.Store(ttls)
+	return c
+}
+
+// SetTTLs changes the TTLs used for results cached from now on.
+func (c *CachedRepository) SetTTLs(ttls CacheTTLs) {
+	c.ttls.Store(ttls)
+}
+
+func (c *CachedRepository) TTLs() CacheTTLs {
+	return c.ttls.Load().(CacheTTLs)
+}
+
+func (c *CachedRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Shared:  atomic.LoadUint64(&c.shared),
+		Entries: c.cache.Len(),
+	}
+}
+
+// Invalidate drops the cached films with the given IDs and every cached
+// collection, which may contain them. Anything that writes films calls it.
+func (c *CachedRepository) Invalidate(filmIDs ...int) {
+	atomic.AddUint64(&c.generation, 1)
+	for _, id := range filmIDs {
+		c.cache.Delete(filmKey(id))
+	}
+}
+
+// Purge drops everything, e.g. when changes may have been missed.
+func (c *CachedRepository) Purge() {
+	atomic.AddUint64(&c.generation, 1)
+	c.cache.Purge()
+}
+
+func filmKey(id int) string {
+	return "film:" + strconv.Itoa(id)
+}
+
+func (c *CachedRepository) collectionKey(kind, arg string) string {
+	return "films:" + strconv.FormatUint(atomic.LoadUint64(&c.generation), 10) + ":" + kind + ":" + arg
+}
+
+// load returns the cached value for key, or calls fetch once for all
+// concurrent callers and caches its result for ttl. Results fetched across
+// an Invalidate are returned but not cached.
+//
+// The shared fetch runs with the context of whichever caller started it, so
+// a cancelled first caller fails the others too; errors are never cached.
+func (c *CachedRepository) load(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if ttl <= 0 {
+		return fetch()
+	}
+	if v, ok := c.cache.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return v, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	generation := atomic.LoadUint64(&c.generation)
+	leader := false
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		leader = true
+		v, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		if atomic.LoadUint64(&c.generation) == generation {
+			c.cache.Set(key, v, ttl)
+		}
+		return v, nil
+	})
+	if !leader {
+		atomic.AddUint64(&c.shared, 1)
+	}
+	return v, err
+}
+
+func (c *CachedRepository) loadFilms(key string, ttl time.Duration, fetch func() ([]Film, error)) ([]Film, error) {
+	v, err := c.load(key, ttl, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]Film), nil
+}
+
+func (c *CachedRepository) GetAll(ctx context.Context) ([]Film, error) {
+	return c.loadFilms(c.collectionKey("all", ""), c.TTLs().GetAll, func() ([]Film, error) {
+		return c.repo.GetAll(ctx)
+	})
+}
+
+func (c *CachedRepository) GetByID(ctx context.Context, id int) (Film, error) {
+	v, err := c.load(filmKey(id), c.TTLs().GetByID, func() (interface{}, error) {
+		return c.repo.GetByID(ctx, id)
+	})
+	if err != nil {
+		return Film{}, err
+	}
+	return v.(Film), nil
+}
+
+// GetByIDs serves the cached films and loads the others with one query,
+// caching them like GetByID.
+func (c *CachedRepository) GetByIDs(ctx context.Context, ids []int) ([]Film, error) {
+	ttl := c.TTLs().GetByID
+	if ttl <= 0 {
+		return c.repo.GetByIDs(ctx, ids)
+	}
+	found := make(map[int]Film, len(ids))
+	var missed []int
+	for _, id := range ids {
+		if v, ok := c.cache.Get(filmKey(id)); ok {
+			atomic.AddUint64(&c.hits, 1)
+			found[id] = v.(Film)
+		} else {
+			atomic.AddUint64(&c.misses, 1)
+			missed = append(missed, id)
+		}
+	}
+	if len(missed) > 0 {
+		generation := atomic.LoadUint64(&c.generation)
+		list, err := c.repo.GetByIDs(ctx, missed)
+		if err != nil {
+			return nil, err
+		}
+		current := atomic.LoadUint64(&c.generation) == generation
+		for _, film := range list {
+			found[film.FilmID] = film
+			if current {
+				c.cache.Set(filmKey(film.FilmID), film, ttl)
+			}
+		}
+	}
+	return inOrder(ids, found), nil
+}
+
+func (c *CachedRepository) GetAllByRating(ctx context.Context, rating string) ([]Film, error) {
+	return c.loadFilms(c.collectionKey("rating", rating), c.TTLs().Filtered, func() ([]Film, error) {
+		return c.repo.GetAllByRating(ctx, rating)
+	})
+}
+
+func (c *CachedRepository) GetAllByCategory(ctx context.Context, category string) ([]Film, error) {
+	return c.loadFilms(c.collectionKey("category", category), c.TTLs().Filtered, func() ([]Film, error) {
+		return c.repo.GetAllByCategory(ctx, category)
+	})
+}
+
+// LastModified is not cached, it is what tells clients whether their own
+// copies are current.
+func (c *CachedRepository) LastModified(ctx context.Context) (time.Time, error) {
+	return c.repo.LastModified(ctx)
+}
+
+// StreamFilms projects the cached collection. Relations are not cached:
+// selections including them are streamed from the underlying repository.
+func (c *CachedRepository) StreamFilms(ctx context.Context, filter Filter, sel Selection, fn func(Film) error) error {
+	if len(sel.Include) > 0 {
+		return Stream(ctx, c.repo, filter, sel, fn)
+	}
+	list, err := Collection(ctx, c, filter)
+	if err != nil {
+		return err
+	}
+	for _, film := range list {
+		if err := fn(sel.Project(film)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectByID projects the cached film, or loads the selection from the
+// underlying repository when it includes relations.
+func (c *CachedRepository) SelectByID(ctx context.Context, id int, sel Selection) (Film, error) {
+	if len(sel.Include) > 0 {
+		return Get(ctx, c.repo, id, sel)
+	}
+	film, err := c.GetByID(ctx, id)
+	if err != nil {
+		return Film{}, err
+	}
+	return sel.Project(film), nil
+}
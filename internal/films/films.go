@@ -1,10 +1,105 @@
 package films
 
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Film struct {
-	FilmID      int    `json:"film_id"`
-	Title       string `json:"title,omitempty"`
-	Description string `json:"description,omitempty"`
-	ReleaseYear int    `json:"release_year,omitempty"`
-	Rating      string `json:"rating,omitempty"`
-	Category    string `json:"category,omitempty"`
+	FilmID      int    `json:"film_id" xml:"film_id"`
+	Title       string `json:"title,omitempty" xml:"title,omitempty"`
+	Description string `json:"description,omitempty" xml:"description,omitempty"`
+	ReleaseYear int    `json:"release_year,omitempty" xml:"release_year,omitempty"`
+	Rating      string `json:"rating,omitempty" xml:"rating,omitempty"`
+	Category    string `json:"category,omitempty" xml:"category,omitempty"`
+	// Actors, Categories and Language are only loaded when included, see
+	// Selection.
+	Actors     Actors     `json:"actors,omitempty" xml:"actors,omitempty"`
+	Categories Categories `json:"categories,omitempty" xml:"categories,omitempty"`
+	Language   *Language  `json:"language,omitempty" xml:"language,omitempty"`
+	// Length is in minutes, 0 when unknown, and the rental terms are a
+	// duration in days and rates. They are only served by API v2.
+	Length          int   `json:"-" xml:"-"`
+	RentalDuration  int   `json:"-" xml:"-"`
+	RentalRate      Cents `json:"-" xml:"-"`
+	ReplacementCost Cents `json:"-" xml:"-"`
+	// LastUpdate backs Last-Modified, it is only loaded by GetByID
+	LastUpdate time.Time `json:"-" xml:"-"`
+}
+
+// Ratings are the MPAA ratings films are rated with.
+var Ratings = []string{"G", "PG", "PG-13", "R", "NC-17"}
+
+// Actors and Categories wrap their elements in XML, e.g. <actors><actor>.
+// Tags like "actors>actor" would write the wrapper even when there are none.
+type (
+	Actors     []Actor
+	Categories []Category
+)
+
+func (a Actors) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Actors []Actor `xml:"actor"`
+	}{a}, start)
+}
+
+func (c Categories) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Categories []Category `xml:"category"`
+	}{c}, start)
+}
+
+// Cents is an amount of money in hundredths. It scans numeric columns
+// without going through floats.
+type Cents int64
+
+// ParseCents reads a decimal amount with at most two decimals, e.g. "4.99".
+func ParseCents(s string) (Cents, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if strings.TrimPrefix(whole, "-")+frac == "" {
+		return 0, fmt.Errorf("amount %q is not a number", s)
+	}
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("amount %q has more than two decimals", s)
+	}
+	negative := strings.HasPrefix(whole, "-")
+	n, err := strconv.ParseInt(strings.TrimPrefix(whole, "-")+(frac + "00")[:2], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q: %w", s, err)
+	}
+	if negative {
+		n = -n
+	}
+	return Cents(n), nil
+}
+
+// String formats c as a decimal amount, e.g. "4.99".
+func (c Cents) String() string {
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+func (c *Cents) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return c.parse(string(v))
+	case string:
+		return c.parse(v)
+	case int64:
+		*c = Cents(v * 100)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Cents", src)
+}
+
+func (c *Cents) parse(s string) error {
+	n, err := ParseCents(s)
+	*c = n
+	return err
 }
@@ -4,17 +4,23 @@ import (
 	"context"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
 var expected = Film{
-	FilmID:      1,
-	Title:       "title",
-	Description: "description",
-	ReleaseYear: 2021,
-	Rating:      "rating",
+	FilmID:          1,
+	Title:           "title",
+	Description:     "description",
+	ReleaseYear:     2021,
+	Rating:          "rating",
+	Length:          86,
+	RentalDuration:  6,
+	RentalRate:      99,
+	ReplacementCost: 2099,
 }
 
 func TestGetAll(t *testing.T) {
@@ -25,8 +31,8 @@ func TestGetAll(t *testing.T) {
 
 	defer db.Close()
 
-	filmMockRows := sqlmock.NewRows([]string{"film_id", "title", "description", "release_year", "rating"})
-	filmMockRows.AddRow(expected.FilmID, expected.Title, expected.Description, expected.ReleaseYear, expected.Rating)
+	filmMockRows := sqlmock.NewRows([]string{"film_id", "title", "description", "release_year", "rating", "length", "rental_duration", "rental_rate", "replacement_cost"})
+	filmMockRows.AddRow(expected.FilmID, expected.Title, expected.Description, expected.ReleaseYear, expected.Rating, expected.Length, expected.RentalDuration, "0.99", "20.99")
 
 	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_ALL)).
 		WillReturnRows(filmMockRows)
@@ -84,8 +90,11 @@ func TestGetByID(t *testing.T) {
 
 	defer db.Close()
 
-	filmMockRows := sqlmock.NewRows([]string{"film_id", "title", "description", "release_year", "rating"})
-	filmMockRows.AddRow(expected.FilmID, expected.Title, expected.Description, expected.ReleaseYear, expected.Rating)
+	expected := expected
+	expected.LastUpdate = time.Date(2013, 5, 26, 14, 50, 58, 0, time.UTC)
+
+	filmMockRows := sqlmock.NewRows([]string{"film_id", "title", "description", "release_year", "rating", "length", "rental_duration", "rental_rate", "replacement_cost", "last_update"})
+	filmMockRows.AddRow(expected.FilmID, expected.Title, expected.Description, expected.ReleaseYear, expected.Rating, expected.Length, expected.RentalDuration, []byte("0.99"), []byte("20.99"), expected.LastUpdate)
 
 	mock.ExpectQuery(regexp.QuoteMeta(SQL_BY_ID)).
 		WithArgs(expected.FilmID).
@@ -99,3 +108,75 @@ func TestGetByID(t *testing.T) {
 	assert.Equal(t, expected, film, "we expected film to be %v but got %v", expected, film)
 	assert.NoError(t, mock.ExpectationsWereMet(), "an error '%s' was not expected while getting films", err)
 }
+
+func TestGetByIDs(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+
+	assert.NoError(t, err, "an error '%s' was not expected when opening a stub database connection")
+
+	defer db.Close()
+
+	second := expected
+	second.FilmID = 2
+
+	// rows come back in any order, films in the order asked for
+	filmMockRows := sqlmock.NewRows([]string{"film_id", "title", "description", "release_year", "rating", "length", "rental_duration", "rental_rate", "replacement_cost", "last_update"})
+	filmMockRows.AddRow(expected.FilmID, expected.Title, expected.Description, expected.ReleaseYear, expected.Rating, expected.Length, expected.RentalDuration, "0.99", "20.99", time.Time{})
+	filmMockRows.AddRow(second.FilmID, second.Title, second.Description, second.ReleaseYear, second.Rating, second.Length, second.RentalDuration, "0.99", "20.99", time.Time{})
+
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_BY_IDS)).
+		WithArgs(pq.Array([]int{2, 3, 1})).
+		WillReturnRows(filmMockRows)
+
+	repo := NewPostgresFilmRepository(db)
+	films, err := repo.GetByIDs(context.Background(), []int{2, 3, 1})
+
+	assert.NoError(t, err, "an error '%s' was not expected while getting films", err)
+	assert.Equal(t, []Film{second, expected}, films)
+	assert.NoError(t, mock.ExpectationsWereMet(), "an error '%s' was not expected while getting films", err)
+}
+
+func TestLastModified(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+
+	assert.NoError(t, err, "an error '%s' was not expected when opening a stub database connection")
+
+	defer db.Close()
+
+	lastUpdate := time.Date(2013, 5, 26, 14, 50, 58, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_LAST_MODIFIED)).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(lastUpdate))
+
+	repo := NewPostgresFilmRepository(db)
+	actual, err := repo.LastModified(context.Background())
+
+	assert.NoError(t, err, "an error '%s' was not expected while getting last modified", err)
+	assert.Equal(t, lastUpdate, actual)
+	assert.NoError(t, mock.ExpectationsWereMet(), "an error '%s' was not expected while getting last modified", err)
+}
+
+func TestCents(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   string
+		want Cents
+		out  string
+	}{
+		{"4.99", 499, "4.99"},
+		{"20", 2000, "20.00"},
+		{"0.5", 50, "0.50"},
+		{"-1.05", -105, "-1.05"},
+	}
+	for _, tt := range tests {
+		got, err := ParseCents(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+		assert.Equal(t, tt.out, got.String(), tt.in)
+	}
+	for _, in := range []string{"4.999", "abc", ""} {
+		_, err := ParseCents(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -3,8 +3,12 @@ package films
 import (
 	"context"
 	"sync"
+	"time"
 )
 
+// memFilmRepository serves films from memory. The films it is created with
+// may carry their relations, which are embedded when included like the
+// Postgres repository does.
 type memFilmRepository struct {
 	films []Film
 	sync.Mutex
@@ -17,40 +21,81 @@ func NewMemFilmRepository(films []Film) FilmRepository {
 }
 
 func (r *memFilmRepository) GetAll(context context.Context) ([]Film, error) {
-	return r.films, nil
+	return r.collect(context, Filter{})
 }
 
 func (r *memFilmRepository) GetByID(context context.Context, id int) (Film, error) {
+	return r.SelectByID(context, id, Selection{})
+}
+
+func (r *memFilmRepository) GetByIDs(context context.Context, ids []int) ([]Film, error) {
+	r.Lock()
+	defer r.Unlock()
+	found := make(map[int]Film, len(ids))
+	for _, film := range r.films {
+		found[film.FilmID] = Selection{}.Project(film)
+	}
+	return inOrder(ids, found), nil
+}
+
+func (r *memFilmRepository) SelectByID(ctx context.Context, id int, sel Selection) (Film, error) {
 	r.Lock()
 	defer r.Unlock()
 	for _, film := range r.films {
 		if film.FilmID == id {
-			return film, nil
+			return sel.Project(film), nil
 		}
 	}
 	return Film{}, ErrNotFound
 }
 
 func (r *memFilmRepository) GetAllByRating(context context.Context, rating string) ([]Film, error) {
+	return r.collect(context, Filter{Rating: rating})
+}
+
+func (r *memFilmRepository) GetAllByCategory(context context.Context, category string) ([]Film, error) {
+	return r.collect(context, Filter{Category: category})
+}
+
+// StreamFilms yields the films matching filter in the order they were given.
+func (r *memFilmRepository) StreamFilms(ctx context.Context, filter Filter, sel Selection, fn func(Film) error) error {
 	r.Lock()
-	defer r.Unlock()
 	var films []Film
 	for _, film := range r.films {
-		if film.Rating == rating {
-			films = append(films, film)
+		switch {
+		case filter.Rating != "" && film.Rating != filter.Rating:
+		case filter.Rating == "" && filter.Category != "" && film.Category != filter.Category:
+		default:
+			films = append(films, sel.Project(film))
+		}
+	}
+	r.Unlock()
+
+	for _, film := range films {
+		if err := fn(film); err != nil {
+			return err
 		}
 	}
-	return films, nil
+	return nil
 }
 
-func (r *memFilmRepository) GetAllByCategory(context context.Context, category string) ([]Film, error) {
+func (r *memFilmRepository) collect(ctx context.Context, filter Filter) ([]Film, error) {
+	films := []Film{}
+	err := r.StreamFilms(ctx, filter, Selection{}, func(film Film) error {
+		films = append(films, film)
+		return nil
+	})
+	return films, err
+}
+
+func (r *memFilmRepository) LastModified(context context.Context) (time.Time, error) {
 	r.Lock()
 	defer r.Unlock()
-	var films []Film
+	var lastModified time.Time
 	for _, film := range r.films {
-		if film.Category == category {
-			films = append(films, film)
+		if film.LastUpdate.After(lastModified) {
+			lastModified = film.LastUpdate
 		}
 	}
-	return films, nil
+	return lastModified, nil
 }
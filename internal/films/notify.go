@@ -0,0 +1,136 @@
+package films
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// CatalogChannel is the Postgres NOTIFY channel the catalog triggers publish
+// to, see the 0001_catalog_notify migration.
+const CatalogChannel = "catalog_changes"
+
+// CatalogChange is the payload of a catalog notification.
+type CatalogChange struct {
+	// Table is one of film, film_category, film_actor, inventory or rental.
+	Table string `json:"table"`
+	// Op is INSERT, UPDATE or DELETE. For rental it is INSERT when a copy is
+	// rented and UPDATE when it is returned.
+	Op     string `json:"op"`
+	FilmID int    `json:"film_id"`
+	// StoreID is only set for inventory and rental changes.
+	StoreID int `json:"store_id,omitempty"`
+	// InventoryID is only set for rental changes.
+	InventoryID int `json:"inventory_id,omitempty"`
+}
+
+// AffectsFilm reports whether the change alters the film itself, as opposed
+// to its availability.
+func (c CatalogChange) AffectsFilm() bool {
+	return c.Table != "rental"
+}
+
+// ChangeListener consumes catalog notifications from Postgres. The lib/pq
+// listener reconnects with exponential backoff between MinReconnect and
+// MaxReconnect; notifications sent while disconnected are lost, so
+// OnReconnect handlers should assume anything may have changed.
+//
+// Handlers must be registered before Run and are called from its goroutine.
+type ChangeListener struct {
+	dsn          string
+	logger       *zap.Logger
+	MinReconnect time.Duration
+	MaxReconnect time.Duration
+	PingInterval time.Duration
+
+	onChange    []func(CatalogChange)
+	onReconnect []func()
+}
+
+func NewChangeListener(dsn string, logger *zap.Logger) *ChangeListener {
+	return &ChangeListener{
+		dsn:          dsn,
+		logger:       logger,
+		MinReconnect: time.Second,
+		MaxReconnect: time.Minute,
+		PingInterval: 90 * time.Second,
+	}
+}
+
+// OnChange registers fn to be called for every catalog change.
+func (l *ChangeListener) OnChange(fn func(CatalogChange)) {
+	l.onChange = append(l.onChange, fn)
+}
+
+// OnReconnect registers fn to be called after the connection was lost and
+// re-established.
+func (l *ChangeListener) OnReconnect(fn func()) {
+	l.onReconnect = append(l.onReconnect, fn)
+}
+
+// Run listens until ctx is done.
+func (l *ChangeListener) Run(ctx context.Context) error {
+	listener := pq.NewListener(l.dsn, l.MinReconnect, l.MaxReconnect, l.event)
+	defer listener.Close()
+
+	// Listen blocks until connected, closing the listener releases it
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	if err := listener.Listen(CatalogChannel); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	l.logger.Info("Listening for catalog changes", zap.String("channel", CatalogChannel))
+
+	ping := time.NewTicker(l.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return ctx.Err()
+			}
+			if n == nil {
+				for _, fn := range l.onReconnect {
+					fn()
+				}
+				continue
+			}
+			l.dispatch(n.Extra)
+		case <-ping.C:
+			// notices a dead connection sooner than waiting for a notification
+			_ = listener.Ping()
+		}
+	}
+}
+
+func (l *ChangeListener) dispatch(payload string) {
+	var change CatalogChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		l.logger.Warn("Ignoring malformed catalog notification", zap.String("payload", payload), zap.Error(err))
+		return
+	}
+	for _, fn := range l.onChange {
+		fn(change)
+	}
+}
+
+func (l *ChangeListener) event(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		l.logger.Warn("Catalog listener disconnected", zap.Error(err))
+	case pq.ListenerEventReconnected:
+		l.logger.Info("Catalog listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.logger.Warn("Catalog listener could not connect, retrying", zap.Error(err))
+	}
+}
@@ -0,0 +1,75 @@
+package films
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestChangeListenerDispatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    []CatalogChange
+	}{
+		{
+			name:    "film",
+			payload: `{"table":"film","op":"UPDATE","film_id":7}`,
+			want:    []CatalogChange{{Table: "film", Op: "UPDATE", FilmID: 7}},
+		},
+		{
+			name:    "inventory",
+			payload: `{"table":"inventory","op":"INSERT","film_id":3,"store_id":2}`,
+			want:    []CatalogChange{{Table: "inventory", Op: "INSERT", FilmID: 3, StoreID: 2}},
+		},
+		{
+			name:    "rental",
+			payload: `{"table":"rental","op":"INSERT","film_id":3,"store_id":2,"inventory_id":12}`,
+			want:    []CatalogChange{{Table: "rental", Op: "INSERT", FilmID: 3, StoreID: 2, InventoryID: 12}},
+		},
+		{
+			name:    "malformed",
+			payload: `film:7`,
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			l := NewChangeListener("", zap.NewNop())
+			var got []CatalogChange
+			l.OnChange(func(c CatalogChange) {
+				got = append(got, c)
+			})
+			l.dispatch(tt.payload)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCatalogChangeEvictsCachedFilm(t *testing.T) {
+	t.Parallel()
+
+	repo := &countingRepository{FilmRepository: NewMemFilmRepository(data2)}
+	c := NewCachedRepository(repo, NewLRUCache(10), ttls)
+	l := NewChangeListener("", zap.NewNop())
+	l.OnChange(func(change CatalogChange) {
+		c.Invalidate(change.FilmID)
+	})
+
+	ctx := context.Background()
+	_, _ = c.GetByID(ctx, 1)
+	_, _ = c.GetByID(ctx, 2)
+	l.dispatch(`{"table":"film_actor","op":"DELETE","film_id":1}`)
+	_, _ = c.GetByID(ctx, 1)
+	_, _ = c.GetByID(ctx, 2)
+
+	assert.Equal(t, int32(3), repo.calls)
+	assert.Equal(t, uint64(1), c.Stats().Hits)
+}
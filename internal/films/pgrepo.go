@@ -4,94 +4,231 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 const (
-	SQL_BY_ID           = `SELECT film_id, title, description , release_year, rating FROM film WHERE film_id = $1 ORDER BY title ASC`
-	SQL_GET_ALL         = `SELECT film_id, title, description , release_year, rating FROM film ORDER BY title ASC`
-	SQL_GET_BY_RATING   = `SELECT film_id, title, description , release_year FROM film WHERE rating = $1 ORDER BY title ASC`
-	SQL_GET_BY_CATEGORY = `SELECT film_id, title, description , release_year, rating from film where film_id in(select distinct(film_id) from film_category where category_id = (select category_id from category where category.name = $1)) ORDER BY title ASC`
+	// the film queries are formatted with the columns to select, see columns
+	SQL_FILM_BY_ID        = `SELECT %s, last_update FROM film WHERE film_id = $1`
+	SQL_FILMS             = `SELECT %s FROM film ORDER BY title ASC`
+	SQL_FILMS_BY_IDS      = `SELECT %s, last_update FROM film WHERE film_id = ANY($1)`
+	SQL_FILMS_BY_RATING   = `SELECT %s FROM film WHERE rating = $1 ORDER BY title ASC`
+	SQL_FILMS_BY_CATEGORY = `SELECT %s from film where film_id in(select distinct(film_id) from film_category where category_id = (select category_id from category where category.name = $1)) ORDER BY title ASC`
+	SQL_LAST_MODIFIED     = `SELECT COALESCE(max(last_update), 'epoch') FROM film`
+)
+
+// sqlTerms selects the length and rental terms of films.
+const sqlTerms = "COALESCE(length, 0), rental_duration, rental_rate, replacement_cost"
+
+// The film queries of the zero Selection.
+var (
+	SQL_BY_ID           = fmt.Sprintf(SQL_FILM_BY_ID, "film_id, title, description, release_year, rating, "+sqlTerms)
+	SQL_GET_ALL         = fmt.Sprintf(SQL_FILMS, "film_id, title, description, release_year, rating, "+sqlTerms)
+	SQL_GET_BY_IDS      = fmt.Sprintf(SQL_FILMS_BY_IDS, "film_id, title, description, release_year, rating, "+sqlTerms)
+	SQL_GET_BY_RATING   = fmt.Sprintf(SQL_FILMS_BY_RATING, "film_id, title, description, release_year, "+sqlTerms)
+	SQL_GET_BY_CATEGORY = fmt.Sprintf(SQL_FILMS_BY_CATEGORY, "film_id, title, description, release_year, rating, "+sqlTerms)
 )
 
+// includeBatch is how many streamed films have their relations loaded at
+// once.
+const includeBatch = 100
+
 var ErrNotFound = errors.New("film not found")
 
 type postgressFilmRepository struct {
-	db *sql.DB
+	db        *sql.DB
+	relations RelationRepository
 }
 
 func NewPostgresFilmRepository(db *sql.DB) FilmRepository {
 	return &postgressFilmRepository{
-		db: db,
+		db:        db,
+		relations: NewPostgresRelationRepository(db),
+	}
+}
+
+// filmColumns are the selectable columns of film, by the fields they are
+// scanned into. The length and rental terms are only selected with every
+// field, they are not among Fields.
+var filmColumns = []struct {
+	field  string
+	column string
+	dest   func(*Film) interface{}
+}{
+	{"film_id", "film_id", func(f *Film) interface{} { return &f.FilmID }},
+	{"title", "title", func(f *Film) interface{} { return &f.Title }},
+	{"description", "description", func(f *Film) interface{} { return &f.Description }},
+	{"release_year", "release_year", func(f *Film) interface{} { return &f.ReleaseYear }},
+	{"rating", "rating", func(f *Film) interface{} { return &f.Rating }},
+	{"length", "COALESCE(length, 0)", func(f *Film) interface{} { return &f.Length }},
+	{"rental_duration", "rental_duration", func(f *Film) interface{} { return &f.RentalDuration }},
+	{"rental_rate", "rental_rate", func(f *Film) interface{} { return &f.RentalRate }},
+	{"replacement_cost", "replacement_cost", func(f *Film) interface{} { return &f.ReplacementCost }},
+}
+
+// columns returns the select list for sel and the scan destinations of its
+// columns in a film. The rating of films filtered by rating is the one asked
+// for, so it is not selected.
+func columns(filter Filter, sel Selection) (string, func(*Film) []interface{}) {
+	var names []string
+	var dests []func(*Film) interface{}
+	for _, c := range filmColumns {
+		if !sel.Has(c.field) || (c.field == "rating" && filter.Rating != "") {
+			continue
+		}
+		names = append(names, c.column)
+		dests = append(dests, c.dest)
+	}
+	return strings.Join(names, ", "), func(f *Film) []interface{} {
+		ptrs := make([]interface{}, len(dests))
+		for i, dest := range dests {
+			ptrs[i] = dest(f)
+		}
+		return ptrs
 	}
 }
 
 func (r *postgressFilmRepository) GetAll(context context.Context) ([]Film, error) {
-	rows, err := r.db.QueryContext(context, SQL_GET_ALL)
+	return r.collect(context, Filter{})
+}
+
+func (r *postgressFilmRepository) GetByID(context context.Context, id int) (Film, error) {
+	return r.SelectByID(context, id, Selection{})
+}
+
+// GetByIDs loads the films with one query, ordering them as ids. Like
+// GetByID it loads their LastUpdate.
+func (r *postgressFilmRepository) GetByIDs(ctx context.Context, ids []int) ([]Film, error) {
+	_, scan := columns(Filter{}, Selection{})
+	rows, err := r.db.QueryContext(ctx, SQL_GET_BY_IDS, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var films []Film
+	found := make(map[int]Film, len(ids))
 	for rows.Next() {
 		var film Film
-		err := rows.Scan(&film.FilmID, &film.Title, &film.Description, &film.ReleaseYear, &film.Rating)
-		if err != nil {
+		if err := rows.Scan(append(scan(&film), &film.LastUpdate)...); err != nil {
 			return nil, err
 		}
-		films = append(films, film)
+		found[film.FilmID] = film
 	}
-	return films, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return inOrder(ids, found), nil
 }
 
-func (r *postgressFilmRepository) GetByID(context context.Context, id int) (Film, error) {
+// SelectByID selects only the columns of sel.
+func (r *postgressFilmRepository) SelectByID(ctx context.Context, id int, sel Selection) (Film, error) {
+	list, scan := columns(Filter{}, sel)
 	var film Film
-	err := r.db.QueryRowContext(context, SQL_BY_ID, id).Scan(&film.FilmID, &film.Title, &film.Description, &film.ReleaseYear, &film.Rating)
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf(SQL_FILM_BY_ID, list), id).Scan(append(scan(&film), &film.LastUpdate)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Film{}, ErrNotFound
 		}
 		return Film{}, err
 	}
-	return film, nil
+	films := []Film{film}
+	if err := embed(ctx, r.relations, films, sel.Include); err != nil {
+		return Film{}, err
+	}
+	return films[0], nil
 }
 
 func (r *postgressFilmRepository) GetAllByRating(context context.Context, rating string) ([]Film, error) {
-	rows, err := r.db.QueryContext(context, SQL_GET_BY_RATING, rating)
-	if err != nil {
-		return nil, err
+	return r.collect(context, Filter{Rating: rating})
+}
+
+func (r *postgressFilmRepository) GetAllByCategory(context context.Context, category string) ([]Film, error) {
+	return r.collect(context, Filter{Category: category})
+}
+
+// StreamFilms scans films straight from the result rows, selecting only the
+// columns of sel. Relations are loaded includeBatch films at a time, once
+// every film has been read: querying them while the rows are open would hold
+// a second connection of the pool.
+func (r *postgressFilmRepository) StreamFilms(ctx context.Context, filter Filter, sel Selection, fn func(Film) error) error {
+	if len(sel.Include) == 0 {
+		return r.scanFilms(ctx, filter, sel, fn)
 	}
-	defer rows.Close()
 
-	var films []Film
-	for rows.Next() {
-		var film Film
-		err := rows.Scan(&film.FilmID, &film.Title, &film.Description, &film.ReleaseYear)
-		if err != nil {
-			return nil, err
+	var list []Film
+	err := r.scanFilms(ctx, filter, sel, func(film Film) error {
+		list = append(list, film)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(list); start += includeBatch {
+		batch := list[start:min(start+includeBatch, len(list))]
+		if err := embed(ctx, r.relations, batch, sel.Include); err != nil {
+			return err
+		}
+		for _, film := range batch {
+			if err := fn(film); err != nil {
+				return err
+			}
 		}
-		film.Rating = rating
-		films = append(films, film)
 	}
-	return films, nil
+	return nil
 }
 
-func (r *postgressFilmRepository) GetAllByCategory(context context.Context, category string) ([]Film, error) {
-	rows, err := r.db.QueryContext(context, SQL_GET_BY_CATEGORY, category)
+// scanFilms calls fn with each film of the result rows, without relations.
+func (r *postgressFilmRepository) scanFilms(ctx context.Context, filter Filter, sel Selection, fn func(Film) error) error {
+	list, scan := columns(filter, sel)
+	query, args := fmt.Sprintf(SQL_FILMS, list), []interface{}(nil)
+	switch {
+	case filter.Rating != "":
+		query, args = fmt.Sprintf(SQL_FILMS_BY_RATING, list), []interface{}{filter.Rating}
+	case filter.Category != "":
+		query, args = fmt.Sprintf(SQL_FILMS_BY_CATEGORY, list), []interface{}{filter.Category}
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var films []Film
 	for rows.Next() {
 		var film Film
-		err := rows.Scan(&film.FilmID, &film.Title, &film.Description, &film.ReleaseYear, &film.Rating)
-		if err != nil {
-			return nil, err
+		if err := rows.Scan(scan(&film)...); err != nil {
+			return err
+		}
+		if filter.Rating != "" && sel.Has("rating") {
+			film.Rating = filter.Rating
 		}
-		film.Category = category
+		if filter.Category != "" && sel.Has("category") {
+			film.Category = filter.Category
+		}
+		if err := fn(film); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *postgressFilmRepository) collect(ctx context.Context, filter Filter) ([]Film, error) {
+	var films []Film
+	err := r.StreamFilms(ctx, filter, Selection{}, func(film Film) error {
 		films = append(films, film)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return films, nil
 }
+
+func (r *postgressFilmRepository) LastModified(context context.Context) (time.Time, error) {
+	var lastModified time.Time
+	err := r.db.QueryRowContext(context, SQL_LAST_MODIFIED).Scan(&lastModified)
+	return lastModified, err
+}
@@ -0,0 +1,189 @@
+package films
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type Actor struct {
+	ActorID   int    `json:"actor_id" xml:"actor_id"`
+	FirstName string `json:"first_name" xml:"first_name"`
+	LastName  string `json:"last_name" xml:"last_name"`
+}
+
+type Category struct {
+	CategoryID int    `json:"category_id" xml:"category_id"`
+	Name       string `json:"name" xml:"name"`
+}
+
+type Language struct {
+	LanguageID int    `json:"language_id" xml:"language_id"`
+	Name       string `json:"name" xml:"name"`
+}
+
+// Availability is how many copies of a film a store has and how many of them
+// are not rented out.
+type Availability struct {
+	StoreID   int `json:"store_id"`
+	Copies    int `json:"copies"`
+	Available int `json:"available"`
+}
+
+// RelationRepository loads what is related to films, keyed by film ID, so
+// that the relations of many films are fetched in one query each. Films
+// without relations are missing from the maps.
+type RelationRepository interface {
+	ActorsByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Actor, error)
+	CategoriesByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Category, error)
+	LanguagesByFilmIDs(ctx context.Context, filmIDs []int) (map[int]Language, error)
+	AvailabilityByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Availability, error)
+}
+
+const (
+	SQL_ACTORS_BY_FILMS       = `SELECT fa.film_id, a.actor_id, a.first_name, a.last_name FROM film_actor fa JOIN actor a ON a.actor_id = fa.actor_id WHERE fa.film_id = ANY($1) ORDER BY fa.film_id, a.last_name, a.first_name`
+	SQL_CATEGORIES_BY_FILMS   = `SELECT fc.film_id, c.category_id, c.name FROM film_category fc JOIN category c ON c.category_id = fc.category_id WHERE fc.film_id = ANY($1) ORDER BY fc.film_id, c.name`
+	SQL_LANGUAGES_BY_FILMS    = `SELECT f.film_id, l.language_id, trim(l.name) FROM film f JOIN language l ON l.language_id = f.language_id WHERE f.film_id = ANY($1)`
+	SQL_AVAILABILITY_BY_FILMS = `SELECT i.film_id, i.store_id, count(*), count(*) FILTER (WHERE NOT EXISTS (SELECT 1 FROM rental r WHERE r.inventory_id = i.inventory_id AND r.return_date IS NULL)) FROM inventory i WHERE i.film_id = ANY($1) GROUP BY i.film_id, i.store_id ORDER BY i.film_id, i.store_id`
+)
+
+type postgresRelationRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRelationRepository(db *sql.DB) RelationRepository {
+	return &postgresRelationRepository{
+		db: db,
+	}
+}
+
+// query runs a by-films query and calls scan for each row.
+func (r *postgresRelationRepository) query(ctx context.Context, query string, filmIDs []int, scan func(*sql.Rows) error) error {
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(filmIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *postgresRelationRepository) ActorsByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Actor, error) {
+	actors := make(map[int][]Actor)
+	err := r.query(ctx, SQL_ACTORS_BY_FILMS, filmIDs, func(rows *sql.Rows) error {
+		var filmID int
+		var a Actor
+		if err := rows.Scan(&filmID, &a.ActorID, &a.FirstName, &a.LastName); err != nil {
+			return err
+		}
+		actors[filmID] = append(actors[filmID], a)
+		return nil
+	})
+	return actors, err
+}
+
+func (r *postgresRelationRepository) CategoriesByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Category, error) {
+	categories := make(map[int][]Category)
+	err := r.query(ctx, SQL_CATEGORIES_BY_FILMS, filmIDs, func(rows *sql.Rows) error {
+		var filmID int
+		var c Category
+		if err := rows.Scan(&filmID, &c.CategoryID, &c.Name); err != nil {
+			return err
+		}
+		categories[filmID] = append(categories[filmID], c)
+		return nil
+	})
+	return categories, err
+}
+
+func (r *postgresRelationRepository) LanguagesByFilmIDs(ctx context.Context, filmIDs []int) (map[int]Language, error) {
+	languages := make(map[int]Language)
+	err := r.query(ctx, SQL_LANGUAGES_BY_FILMS, filmIDs, func(rows *sql.Rows) error {
+		var filmID int
+		var l Language
+		if err := rows.Scan(&filmID, &l.LanguageID, &l.Name); err != nil {
+			return err
+		}
+		languages[filmID] = l
+		return nil
+	})
+	return languages, err
+}
+
+func (r *postgresRelationRepository) AvailabilityByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Availability, error) {
+	availability := make(map[int][]Availability)
+	err := r.query(ctx, SQL_AVAILABILITY_BY_FILMS, filmIDs, func(rows *sql.Rows) error {
+		var filmID int
+		var a Availability
+		if err := rows.Scan(&filmID, &a.StoreID, &a.Copies, &a.Available); err != nil {
+			return err
+		}
+		availability[filmID] = append(availability[filmID], a)
+		return nil
+	})
+	return availability, err
+}
+
+// MemRelations are the relations served by the in-memory repository, keyed
+// by film ID.
+type MemRelations struct {
+	Actors       map[int][]Actor
+	Categories   map[int][]Category
+	Languages    map[int]Language
+	Availability map[int][]Availability
+}
+
+type memRelationRepository struct {
+	relations MemRelations
+}
+
+func NewMemRelationRepository(relations MemRelations) RelationRepository {
+	return &memRelationRepository{
+		relations: relations,
+	}
+}
+
+func (r *memRelationRepository) ActorsByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Actor, error) {
+	actors := make(map[int][]Actor)
+	for _, id := range filmIDs {
+		if a, ok := r.relations.Actors[id]; ok {
+			actors[id] = a
+		}
+	}
+	return actors, nil
+}
+
+func (r *memRelationRepository) CategoriesByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Category, error) {
+	categories := make(map[int][]Category)
+	for _, id := range filmIDs {
+		if c, ok := r.relations.Categories[id]; ok {
+			categories[id] = c
+		}
+	}
+	return categories, nil
+}
+
+func (r *memRelationRepository) LanguagesByFilmIDs(ctx context.Context, filmIDs []int) (map[int]Language, error) {
+	languages := make(map[int]Language)
+	for _, id := range filmIDs {
+		if l, ok := r.relations.Languages[id]; ok {
+			languages[id] = l
+		}
+	}
+	return languages, nil
+}
+
+func (r *memRelationRepository) AvailabilityByFilmIDs(ctx context.Context, filmIDs []int) (map[int][]Availability, error) {
+	availability := make(map[int][]Availability)
+	for _, id := range filmIDs {
+		if a, ok := r.relations.Availability[id]; ok {
+			availability[id] = a
+		}
+	}
+	return availability, nil
+}
@@ -0,0 +1,53 @@
+package films
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRelations(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ids := pq.Array([]int{1, 2})
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_ACTORS_BY_FILMS)).WithArgs(ids).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "actor_id", "first_name", "last_name"}).
+			AddRow(1, 10, "PENELOPE", "GUINESS").
+			AddRow(1, 11, "NICK", "WAHLBERG").
+			AddRow(2, 10, "PENELOPE", "GUINESS"))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_CATEGORIES_BY_FILMS)).WithArgs(ids).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "category_id", "name"}).AddRow(2, 6, "Documentary"))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_LANGUAGES_BY_FILMS)).WithArgs(ids).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "language_id", "name"}).AddRow(1, 1, "English"))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_AVAILABILITY_BY_FILMS)).WithArgs(ids).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "store_id", "copies", "available"}).AddRow(1, 1, 4, 3))
+
+	repo := NewPostgresRelationRepository(db)
+	ctx := context.Background()
+
+	actors, err := repo.ActorsByFilmIDs(ctx, []int{1, 2})
+	assert.NoError(t, err)
+	assert.Len(t, actors[1], 2)
+	assert.Equal(t, []Actor{{ActorID: 10, FirstName: "PENELOPE", LastName: "GUINESS"}}, actors[2])
+
+	categories, err := repo.CategoriesByFilmIDs(ctx, []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]Category{2: {{CategoryID: 6, Name: "Documentary"}}}, categories)
+
+	languages, err := repo.LanguagesByFilmIDs(ctx, []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]Language{1: {LanguageID: 1, Name: "English"}}, languages)
+
+	availability, err := repo.AvailabilityByFilmIDs(ctx, []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]Availability{1: {{StoreID: 1, Copies: 4, Available: 3}}}, availability)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,11 +2,18 @@ package films
 
 import (
 	"context"
+	"time"
 )
 
 type FilmRepository interface {
 	GetAll(context.Context) ([]Film, error)
 	GetByID(context.Context, int) (Film, error)
+	// GetByIDs returns the films with the given IDs in the order asked for,
+	// skipping IDs of no film.
+	GetByIDs(context.Context, []int) ([]Film, error)
 	GetAllByRating(context.Context, string) ([]Film, error)
 	GetAllByCategory(context.Context, string) ([]Film, error)
+	// LastModified is the most recent last_update across all films, used to
+	// validate cached collections without loading them.
+	LastModified(context.Context) (time.Time, error)
 }
@@ -0,0 +1,167 @@
+package films
+
+import (
+	"context"
+	"errors"
+)
+
+// Fields are the fields of a Film a Selection may be restricted to, by JSON
+// name. film_id is always selected.
+var Fields = []string{"film_id", "title", "description", "release_year", "rating", "category"}
+
+// The relations a Selection may include.
+const (
+	IncludeActors     = "actors"
+	IncludeCategories = "categories"
+	IncludeLanguage   = "language"
+)
+
+// Includes are the relations a Selection may include.
+var Includes = []string{IncludeActors, IncludeCategories, IncludeLanguage}
+
+// ErrNoRelations is returned when relations are asked of a repository that
+// cannot load them.
+var ErrNoRelations = errors.New("films: repository cannot include relations")
+
+// Selection is what to load of each film: some of its fields and the
+// relations to embed. The zero Selection loads every field and no relations.
+type Selection struct {
+	Fields  []string
+	Include []string
+}
+
+// Has reports whether field is selected.
+func (s Selection) Has(field string) bool {
+	if len(s.Fields) == 0 || field == "film_id" {
+		return true
+	}
+	return contains(s.Fields, field)
+}
+
+// FieldNames lists the selected fields in the order of Fields, or nil when
+// all of them are.
+func (s Selection) FieldNames() []string {
+	if len(s.Fields) == 0 {
+		return nil
+	}
+	var names []string
+	for _, f := range Fields {
+		if s.Has(f) {
+			names = append(names, f)
+		}
+	}
+	return names
+}
+
+// Includes reports whether relation is to be embedded.
+func (s Selection) Includes(relation string) bool {
+	return contains(s.Include, relation)
+}
+
+// Project returns f with only the selected fields and included relations.
+// LastUpdate is kept, it is not part of the representation.
+func (s Selection) Project(f Film) Film {
+	p := Film{FilmID: f.FilmID, LastUpdate: f.LastUpdate}
+	if s.Has("title") {
+		p.Title = f.Title
+	}
+	if s.Has("description") {
+		p.Description = f.Description
+	}
+	if s.Has("release_year") {
+		p.ReleaseYear = f.ReleaseYear
+	}
+	if s.Has("rating") {
+		p.Rating = f.Rating
+	}
+	if s.Has("category") {
+		p.Category = f.Category
+	}
+	if len(s.Fields) == 0 {
+		p.Length, p.RentalDuration = f.Length, f.RentalDuration
+		p.RentalRate, p.ReplacementCost = f.RentalRate, f.ReplacementCost
+	}
+	if s.Includes(IncludeActors) {
+		p.Actors = f.Actors
+	}
+	if s.Includes(IncludeCategories) {
+		p.Categories = f.Categories
+	}
+	if s.Includes(IncludeLanguage) {
+		p.Language = f.Language
+	}
+	return p
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector is implemented by repositories that can load part of a film.
+type Selector interface {
+	SelectByID(ctx context.Context, id int, sel Selection) (Film, error)
+}
+
+// Get loads the selection of the film with id from repo. Repositories that
+// are not Selectors load the whole film, and cannot include relations.
+func Get(ctx context.Context, repo FilmRepository, id int, sel Selection) (Film, error) {
+	if s, ok := repo.(Selector); ok {
+		return s.SelectByID(ctx, id, sel)
+	}
+	if len(sel.Include) > 0 {
+		return Film{}, ErrNoRelations
+	}
+	film, err := repo.GetByID(ctx, id)
+	if err != nil {
+		return Film{}, err
+	}
+	return sel.Project(film), nil
+}
+
+// embed loads the included relations of films, with one query per relation.
+func embed(ctx context.Context, relations RelationRepository, films []Film, include []string) error {
+	if len(films) == 0 || len(include) == 0 {
+		return nil
+	}
+	ids := make([]int, len(films))
+	for i, f := range films {
+		ids[i] = f.FilmID
+	}
+
+	sel := Selection{Include: include}
+	if sel.Includes(IncludeActors) {
+		actors, err := relations.ActorsByFilmIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for i := range films {
+			films[i].Actors = actors[films[i].FilmID]
+		}
+	}
+	if sel.Includes(IncludeCategories) {
+		categories, err := relations.CategoriesByFilmIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for i := range films {
+			films[i].Categories = categories[films[i].FilmID]
+		}
+	}
+	if sel.Includes(IncludeLanguage) {
+		languages, err := relations.LanguagesByFilmIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for i := range films {
+			if l, ok := languages[films[i].FilmID]; ok {
+				films[i].Language = &l
+			}
+		}
+	}
+	return nil
+}
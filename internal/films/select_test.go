@@ -0,0 +1,113 @@
+package films
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var selectFilm = Film{
+	FilmID:      1,
+	Title:       "ACADEMY DINOSAUR",
+	Description: "description",
+	ReleaseYear: 2006,
+	Rating:      "PG",
+	Actors:      Actors{{ActorID: 1, FirstName: "PENELOPE", LastName: "GUINESS"}},
+	Categories:  Categories{{CategoryID: 6, Name: "Documentary"}},
+	Language:    &Language{LanguageID: 1, Name: "English"},
+}
+
+func TestSelectionProject(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		sel  Selection
+		want Film
+	}{
+		{"everything but relations", Selection{}, Film{FilmID: 1, Title: "ACADEMY DINOSAUR", Description: "description", ReleaseYear: 2006, Rating: "PG"}},
+		{"fields", Selection{Fields: []string{"title", "rating"}}, Film{FilmID: 1, Title: "ACADEMY DINOSAUR", Rating: "PG"}},
+		{"film_id alone", Selection{Fields: []string{"film_id"}}, Film{FilmID: 1}},
+		{"includes", Selection{Fields: []string{"film_id"}, Include: []string{IncludeActors, IncludeLanguage}}, Film{FilmID: 1, Actors: selectFilm.Actors, Language: selectFilm.Language}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.sel.Project(selectFilm), tt.name)
+	}
+	assert.Nil(t, Selection{}.FieldNames())
+	assert.Equal(t, []string{"film_id", "title", "rating"}, Selection{Fields: []string{"rating", "title"}}.FieldNames())
+}
+
+func TestStreamFilmsSelection(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT film_id, title FROM film WHERE rating = $1 ORDER BY title ASC`)).
+		WithArgs("PG").
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "title"}).AddRow(1, "ACADEMY DINOSAUR").AddRow(2, "ACE GOLDFINGER"))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_ACTORS_BY_FILMS)).
+		WithArgs(pq.Array([]int{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "actor_id", "first_name", "last_name"}).AddRow(1, 1, "PENELOPE", "GUINESS"))
+
+	repo := NewPostgresFilmRepository(db).(Streamer)
+	var got []Film
+	sel := Selection{Fields: []string{"title", "rating"}, Include: []string{IncludeActors}}
+	err = repo.StreamFilms(context.Background(), Filter{Rating: "PG"}, sel, func(f Film) error {
+		got = append(got, f)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Film{
+		{FilmID: 1, Title: "ACADEMY DINOSAUR", Rating: "PG", Actors: selectFilm.Actors},
+		{FilmID: 2, Title: "ACE GOLDFINGER", Rating: "PG"},
+	}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectByID(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	lastUpdate := time.Date(2013, 5, 26, 14, 50, 58, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT film_id, release_year, last_update FROM film WHERE film_id = $1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "release_year", "last_update"}).AddRow(1, 2006, lastUpdate))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_LANGUAGES_BY_FILMS)).
+		WithArgs(pq.Array([]int{1})).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "language_id", "name"}).AddRow(1, 1, "English"))
+
+	film, err := Get(context.Background(), NewPostgresFilmRepository(db), 1, Selection{Fields: []string{"release_year"}, Include: []string{IncludeLanguage}})
+	assert.NoError(t, err)
+	assert.Equal(t, Film{FilmID: 1, ReleaseYear: 2006, Language: selectFilm.Language, LastUpdate: lastUpdate}, film)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMemSelection(t *testing.T) {
+	t.Parallel()
+	repo := NewMemFilmRepository([]Film{selectFilm})
+	sel := Selection{Fields: []string{"title"}, Include: []string{IncludeCategories}}
+	want := Film{FilmID: 1, Title: "ACADEMY DINOSAUR", Categories: selectFilm.Categories}
+
+	film, err := Get(context.Background(), repo, 1, sel)
+	assert.NoError(t, err)
+	assert.Equal(t, want, film)
+
+	var got []Film
+	err = Stream(context.Background(), repo, Filter{Rating: "PG"}, sel, func(f Film) error {
+		got = append(got, f)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Film{want}, got)
+
+	// relations are only there when included
+	all, _ := repo.GetAll(context.Background())
+	assert.Nil(t, all[0].Actors)
+}
@@ -0,0 +1,51 @@
+package films
+
+import "context"
+
+// Filter selects the films of a collection. The zero Filter selects all of
+// them; Rating takes precedence over Category.
+type Filter struct {
+	Rating   string
+	Category string
+}
+
+// Streamer is implemented by repositories that can yield a collection a film
+// at a time rather than loading all of it.
+type Streamer interface {
+	// StreamFilms calls fn with the selection of each film matching filter,
+	// ordered by title, stopping at the first error fn returns.
+	StreamFilms(ctx context.Context, filter Filter, sel Selection, fn func(Film) error) error
+}
+
+// Stream calls fn with the selection of each film of repo matching filter,
+// ordered by title. Films are yielded as they are read when repo is a
+// Streamer, and from the loaded collection otherwise, without relations.
+func Stream(ctx context.Context, repo FilmRepository, filter Filter, sel Selection, fn func(Film) error) error {
+	if s, ok := repo.(Streamer); ok {
+		return s.StreamFilms(ctx, filter, sel, fn)
+	}
+	if len(sel.Include) > 0 {
+		return ErrNoRelations
+	}
+	list, err := Collection(ctx, repo, filter)
+	if err != nil {
+		return err
+	}
+	for _, film := range list {
+		if err := fn(sel.Project(film)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Collection loads the films of repo matching filter.
+func Collection(ctx context.Context, repo FilmRepository, filter Filter) ([]Film, error) {
+	switch {
+	case filter.Rating != "":
+		return repo.GetAllByRating(ctx, filter.Rating)
+	case filter.Category != "":
+		return repo.GetAllByCategory(ctx, filter.Category)
+	}
+	return repo.GetAll(ctx)
+}
@@ -0,0 +1,99 @@
+package films
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamFilms(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"film_id", "title", "description", "release_year", "rating", "length", "rental_duration", "rental_rate", "replacement_cost"}).
+		AddRow(1, "a", "", 2006, "PG", 0, 3, "4.99", "20.99").
+		AddRow(2, "b", "", 2006, "G", 0, 3, "4.99", "20.99").
+		AddRow(3, "c", "", 2006, "G", 0, 3, "4.99", "20.99")
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET_BY_CATEGORY)).WithArgs("Horror").WillReturnRows(rows)
+
+	repo := NewPostgresFilmRepository(db).(Streamer)
+	stop := errors.New("stop")
+	var got []Film
+	err = repo.StreamFilms(context.Background(), Filter{Category: "Horror"}, Selection{}, func(f Film) error {
+		got = append(got, f)
+		if len(got) == 2 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []Film{
+		{FilmID: 1, Title: "a", ReleaseYear: 2006, Rating: "PG", Category: "Horror", RentalDuration: 3, RentalRate: 499, ReplacementCost: 2099},
+		{FilmID: 2, Title: "b", ReleaseYear: 2006, Rating: "G", Category: "Horror", RentalDuration: 3, RentalRate: 499, ReplacementCost: 2099},
+	}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamFilmsIncludesOneConnection(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	// relations queried while the films' rows are open would wait for a
+	// second connection
+	db.SetMaxOpenConns(1)
+
+	rows := sqlmock.NewRows([]string{"film_id"})
+	ids := make([]int, includeBatch+1)
+	for i := range ids {
+		ids[i] = i + 1
+		rows.AddRow(ids[i])
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT film_id FROM film ORDER BY title ASC`)).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_ACTORS_BY_FILMS)).
+		WithArgs(pq.Array(ids[:includeBatch])).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "actor_id", "first_name", "last_name"}))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_ACTORS_BY_FILMS)).
+		WithArgs(pq.Array(ids[includeBatch:])).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id", "actor_id", "first_name", "last_name"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	repo := NewPostgresFilmRepository(db).(Streamer)
+	var got int
+	err = repo.StreamFilms(ctx, Filter{}, Selection{Fields: []string{"film_id"}, Include: []string{IncludeActors}}, func(Film) error {
+		got++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(ids), got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamFallsBackToCollection(t *testing.T) {
+	t.Parallel()
+	// embedding the interface hides StreamFilms
+	repo := struct{ FilmRepository }{NewMemFilmRepository([]Film{
+		{FilmID: 1, Title: "a", Rating: "PG"},
+		{FilmID: 2, Title: "b", Rating: "G"},
+	})}
+
+	var got []Film
+	err := Stream(context.Background(), repo, Filter{Rating: "G"}, Selection{Fields: []string{"title"}}, func(f Film) error {
+		got = append(got, f)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Film{{FilmID: 2, Title: "b"}}, got)
+
+	err = Stream(context.Background(), repo, Filter{}, Selection{Include: []string{IncludeActors}}, func(Film) error { return nil })
+	assert.Equal(t, ErrNoRelations, err)
+}
@@ -0,0 +1,44 @@
+package gql
+
+import (
+	"net/http"
+	"strings"
+)
+
+// GraphiQL serves the GraphiQL IDE, loaded from a CDN, querying endpoint. It
+// is meant for development only.
+func GraphiQL(endpoint string) http.HandlerFunc {
+	page := []byte(strings.Replace(graphiqlPage, "{{endpoint}}", endpoint, 1))
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(page)
+	}
+}
+
+const graphiqlPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>rx GraphiQL</title>
+  <style>body { height: 100vh; margin: 0; } #graphiql { height: 100vh; }</style>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3.0.6/graphiql.min.css">
+  <script crossorigin src="https://unpkg.com/react@18.2.0/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18.2.0/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3.0.6/graphiql.min.js"></script>
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script>
+    // the API key is kept in the headers tab, sent as X-API-Key
+    const fetcher = GraphiQL.createFetcher({ url: '{{endpoint}}' });
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(
+      React.createElement(GraphiQL, {
+        fetcher: fetcher,
+        defaultHeaders: '{"X-API-Key": ""}',
+        defaultQuery: '{\n  film(id: 1) {\n    title\n    actors { firstName lastName }\n    categories { name }\n    availability { storeId available }\n    comments { author body }\n  }\n}\n',
+      }),
+    );
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,120 @@
+package gql
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+var errInvalidPage = errors.New("limit must be between 0 and 1000 and offset cannot be negative")
+
+// maxRequestSize bounds the body of a POST request.
+const maxRequestSize = 1 << 20
+
+// request is a GraphQL request as sent in a POST body or GET query string.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler serves GraphQL over HTTP: queries are POSTed as JSON or sent as
+// the query, operationName and variables parameters of a GET.
+type Handler struct {
+	schema   graphql.Schema
+	resolver Resolver
+	limits   func() Limits
+}
+
+// NewHandler returns a handler for the schema over r, enforcing the limits
+// returned by limits on every request. It panics if the schema is invalid,
+// which is a programming error.
+func NewHandler(r Resolver, limits func() Limits) *Handler {
+	schema, err := NewSchema(r)
+	if err != nil {
+		panic("gql: invalid schema: " + err.Error())
+	}
+	return &Handler{schema: schema, resolver: r, limits: limits}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				problem.Error(w, r, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			problem.Error(w, r, http.StatusBadRequest, "Request body must be a JSON GraphQL request")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		problem.Error(w, r, http.StatusMethodNotAllowed, "GraphQL requests are GET or POST")
+		return
+	}
+	if req.Query == "" {
+		problem.Error(w, r, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	result := h.execute(r, req, h.limits())
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// execute parses, validates and checks req against the limits before running
+// it, with fresh loaders so that nothing is cached across requests.
+func (h *Handler) execute(r *http.Request, req request, limits Limits) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	validation := graphql.ValidateDocument(&h.schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if err := checkLimits(h.schema, doc, req.OperationName, req.Variables, limits); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if r.Method == http.MethodGet && isMutation(doc, req.OperationName) {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(errors.New("mutations must be POSTed"))}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(r.Context(), newLoaders(h.resolver)),
+	})
+}
+
+func isMutation(doc *ast.Document, operationName string) bool {
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+				return op.Operation == ast.OperationTypeMutation
+			}
+		}
+	}
+	return false
+}
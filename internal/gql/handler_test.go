@@ -0,0 +1,154 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+// countingRelations counts the batched calls made to a RelationRepository.
+type countingRelations struct {
+	films.RelationRepository
+	calls map[string][][]int
+}
+
+func (r *countingRelations) ActorsByFilmIDs(ctx context.Context, ids []int) (map[int][]films.Actor, error) {
+	r.calls["actors"] = append(r.calls["actors"], ids)
+	return r.RelationRepository.ActorsByFilmIDs(ctx, ids)
+}
+
+func (r *countingRelations) AvailabilityByFilmIDs(ctx context.Context, ids []int) (map[int][]films.Availability, error) {
+	r.calls["availability"] = append(r.calls["availability"], ids)
+	return r.RelationRepository.AvailabilityByFilmIDs(ctx, ids)
+}
+
+func testHandler(t *testing.T, limits Limits) (*Handler, *countingRelations) {
+	t.Helper()
+	mem := films.NewMemFilmRepository([]films.Film{
+		{FilmID: 1, Title: "ACADEMY DINOSAUR", Rating: "PG"},
+		{FilmID: 2, Title: "ACE GOLDFINGER", Rating: "G"},
+		{FilmID: 3, Title: "ADAPTATION HOLES", Rating: "NC-17"},
+	})
+	relations := &countingRelations{
+		RelationRepository: films.NewMemRelationRepository(films.MemRelations{
+			Actors: map[int][]films.Actor{
+				1: {{ActorID: 1, FirstName: "PENELOPE", LastName: "GUINESS"}},
+				2: {{ActorID: 2, FirstName: "NICK", LastName: "WAHLBERG"}},
+			},
+			Categories:   map[int][]films.Category{1: {{CategoryID: 6, Name: "Documentary"}}},
+			Languages:    map[int]films.Language{1: {LanguageID: 1, Name: "English"}},
+			Availability: map[int][]films.Availability{1: {{StoreID: 1, Copies: 4, Available: 3}, {StoreID: 2, Copies: 4, Available: 0}}},
+		}),
+		calls: map[string][][]int{},
+	}
+	commentRepo := comments.NewMemCommentRepository()
+	_, _ = commentRepo.Create(context.Background(), comments.Comment{FilmID: 1, Author: "kiosk", Body: "great"})
+
+	h := NewHandler(Resolver{Films: mem, Relations: relations, Comments: commentRepo}, func() Limits { return limits })
+	return h, relations
+}
+
+func post(t *testing.T, h http.Handler, query string, variables map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	body, _ := json.Marshal(request{Query: query, Variables: variables})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body))))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	return result
+}
+
+func TestFilmQuery(t *testing.T) {
+	t.Parallel()
+
+	h, _ := testHandler(t, Limits{})
+	result := post(t, h, `query($id: Int!) {
+		film(id: $id) {
+			title
+			actors { firstName lastName }
+			categories { name }
+			language { name }
+			availability(storeId: 1) { storeId copies available }
+			comments { author body }
+		}
+		missing: film(id: 99) { title }
+	}`, map[string]interface{}{"id": 1})
+
+	expected := `{"data": {
+		"film": {
+			"title": "ACADEMY DINOSAUR",
+			"actors": [{"firstName": "PENELOPE", "lastName": "GUINESS"}],
+			"categories": [{"name": "Documentary"}],
+			"language": {"name": "English"},
+			"availability": [{"storeId": 1, "copies": 4, "available": 3}],
+			"comments": [{"author": "kiosk", "body": "great"}]
+		},
+		"missing": null
+	}}`
+	actual, _ := json.Marshal(result)
+	assert.JSONEq(t, expected, string(actual))
+}
+
+func TestFilmsQueryBatchesRelations(t *testing.T) {
+	t.Parallel()
+
+	h, relations := testHandler(t, Limits{})
+	result := post(t, h, `{ films(limit: 3) { id actors { lastName } availability { available } } }`, nil)
+	assert.Nil(t, result["errors"])
+
+	list := result["data"].(map[string]interface{})["films"].([]interface{})
+	assert.Len(t, list, 3)
+	assert.Equal(t, []interface{}{}, list[2].(map[string]interface{})["actors"])
+
+	// one query per relation, not one per film
+	assert.Equal(t, [][]int{{1, 2, 3}}, relations.calls["actors"])
+	assert.Equal(t, [][]int{{1, 2, 3}}, relations.calls["availability"])
+}
+
+func TestFilmsQueryPaging(t *testing.T) {
+	t.Parallel()
+
+	h, _ := testHandler(t, Limits{})
+	result := post(t, h, `{ films(rating: "G") { id } page: films(limit: 1, offset: 1) { id } }`, nil)
+	actual, _ := json.Marshal(result["data"])
+	assert.JSONEq(t, `{"films": [{"id": 2}], "page": [{"id": 2}]}`, string(actual))
+
+	result = post(t, h, `{ films(limit: 5000) { id } }`, nil)
+	assert.Nil(t, result["data"])
+	assert.Len(t, result["errors"], 1)
+}
+
+func TestHandlerRequests(t *testing.T) {
+	t.Parallel()
+
+	h, _ := testHandler(t, Limits{})
+
+	q := url.Values{"query": {`{ film(id: 2) { title } }`}}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"data": {"film": {"title": "ACE GOLDFINGER"}}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", "/graphql", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	// validation errors are reported in the body
+	result := post(t, h, `{ film(id: 1) { budget } }`, nil)
+	assert.Nil(t, result["data"])
+	assert.Len(t, result["errors"], 1)
+}
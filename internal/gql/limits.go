@@ -0,0 +1,165 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// assumedListSize is how many items a list without a limit argument is
+// assumed to hold when estimating complexity, e.g. the actors of a film.
+const assumedListSize = 10
+
+// Limits bound the queries a client may run. A zero limit is not enforced.
+type Limits struct {
+	// MaxDepth is the deepest nesting of fields, `{ film { actors { id } } }`
+	// has a depth of 3.
+	MaxDepth int
+	// MaxComplexity bounds the estimated number of fields resolved: every
+	// field costs 1 and the cost of the fields below a list is multiplied by
+	// its limit argument, or by 10 if it has none.
+	MaxComplexity int
+}
+
+// limitError is returned when a query exceeds a limit.
+type limitError struct {
+	limit string
+	value int
+	max   int
+}
+
+func (e limitError) Error() string {
+	return fmt.Sprintf("query %s %d exceeds the maximum of %d", e.limit, e.value, e.max)
+}
+
+// measure walks the operation that will be executed to find its depth and
+// complexity. Introspection fields are not counted so that tools like
+// GraphiQL keep working under tight limits. The document must be valid.
+type measure struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func checkLimits(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}, limits Limits) error {
+	m := measure{schema: schema, fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	depth, complexity := m.selectionSet(operation.SelectionSet, schema.QueryType())
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return limitError{"depth", depth, limits.MaxDepth}
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		return limitError{"complexity", complexity, limits.MaxComplexity}
+	}
+	return nil
+}
+
+// selectionSet returns the depth and complexity of set selected on parent,
+// parent is nil below fields that are not in the schema, i.e. introspection.
+func (m measure) selectionSet(set *ast.SelectionSet, parent *graphql.Object) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+	maxDepth, total := 0, 0
+	add := func(depth, complexity int) {
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		total += complexity
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			add(m.field(selection, parent))
+		case *ast.InlineFragment:
+			add(m.selectionSet(selection.SelectionSet, m.typeCondition(selection.TypeCondition, parent)))
+		case *ast.FragmentSpread:
+			if fragment, ok := m.fragments[selection.Name.Value]; ok {
+				add(m.selectionSet(fragment.SelectionSet, m.typeCondition(fragment.TypeCondition, parent)))
+			}
+		}
+	}
+	return maxDepth, total
+}
+
+func (m measure) field(f *ast.Field, parent *graphql.Object) (int, int) {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+	var def *graphql.FieldDefinition
+	if parent != nil {
+		def = parent.Fields()[f.Name.Value]
+	}
+
+	var child *graphql.Object
+	multiplier := 1
+	if def != nil {
+		child, _ = graphql.GetNamed(def.Type).(*graphql.Object)
+		typ := def.Type
+		if nonNull, ok := typ.(*graphql.NonNull); ok {
+			typ = nonNull.OfType
+		}
+		if _, ok := typ.(*graphql.List); ok {
+			multiplier = m.listSize(f, def)
+		}
+	}
+
+	depth, complexity := m.selectionSet(f.SelectionSet, child)
+	return depth + 1, 1 + multiplier*complexity
+}
+
+// listSize is the limit argument of a list field, its default or
+// assumedListSize.
+func (m measure) listSize(f *ast.Field, def *graphql.FieldDefinition) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return n
+			}
+		case *ast.Variable:
+			switch n := m.variables[value.Name.Value].(type) {
+			case float64:
+				return int(n)
+			case int:
+				return n
+			}
+		}
+	}
+	for _, arg := range def.Args {
+		if arg.Name() == "limit" {
+			if n, ok := arg.DefaultValue.(int); ok {
+				return n
+			}
+		}
+	}
+	return assumedListSize
+}
+
+func (m measure) typeCondition(named *ast.Named, parent *graphql.Object) *graphql.Object {
+	if named == nil {
+		return parent
+	}
+	object, _ := m.schema.Type(named.Name.Value).(*graphql.Object)
+	return object
+}
@@ -0,0 +1,73 @@
+package gql
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLimits(t *testing.T) {
+	t.Parallel()
+
+	schema, err := NewSchema(Resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		variables  map[string]interface{}
+		depth      int
+		complexity int
+	}{
+		{
+			name:       "scalar",
+			query:      `{ film(id: 1) { title } }`,
+			depth:      2,
+			complexity: 2,
+		},
+		{
+			name:       "nested lists",
+			query:      `{ film(id: 1) { actors { firstName lastName } } }`,
+			depth:      3,
+			complexity: 1 + (1 + 10*2),
+		},
+		{
+			name:       "default limit",
+			query:      `{ films { title } }`,
+			depth:      2,
+			complexity: 1 + 100,
+		},
+		{
+			name:       "variable limit",
+			query:      `query($n: Int) { films(limit: $n) { title ...F } } fragment F on Film { comments { body } }`,
+			variables:  map[string]interface{}{"n": float64(5)},
+			depth:      3,
+			complexity: 1 + 5*(1+1+10),
+		},
+		{
+			name:       "introspection is free",
+			query:      `{ __schema { types { name fields { name type { ofType { ofType { name } } } } } } film(id: 1) { __typename id } }`,
+			depth:      2,
+			complexity: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.NoError(t, checkLimits(schema, doc, "", tt.variables, Limits{MaxDepth: tt.depth, MaxComplexity: tt.complexity}))
+			assert.Equal(t, limitError{"depth", tt.depth, tt.depth - 1},
+				checkLimits(schema, doc, "", tt.variables, Limits{MaxDepth: tt.depth - 1}))
+			assert.Equal(t, limitError{"complexity", tt.complexity, tt.complexity - 1},
+				checkLimits(schema, doc, "", tt.variables, Limits{MaxComplexity: tt.complexity - 1}))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package gql
+
+import (
+	"context"
+	"sync"
+)
+
+// batchFunc loads the values of many keys at once. Keys missing from the
+// result have no value.
+type batchFunc func(ctx context.Context, keys []int) (map[int]interface{}, error)
+
+// loader collects the keys requested while a level of the query is resolved
+// and loads them with a single batchFunc call once the first of them is
+// needed. The executor resolves thunks breadth first, so every film in a list
+// has asked for its actors before the first actor is loaded.
+type loader struct {
+	fetch batchFunc
+
+	mu      sync.Mutex
+	pending []int
+	queued  map[int]bool
+	values  map[int]interface{}
+	errs    map[int]error
+	batches int
+}
+
+func newLoader(fetch batchFunc) *loader {
+	return &loader{
+		fetch:  fetch,
+		queued: make(map[int]bool),
+		values: make(map[int]interface{}),
+		errs:   make(map[int]error),
+	}
+}
+
+// load queues key and returns a thunk resolving to its value, nil if it has
+// none.
+func (l *loader) load(ctx context.Context, key int) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.dispatch(ctx)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.values[key], l.errs[key]
+	}
+}
+
+// dispatch loads the pending keys, if any.
+func (l *loader) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	keys := l.pending
+	l.pending = nil
+	if len(keys) > 0 {
+		l.batches++
+	}
+	l.mu.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.fetch(ctx, keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		if v, ok := values[key]; ok {
+			l.values[key] = v
+		}
+	}
+}
+
+// loaders are the per request loaders of film relations.
+type loaders struct {
+	actors       *loader
+	categories   *loader
+	language     *loader
+	availability *loader
+	comments     *loader
+}
+
+func newLoaders(r Resolver) *loaders {
+	return &loaders{
+		actors: newLoader(func(ctx context.Context, ids []int) (map[int]interface{}, error) {
+			m, err := r.Relations.ActorsByFilmIDs(ctx, ids)
+			out := make(map[int]interface{}, len(m))
+			for k, v := range m {
+				out[k] = v
+			}
+			return out, err
+		}),
+		categories: newLoader(func(ctx context.Context, ids []int) (map[int]interface{}, error) {
+			m, err := r.Relations.CategoriesByFilmIDs(ctx, ids)
+			out := make(map[int]interface{}, len(m))
+			for k, v := range m {
+				out[k] = v
+			}
+			return out, err
+		}),
+		language: newLoader(func(ctx context.Context, ids []int) (map[int]interface{}, error) {
+			m, err := r.Relations.LanguagesByFilmIDs(ctx, ids)
+			out := make(map[int]interface{}, len(m))
+			for k, v := range m {
+				out[k] = v
+			}
+			return out, err
+		}),
+		availability: newLoader(func(ctx context.Context, ids []int) (map[int]interface{}, error) {
+			m, err := r.Relations.AvailabilityByFilmIDs(ctx, ids)
+			out := make(map[int]interface{}, len(m))
+			for k, v := range m {
+				out[k] = v
+			}
+			return out, err
+		}),
+		comments: newLoader(func(ctx context.Context, ids []int) (map[int]interface{}, error) {
+			m, err := r.Comments.GetByFilmIDs(ctx, ids)
+			out := make(map[int]interface{}, len(m))
+			for k, v := range m {
+				out[k] = v
+			}
+			return out, err
+		}),
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
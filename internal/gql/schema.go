@@ -0,0 +1,234 @@
+// Package gql serves a read-only GraphQL API over films and what is related
+// to them: actors, categories, language, availability and comments.
+//
+// Relations are loaded through per request loaders that batch the lookups of
+// every film at the same level of a query into one repository call, so
+// listing 100 films with their actors costs two queries rather than 101.
+package gql
+
+import (
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+const (
+	// defaultLimit is the number of films returned when the query has no
+	// limit.
+	defaultLimit = 100
+	// maxLimit is the most films a single list may return.
+	maxLimit = 1000
+)
+
+// Resolver holds the repositories queries are resolved against.
+type Resolver struct {
+	Films     films.FilmRepository
+	Relations films.RelationRepository
+	Comments  comments.CommentRepository
+}
+
+// field builds a non-null field read from a film, actor, etc. by fn.
+func field(typ graphql.Output, fn func(source interface{}) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return fn(p.Source), nil
+		},
+	}
+}
+
+// NewSchema builds the schema. It only fails if the schema itself is invalid.
+func NewSchema(r Resolver) (graphql.Schema, error) {
+	nonNullInt := graphql.NewNonNull(graphql.Int)
+	nonNullString := graphql.NewNonNull(graphql.String)
+
+	actorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Actor",
+		Fields: graphql.Fields{
+			"id":        field(nonNullInt, func(s interface{}) interface{} { return s.(films.Actor).ActorID }),
+			"firstName": field(nonNullString, func(s interface{}) interface{} { return s.(films.Actor).FirstName }),
+			"lastName":  field(nonNullString, func(s interface{}) interface{} { return s.(films.Actor).LastName }),
+		},
+	})
+
+	categoryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.Fields{
+			"id":   field(nonNullInt, func(s interface{}) interface{} { return s.(films.Category).CategoryID }),
+			"name": field(nonNullString, func(s interface{}) interface{} { return s.(films.Category).Name }),
+		},
+	})
+
+	languageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Language",
+		Fields: graphql.Fields{
+			"id":   field(nonNullInt, func(s interface{}) interface{} { return s.(films.Language).LanguageID }),
+			"name": field(nonNullString, func(s interface{}) interface{} { return s.(films.Language).Name }),
+		},
+	})
+
+	availabilityType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Availability",
+		Description: "Copies of a film in a store.",
+		Fields: graphql.Fields{
+			"storeId":   field(nonNullInt, func(s interface{}) interface{} { return s.(films.Availability).StoreID }),
+			"copies":    field(nonNullInt, func(s interface{}) interface{} { return s.(films.Availability).Copies }),
+			"available": field(nonNullInt, func(s interface{}) interface{} { return s.(films.Availability).Available }),
+		},
+	})
+
+	commentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comment",
+		Fields: graphql.Fields{
+			"id":     field(nonNullInt, func(s interface{}) interface{} { return s.(comments.Comment).CommentID }),
+			"author": field(nonNullString, func(s interface{}) interface{} { return s.(comments.Comment).Author }),
+			"body":   field(nonNullString, func(s interface{}) interface{} { return s.(comments.Comment).Body }),
+			"createdAt": field(nonNullString, func(s interface{}) interface{} {
+				return s.(comments.Comment).CreatedAt.UTC().Format(time.RFC3339)
+			}),
+		},
+	})
+
+	filmType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Film",
+		Fields: graphql.Fields{
+			"id":          field(nonNullInt, func(s interface{}) interface{} { return s.(films.Film).FilmID }),
+			"title":       field(nonNullString, func(s interface{}) interface{} { return s.(films.Film).Title }),
+			"description": field(graphql.String, func(s interface{}) interface{} { return s.(films.Film).Description }),
+			"releaseYear": field(graphql.Int, func(s interface{}) interface{} { return s.(films.Film).ReleaseYear }),
+			"rating":      field(graphql.String, func(s interface{}) interface{} { return s.(films.Film).Rating }),
+			"actors": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(actorType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					thunk := loadersFrom(p.Context).actors.load(p.Context, p.Source.(films.Film).FilmID)
+					return orEmpty(thunk, []films.Actor{}), nil
+				},
+			},
+			"categories": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					thunk := loadersFrom(p.Context).categories.load(p.Context, p.Source.(films.Film).FilmID)
+					return orEmpty(thunk, []films.Category{}), nil
+				},
+			},
+			"language": {
+				Type: languageType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).language.load(p.Context, p.Source.(films.Film).FilmID), nil
+				},
+			},
+			"availability": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(availabilityType))),
+				Args: graphql.FieldConfigArgument{
+					"storeId": {Type: graphql.Int, Description: "Only this store."},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					thunk := loadersFrom(p.Context).availability.load(p.Context, p.Source.(films.Film).FilmID)
+					storeID, filtered := p.Args["storeId"].(int)
+					return func() (interface{}, error) {
+						v, err := thunk()
+						all, _ := v.([]films.Availability)
+						result := []films.Availability{}
+						for _, a := range all {
+							if !filtered || a.StoreID == storeID {
+								result = append(result, a)
+							}
+						}
+						return result, err
+					}, nil
+				},
+			},
+			"comments": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					thunk := loadersFrom(p.Context).comments.load(p.Context, p.Source.(films.Film).FilmID)
+					return orEmpty(thunk, []comments.Comment{}), nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"film": {
+				Type: filmType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: nonNullInt},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					film, err := r.Films.GetByID(p.Context, p.Args["id"].(int))
+					if err == films.ErrNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return film, nil
+				},
+			},
+			"films": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(filmType))),
+				Description: "Films ordered by title, optionally of one rating or category.",
+				Args: graphql.FieldConfigArgument{
+					"rating":   {Type: graphql.String},
+					"category": {Type: graphql.String},
+					"limit":    {Type: graphql.Int, DefaultValue: defaultLimit, Description: "At most 1000."},
+					"offset":   {Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.films(p)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// orEmpty resolves to empty when the loader has no value for a key, so that
+// films without relations get an empty list rather than null.
+func orEmpty(thunk func() (interface{}, error), empty interface{}) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if v == nil {
+			return empty, err
+		}
+		return v, err
+	}
+}
+
+func (r Resolver) films(p graphql.ResolveParams) (interface{}, error) {
+	var list []films.Film
+	var err error
+	rating, _ := p.Args["rating"].(string)
+	category, _ := p.Args["category"].(string)
+	switch {
+	case rating != "":
+		list, err = r.Films.GetAllByRating(p.Context, rating)
+	case category != "":
+		list, err = r.Films.GetAllByCategory(p.Context, category)
+	default:
+		list, err = r.Films.GetAll(p.Context)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	limit, _ := p.Args["limit"].(int)
+	offset, _ := p.Args["offset"].(int)
+	if limit < 0 || limit > maxLimit || offset < 0 {
+		return nil, errInvalidPage
+	}
+	if offset > len(list) {
+		offset = len(list)
+	}
+	list = list[offset:]
+	if limit < len(list) {
+		list = list[:limit]
+	}
+	return list, nil
+}
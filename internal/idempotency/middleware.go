@@ -0,0 +1,192 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+const (
+	// Header carries the client's key for a request and its retries.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on replayed responses.
+	ReplayedHeader = "Idempotent-Replayed"
+	// MaxKeyLength is the longest key accepted.
+	MaxKeyLength = 255
+	// MaxBody is the largest request body fingerprinted.
+	MaxBody = 1 << 20
+	// inFlight bounds how long a request holds its key, so that one whose
+	// instance died can be retried; it outlasts the route timeouts.
+	inFlight = 2 * time.Minute
+)
+
+// fingerprint identifies a request by its method, path, query and body: a
+// retry must repeat all four, though its query parameters may be reordered.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.Query().Encode() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// scope is the principal a key belongs to, so that clients cannot replay
+// each other's responses.
+func scope(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Method + ":" + p.ID
+	}
+	return "anonymous"
+}
+
+// Middleware stores the first response to a POST carrying an Idempotency-Key
+// for ttl and replays it to later requests with the same key from the same
+// principal, marked with Idempotent-Replayed. A retry arriving while the
+// first request is in flight is answered with 409, and a key reused with
+// another method, path, query or body with 422. Server errors are not stored, so
+// the request can be retried. Store errors let the request through.
+func Middleware(store Store, ttl func() time.Duration, logger *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > MaxKeyLength {
+				problem.Error(w, r, http.StatusBadRequest, "Idempotency-Key is longer than 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBody))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				problem.Error(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			} else if err != nil {
+				problem.Error(w, r, http.StatusBadRequest, "Could not read the request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key = scope(r) + "|" + key
+			fp := fingerprint(r, body)
+			now := time.Now()
+			rec, created, err := store.Begin(r.Context(), key, fp, now, now.Add(inFlight))
+			if err != nil {
+				logger.Error("Idempotency store failed, allowing request", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !created {
+				switch {
+				case rec.Fingerprint != fp:
+					problem.Error(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was used with a different request")
+				case rec.Response == nil:
+					w.Header().Set("Retry-After", "1")
+					problem.Error(w, r, http.StatusConflict, "A request with this Idempotency-Key is in progress")
+				default:
+					replay(w, *rec.Response)
+				}
+				return
+			}
+
+			// the claim is released if the handler panics, the request
+			// still being retryable
+			ctx := context.WithoutCancel(r.Context())
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(ctx, key); err != nil {
+					logger.Error("Could not release idempotency key", zap.Error(err))
+				}
+			}()
+
+			recorded := newRecorder(w)
+			next.ServeHTTP(recorded, r)
+			if recorded.status >= 500 {
+				return
+			}
+			res := Response{Status: recorded.status, Header: recorded.handlerHeader(), Body: recorded.body.Bytes()}
+			if err := store.Complete(ctx, key, res, time.Now().Add(ttl())); err != nil {
+				logger.Error("Could not store idempotent response", zap.Error(err))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// replay writes a stored response over the headers set by the middleware in
+// front.
+func replay(w http.ResponseWriter, res Response) {
+	h := w.Header()
+	for k, v := range res.Header {
+		h[k] = v
+	}
+	h.Set(ReplayedHeader, "true")
+	w.WriteHeader(res.Status)
+	_, _ = w.Write(res.Body)
+}
+
+// recorder passes a response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	// before are the headers set in front of the handler
+	before      http.Header
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newRecorder(w http.ResponseWriter) *recorder {
+	return &recorder{ResponseWriter: w, before: w.Header().Clone(), status: http.StatusOK}
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	rec.header = rec.Header().Clone()
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// handlerHeader are the headers the handler set or changed.
+func (rec *recorder) handlerHeader() http.Header {
+	after := rec.header
+	if !rec.wroteHeader {
+		after = rec.Header()
+	}
+	header := http.Header{}
+	for k, v := range after {
+		if strings.Join(rec.before[k], "\n") != strings.Join(v, "\n") {
+			header[k] = v
+		}
+	}
+	return header
+}
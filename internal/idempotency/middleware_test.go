@@ -0,0 +1,159 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+)
+
+// creator answers every POST with a new resource, counting them.
+type creator struct {
+	created int32
+	// block holds requests until closed, when set
+	block chan struct{}
+	fail  bool
+}
+
+func (c *creator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.block != nil {
+		<-c.block
+	}
+	if c.fail {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	n := atomic.AddInt32(&c.created, 1)
+	w.Header().Set("Location", "/things/"+strconv.Itoa(int(n)))
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(`{"id": ` + strconv.Itoa(int(n)) + `}`))
+}
+
+func post(h http.Handler, principal, key, body string) *httptest.ResponseRecorder {
+	return postTo(h, "/things", principal, key, body)
+}
+
+func postTo(h http.Handler, target, principal, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: principal, Method: "api-key"}))
+	rr := httptest.NewRecorder()
+	// headers set in front of the middleware are not stored
+	rr.Header().Set("RateLimit-Remaining", strconv.Itoa(int(time.Now().UnixNano())))
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	c := &creator{}
+	h := Middleware(NewMemoryStore(), func() time.Duration { return time.Hour }, zap.NewNop())(c)
+
+	first := post(h, "kiosk", "k1", `{"a": 1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+
+	retry := post(h, "kiosk", "k1", `{"a": 1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "/things/1", retry.Header().Get("Location"))
+	assert.Equal(t, "true", retry.Header().Get(ReplayedHeader))
+	assert.NotEqual(t, first.Header().Get("RateLimit-Remaining"), retry.Header().Get("RateLimit-Remaining"))
+	assert.EqualValues(t, 1, c.created)
+
+	// a different payload
+	rr := post(h, "kiosk", "k1", `{"a": 2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	// keys are per principal
+	rr = post(h, "web", "k1", `{"a": 1}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, rr.Header().Get(ReplayedHeader))
+
+	// requests without a key are not deduplicated
+	post(h, "kiosk", "", `{"a": 1}`)
+	post(h, "kiosk", "", `{"a": 1}`)
+	assert.EqualValues(t, 4, c.created)
+
+	rr = post(h, "kiosk", strings.Repeat("k", MaxKeyLength+1), `{}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestMiddlewareQuery(t *testing.T) {
+	t.Parallel()
+	c := &creator{}
+	h := Middleware(NewMemoryStore(), func() time.Duration { return time.Hour }, zap.NewNop())(c)
+
+	first := postTo(h, "/things?dry_run=true&format=csv", "kiosk", "k1", `{}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	// the same parameters in another order are the same request
+	rr := postTo(h, "/things?format=csv&dry_run=true", "kiosk", "k1", `{}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "true", rr.Header().Get(ReplayedHeader))
+
+	for _, target := range []string{"/things", "/things?dry_run=false&format=csv", "/things?dry_run=true"} {
+		rr := postTo(h, target, "kiosk", "k1", `{}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, target)
+	}
+	assert.EqualValues(t, 1, c.created)
+}
+
+func TestMiddlewareInFlight(t *testing.T) {
+	t.Parallel()
+	c := &creator{block: make(chan struct{})}
+	h := Middleware(NewMemoryStore(), func() time.Duration { return time.Hour }, zap.NewNop())(c)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(h, "kiosk", "k1", `{}`) }()
+	// the first request holds the key until it is unblocked
+	assert.Eventually(t, func() bool {
+		return post(h, "kiosk", "k1", `{}`).Code == http.StatusConflict
+	}, time.Second, 5*time.Millisecond)
+
+	close(c.block)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, "true", post(h, "kiosk", "k1", `{}`).Header().Get(ReplayedHeader))
+}
+
+func TestMiddlewareServerErrors(t *testing.T) {
+	t.Parallel()
+	c := &creator{fail: true}
+	h := Middleware(NewMemoryStore(), func() time.Duration { return time.Hour }, zap.NewNop())(c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, post(h, "kiosk", "k1", `{}`).Code)
+	// server errors are not stored, the request is retried
+	c.fail = false
+	rr := post(h, "kiosk", "k1", `{}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, rr.Header().Get(ReplayedHeader))
+}
+
+// failingStore fails every call.
+type failingStore struct{ Store }
+
+func (failingStore) Begin(context.Context, string, string, time.Time, time.Time) (Record, bool, error) {
+	return Record{}, false, errors.New("down")
+}
+
+func TestMiddlewareStoreErrors(t *testing.T) {
+	t.Parallel()
+	c := &creator{}
+	h := Middleware(failingStore{}, func() time.Duration { return time.Hour }, zap.NewNop())(c)
+
+	assert.Equal(t, http.StatusCreated, post(h, "kiosk", "k1", `{}`).Code)
+	assert.Equal(t, http.StatusCreated, post(h, "kiosk", "k1", `{}`).Code)
+	assert.EqualValues(t, 2, c.created)
+}
@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The idempotency_key table is created by migration 0004. A record is in
+// flight while its status is NULL.
+const (
+	SQL_BEGIN = `INSERT INTO idempotency_key (key, fingerprint, expires_at) VALUES ($1, $2, $4)
+ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = NULL, header = NULL, body = NULL, expires_at = EXCLUDED.expires_at
+WHERE idempotency_key.expires_at <= $3
+RETURNING key`
+	SQL_GET      = `SELECT fingerprint, status, header, body FROM idempotency_key WHERE key = $1`
+	SQL_COMPLETE = `UPDATE idempotency_key SET status = $2, header = $3, body = $4, expires_at = $5 WHERE key = $1`
+	SQL_RELEASE  = `DELETE FROM idempotency_key WHERE key = $1 AND status IS NULL`
+	SQL_PURGE    = `DELETE FROM idempotency_key WHERE expires_at <= $1`
+)
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore keeps records in Postgres, shared by every instance.
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Begin(ctx context.Context, key, fingerprint string, now, until time.Time) (Record, bool, error) {
+	// the record claimed by another request may expire, and be purged,
+	// between the insert and the select, so the insert is retried once
+	for attempt := 0; attempt < 2; attempt++ {
+		var claimed string
+		err := s.db.QueryRowContext(ctx, SQL_BEGIN, key, fingerprint, now, until).Scan(&claimed)
+		if err == nil {
+			return Record{Fingerprint: fingerprint}, true, nil
+		} else if err != sql.ErrNoRows {
+			return Record{}, false, err
+		}
+
+		var rec Record
+		var status sql.NullInt64
+		var header, body []byte
+		err = s.db.QueryRowContext(ctx, SQL_GET, key).Scan(&rec.Fingerprint, &status, &header, &body)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return Record{}, false, err
+		}
+		if status.Valid {
+			rec.Response = &Response{Status: int(status.Int64), Body: body}
+			if err := json.Unmarshal(header, &rec.Response.Header); err != nil {
+				return Record{}, false, fmt.Errorf("idempotency key %q: %w", key, err)
+			}
+		}
+		return rec, false, nil
+	}
+	return Record{}, false, errors.New("idempotency key " + key + " changed while claiming it")
+}
+
+func (s *postgresStore) Complete(ctx context.Context, key string, res Response, expires time.Time) error {
+	header, err := json.Marshal(res.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, SQL_COMPLETE, key, res.Status, header, res.Body, expires)
+	return err
+}
+
+func (s *postgresStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, SQL_RELEASE, key)
+	return err
+}
+
+func (s *postgresStore) Purge(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, SQL_PURGE, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
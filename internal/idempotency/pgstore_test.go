@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresStoreBegin(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	until := now.Add(time.Minute)
+	store := NewPostgresStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_BEGIN)).
+		WithArgs("k", "fp", now, until).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("k"))
+	rec, created, err := store.Begin(context.Background(), "k", "fp", now, until)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, Record{Fingerprint: "fp"}, rec)
+
+	// a completed record
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_BEGIN)).
+		WithArgs("k", "fp", now, until).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET)).
+		WithArgs("k").
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "status", "header", "body"}).
+			AddRow("fp", 201, []byte(`{"Location":["/x"]}`), []byte("{}")))
+	rec, created, err = store.Begin(context.Background(), "k", "fp", now, until)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, &Response{Status: 201, Header: http.Header{"Location": {"/x"}}, Body: []byte("{}")}, rec.Response)
+
+	// an in-flight record
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_BEGIN)).
+		WithArgs("k", "fp", now, until).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_GET)).
+		WithArgs("k").
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "status", "header", "body"}).
+			AddRow("fp", nil, nil, nil))
+	rec, created, err = store.Begin(context.Background(), "k", "fp", now, until)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, Record{Fingerprint: "fp"}, rec)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStoreComplete(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	expires := time.Date(2023, 9, 2, 12, 0, 0, 0, time.UTC)
+	store := NewPostgresStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(SQL_COMPLETE)).
+		WithArgs("k", 201, []byte(`{"Location":["/x"]}`), []byte("{}"), expires).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(SQL_RELEASE)).
+		WithArgs("k").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(SQL_PURGE)).
+		WithArgs(expires).
+		WillReturnResult(driver.RowsAffected(3))
+
+	assert.NoError(t, store.Complete(context.Background(), "k", Response{Status: 201, Header: http.Header{"Location": {"/x"}}, Body: []byte("{}")}, expires))
+	assert.NoError(t, store.Release(context.Background(), "k"))
+	n, err := store.Purge(context.Background(), expires)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,103 @@
+// Package idempotency makes POST requests safe to retry: the first response
+// to a request carrying an Idempotency-Key is stored and replayed to the
+// retries of that request.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a stored response.
+type Response struct {
+	Status int
+	// Header holds the headers set by the handler, not by the middleware
+	// in front of it.
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what a store holds for a key.
+type Record struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	// Response is nil while that request is in flight.
+	Response *Response
+}
+
+// Store keeps records by key. Implementations must be safe for concurrent
+// use; an external store lets several instances share keys.
+type Store interface {
+	// Begin claims key for a request with fingerprint until it completes or
+	// until, whichever comes first. A key whose record expired is claimed
+	// anew. It returns the record and whether this call created it.
+	Begin(ctx context.Context, key, fingerprint string, now, until time.Time) (Record, bool, error)
+	// Complete stores the response to the request that claimed key, kept
+	// until expires.
+	Complete(ctx context.Context, key string, res Response, expires time.Time) error
+	// Release gives up an in-flight claim, so that the request can be
+	// retried.
+	Release(ctx context.Context, key string) error
+	// Purge drops the records expired at now, returning how many.
+	Purge(ctx context.Context, now time.Time) (int64, error)
+}
+
+type entry struct {
+	Record
+	expires time.Time
+}
+
+// MemoryStore keeps records in process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry)}
+}
+
+func (m *MemoryStore) Begin(ctx context.Context, key, fingerprint string, now, until time.Time) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		return e.Record, false, nil
+	}
+	e := &entry{Record: Record{Fingerprint: fingerprint}, expires: until}
+	m.entries[key] = e
+	return e.Record, true, nil
+}
+
+func (m *MemoryStore) Complete(ctx context.Context, key string, res Response, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		e.Response = &res
+		e.expires = expires
+	}
+	return nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok && e.Response == nil {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Purge(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, key)
+			n++
+		}
+	}
+	return n, nil
+}
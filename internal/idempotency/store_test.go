@@ -0,0 +1,48 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	rec, created, err := store.Begin(ctx, "k", "fp", now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, Record{Fingerprint: "fp"}, rec)
+
+	// in flight
+	rec, created, _ = store.Begin(ctx, "k", "fp2", now, now.Add(time.Minute))
+	assert.False(t, created)
+	assert.Equal(t, Record{Fingerprint: "fp"}, rec)
+
+	res := Response{Status: http.StatusCreated, Header: http.Header{"Location": {"/x"}}, Body: []byte("{}")}
+	assert.NoError(t, store.Complete(ctx, "k", res, now.Add(time.Hour)))
+	// a completed record is not released
+	assert.NoError(t, store.Release(ctx, "k"))
+	rec, created, _ = store.Begin(ctx, "k", "fp", now.Add(30*time.Minute), now.Add(31*time.Minute))
+	assert.False(t, created)
+	assert.Equal(t, &res, rec.Response)
+
+	// expired records are claimed anew and purged
+	_, created, _ = store.Begin(ctx, "k", "fp", now.Add(time.Hour), now.Add(61*time.Minute))
+	assert.True(t, created)
+	n, err := store.Purge(ctx, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	// released claims can be retried
+	_, _, _ = store.Begin(ctx, "r", "fp", now, now.Add(time.Minute))
+	assert.NoError(t, store.Release(ctx, "r"))
+	_, created, _ = store.Begin(ctx, "r", "fp", now, now.Add(time.Minute))
+	assert.True(t, created)
+}
@@ -0,0 +1,150 @@
+// Package migrate applies the SQL migrations embedded from migrations/ to the
+// dvdrental database.
+//
+// Migrations are named <version>_<description>.sql and applied in version
+// order, each in its own transaction, and recorded in schema_migrations so
+// they run once. An advisory lock keeps concurrently starting instances from
+// applying the same migration twice.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// lockID is the pg_advisory_lock key held while migrating.
+const lockID = 7_201_508
+
+const (
+	SQL_CREATE_TABLE = `CREATE TABLE IF NOT EXISTS schema_migrations (version text PRIMARY KEY, applied_at timestamptz NOT NULL DEFAULT now())`
+	SQL_APPLIED      = `SELECT version FROM schema_migrations`
+	SQL_RECORD       = `INSERT INTO schema_migrations (version) VALUES ($1)`
+	SQL_LOCK         = `SELECT pg_advisory_lock($1)`
+	SQL_UNLOCK       = `SELECT pg_advisory_unlock($1)`
+)
+
+// Migration is a single embedded SQL file.
+type Migration struct {
+	Version string
+	Name    string
+	SQL     string
+}
+
+// List returns the embedded migrations in the order they are applied.
+func List() ([]Migration, error) {
+	return list(migrations)
+}
+
+func list(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var ms []Migration
+	for _, file := range files {
+		name := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+		parts := strings.SplitN(name, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migration %s: want <version>_<description>.sql", file)
+		}
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, Migration{Version: parts[0], Name: name, SQL: string(body)})
+	}
+	return ms, nil
+}
+
+// Up applies every migration that has not been applied yet.
+func Up(ctx context.Context, db *sql.DB, logger *zap.Logger) error {
+	ms, err := List()
+	if err != nil {
+		return err
+	}
+	return up(ctx, db, logger, ms)
+}
+
+func up(ctx context.Context, db *sql.DB, logger *zap.Logger, ms []Migration) error {
+	// the advisory lock belongs to a session, so hold one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, SQL_LOCK, lockID); err != nil {
+		return fmt.Errorf("locking migrations: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), SQL_UNLOCK, lockID)
+	}()
+
+	if _, err := conn.ExecContext(ctx, SQL_CREATE_TABLE); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range ms {
+		if applied[m.Version] {
+			continue
+		}
+		if err := apply(ctx, conn, m); err != nil {
+			return fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+		logger.Info("Applied migration", zap.String("migration", m.Name))
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, SQL_APPLIED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func apply(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, SQL_RECORD, m.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	ms, err := List()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ms)
+	assert.Equal(t, "0001", ms[0].Version)
+	assert.Equal(t, "0001_catalog_notify", ms[0].Name)
+
+	_, err = list(fstest.MapFS{"migrations/nameless.sql": {Data: []byte("SELECT 1")}})
+	assert.Error(t, err)
+}
+
+func TestUpAppliesPendingMigrations(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "an error '%s' was not expected when opening a stub database connection")
+	defer db.Close()
+
+	ms, err := list(fstest.MapFS{
+		"migrations/0002_second.sql": {Data: []byte("CREATE TABLE second ()")},
+		"migrations/0001_first.sql":  {Data: []byte("CREATE TABLE first ()")},
+		"migrations/0003_third.sql":  {Data: []byte("CREATE TABLE third ()")},
+	})
+	assert.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(SQL_LOCK)).WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(SQL_CREATE_TABLE)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(SQL_APPLIED)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("0001"))
+	for _, name := range []string{"0002", "0003"} {
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(SQL_RECORD)).WithArgs(name).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec(regexp.QuoteMeta(SQL_UNLOCK)).WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, up(context.Background(), db, zap.NewNop(), ms))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+-- Publish catalog changes on the catalog_changes channel so API instances can
+-- evict cached films. The payload is JSON: {"table", "op", "film_id", "store_id"}.
+
+CREATE OR REPLACE FUNCTION public.notify_catalog_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    rec record;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+
+    PERFORM pg_notify('catalog_changes', json_build_object(
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'film_id', rec.film_id,
+        'store_id', (to_jsonb(rec)->>'store_id')::integer
+    )::text);
+
+    -- a row moved to another film changes the old film too
+    IF TG_OP = 'UPDATE' AND OLD.film_id IS DISTINCT FROM NEW.film_id THEN
+        PERFORM pg_notify('catalog_changes', json_build_object(
+            'table', TG_TABLE_NAME,
+            'op', TG_OP,
+            'film_id', OLD.film_id,
+            'store_id', (to_jsonb(OLD)->>'store_id')::integer
+        )::text);
+    END IF;
+
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS notify_catalog_change ON public.film;
+CREATE TRIGGER notify_catalog_change AFTER INSERT OR UPDATE OR DELETE ON public.film FOR EACH ROW EXECUTE PROCEDURE public.notify_catalog_change();
+
+DROP TRIGGER IF EXISTS notify_catalog_change ON public.film_category;
+CREATE TRIGGER notify_catalog_change AFTER INSERT OR UPDATE OR DELETE ON public.film_category FOR EACH ROW EXECUTE PROCEDURE public.notify_catalog_change();
+
+DROP TRIGGER IF EXISTS notify_catalog_change ON public.film_actor;
+CREATE TRIGGER notify_catalog_change AFTER INSERT OR UPDATE OR DELETE ON public.film_actor FOR EACH ROW EXECUTE PROCEDURE public.notify_catalog_change();
+
+DROP TRIGGER IF EXISTS notify_catalog_change ON public.inventory;
+CREATE TRIGGER notify_catalog_change AFTER INSERT OR UPDATE OR DELETE ON public.inventory FOR EACH ROW EXECUTE PROCEDURE public.notify_catalog_change();
@@ -0,0 +1,31 @@
+-- Publish rentals and returns on the catalog_changes channel for the event
+-- stream. The payload matches 0001_catalog_notify with the rented copy added:
+-- {"table": "rental", "op", "film_id", "store_id", "inventory_id"}. A rental
+-- is an INSERT, a return the UPDATE that sets return_date.
+
+CREATE OR REPLACE FUNCTION public.notify_rental_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    inv record;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NOT (OLD.return_date IS NULL AND NEW.return_date IS NOT NULL) THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT film_id, store_id INTO inv FROM public.inventory WHERE inventory_id = NEW.inventory_id;
+
+    PERFORM pg_notify('catalog_changes', json_build_object(
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'film_id', inv.film_id,
+        'store_id', inv.store_id,
+        'inventory_id', NEW.inventory_id
+    )::text);
+
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS notify_rental_change ON public.rental;
+CREATE TRIGGER notify_rental_change AFTER INSERT OR UPDATE OF return_date ON public.rental FOR EACH ROW EXECUTE PROCEDURE public.notify_rental_change();
@@ -0,0 +1,11 @@
+-- Customer comments on films, see internal/comments.
+
+CREATE TABLE IF NOT EXISTS public.film_comment (
+    comment_id serial PRIMARY KEY,
+    film_id smallint NOT NULL REFERENCES public.film (film_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    author text NOT NULL,
+    body text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_film_comment_film_id ON public.film_comment (film_id, comment_id);
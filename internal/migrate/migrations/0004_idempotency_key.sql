@@ -0,0 +1,14 @@
+-- Responses to POST requests by Idempotency-Key, see internal/idempotency.
+-- The key is the principal's and the client's key; status, header and body
+-- are NULL while the first request is in flight.
+
+CREATE TABLE IF NOT EXISTS public.idempotency_key (
+    key text PRIMARY KEY,
+    fingerprint text NOT NULL,
+    status integer,
+    header jsonb,
+    body bytea,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_key_expires_at ON public.idempotency_key (expires_at);
@@ -0,0 +1,133 @@
+// Package openapi describes HTTP APIs as OpenAPI 3.1 documents.
+//
+// Only the parts of the specification the rx API uses are modelled. Schemas
+// of request and response bodies are derived from the Go types that are
+// encoded, see SchemaOf, so that they cannot drift from the code.
+package openapi
+
+import "strings"
+
+// Version is the OpenAPI version documents are written in.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to their operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	// Security overrides the document's requirements, an empty list makes
+	// the operation public.
+	Security *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+	// Style and Explode describe how arrays are serialized, e.g. "form" and
+	// false for comma separated values.
+	Style   string `json:"style,omitempty"`
+	Explode *bool  `json:"explode,omitempty"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Response is a response or, when Ref is set, a reference to one in the
+// components.
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	Responses       map[string]Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to the scopes required.
+type SecurityRequirement map[string][]string
+
+// Operation returns the operation for method on path, nil if there is none.
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Add sets the operation for method on path.
+func (d *Document) Add(method, path string, op *Operation) {
+	if d.Paths == nil {
+		d.Paths = make(map[string]PathItem)
+	}
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(PathItem)
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// JSON is the content of a JSON body of schema.
+func JSON(schema *Schema) map[string]MediaType {
+	return Content("application/json", schema)
+}
+
+// Content is the content of a body of the media type.
+func Content(mediaType string, schema *Schema) map[string]MediaType {
+	return map[string]MediaType{mediaType: {Schema: schema}}
+}
+
+// RefResponse refers to the response named name in the components.
+func RefResponse(name string) Response {
+	return Response{Ref: "#/components/responses/" + name}
+}
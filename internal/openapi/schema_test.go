@@ -0,0 +1,75 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type embedded struct {
+	ID int `json:"id"`
+}
+
+type example struct {
+	embedded
+	Name     string          `json:"name"`
+	Note     string          `json:"note,omitempty"`
+	Tags     []string        `json:"tags"`
+	Labels   map[string]int  `json:"labels,omitempty"`
+	At       time.Time       `json:"at"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Next     *example        `json:"-"`
+	Untagged bool
+	hidden   string
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Parallel()
+
+	s := SchemaOf(example{})
+	assert.Equal(t, "object", s.Type)
+	assert.Equal(t, []string{"id", "name", "tags", "at", "Untagged"}, s.Required)
+	assert.Len(t, s.Properties, 8)
+
+	tests := []struct {
+		property string
+		want     *Schema
+	}{
+		{"id", &Schema{Type: "integer"}},
+		{"name", &Schema{Type: "string"}},
+		{"note", &Schema{Type: "string"}},
+		{"tags", ArrayOf(&Schema{Type: "string"})},
+		{"labels", &Schema{Type: "object", AdditionalProperties: &Schema{Type: "integer"}}},
+		{"at", &Schema{Type: "string", Format: "date-time"}},
+		{"data", &Schema{}},
+		{"Untagged", &Schema{Type: "boolean"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, s.Properties[tt.property], tt.property)
+	}
+}
+
+func TestSchemaOfList(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, ArrayOf(&Schema{Type: "integer"}), SchemaOf([]*int{}))
+	assert.Equal(t, &Schema{Type: "string", Format: "byte"}, SchemaOf([]byte{}))
+}
+
+// seconds marshals itself as a string.
+type seconds int
+
+func (seconds) Schema() *Schema {
+	return &Schema{Type: "string", Pattern: `^\d+s$`}
+}
+
+func TestSchemaOfDescriber(t *testing.T) {
+	t.Parallel()
+	s := SchemaOf(struct {
+		Timeout  seconds  `json:"timeout"`
+		Interval *seconds `json:"interval,omitempty"`
+	}{})
+	assert.Equal(t, seconds(0).Schema(), s.Properties["timeout"])
+	assert.Equal(t, seconds(0).Schema(), s.Properties["interval"])
+}
@@ -0,0 +1,34 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SwaggerUI serves a Swagger UI page, loaded from a CDN, for the document at
+// specURL.
+func SwaggerUI(specURL string) http.HandlerFunc {
+	page := []byte(strings.Replace(swaggerUIPage, "{{spec}}", specURL, 1))
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(page)
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>rx API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.9.0/swagger-ui.css">
+  <script crossorigin src="https://unpkg.com/swagger-ui-dist@5.9.0/swagger-ui-bundle.js"></script>
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script>
+    // authorize with an API key or token to try out requests
+    window.ui = SwaggerUIBundle({ url: '{{spec}}', dom_id: '#swagger-ui', persistAuthorization: true });
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,329 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Invalid describes a value that does not match its schema.
+type Invalid struct {
+	// In is where the value is: path, query, header, body or response.
+	In string `json:"in"`
+	// Name is the parameter name, or a JSON pointer into a body.
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (i Invalid) Error() string {
+	if i.Name == "" {
+		return i.In + ": " + i.Reason
+	}
+	return fmt.Sprintf("%s %s: %s", i.In, i.Name, i.Reason)
+}
+
+// Validator checks requests and responses against operations, resolving
+// references to its components. It is safe for concurrent use.
+type Validator struct {
+	components Components
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func NewValidator(components Components) *Validator {
+	return &Validator{components: components, patterns: make(map[string]*regexp.Regexp)}
+}
+
+// Params checks the path, query and header parameters of op in r, reading
+// path parameters with pathParam. Empty values count as absent.
+func (v *Validator) Params(op *Operation, r *http.Request, pathParam func(name string) string) []Invalid {
+	var invalid []Invalid
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var raw string
+		switch p.In {
+		case "path":
+			raw = pathParam(p.Name)
+		case "query":
+			raw = query.Get(p.Name)
+		case "header":
+			raw = r.Header.Get(p.Name)
+		}
+		if raw == "" {
+			if p.Required {
+				invalid = append(invalid, Invalid{In: p.In, Name: p.Name, Reason: "is required"})
+			}
+			continue
+		}
+		for _, i := range v.param(p.Schema, raw) {
+			invalid = append(invalid, Invalid{In: p.In, Name: p.Name, Reason: i.Reason})
+		}
+	}
+	return invalid
+}
+
+// Body checks a request body against op. JSON bodies are checked against
+// their schema, others only for being of a declared media type.
+func (v *Validator) Body(op *Operation, contentType string, body []byte) []Invalid {
+	if op.RequestBody == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return []Invalid{{In: "body", Reason: "is required"}}
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return []Invalid{{In: "body", Reason: fmt.Sprintf("unsupported media type %q", contentType)}}
+	}
+	return v.content("body", mediaType, media.Schema, body)
+}
+
+// Response checks a response against op. Its status must be documented,
+// unless it is a server error, and a body must be of a documented media type
+// and, if JSON, match the schema.
+func (v *Validator) Response(op *Operation, status int, contentType string, body []byte) []Invalid {
+	res, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if status >= 500 {
+			return nil
+		}
+		return []Invalid{{In: "response", Reason: fmt.Sprintf("status %d is not documented", status)}}
+	}
+	if res.Ref != "" {
+		res = v.components.Responses[strings.TrimPrefix(res.Ref, "#/components/responses/")]
+	}
+	if len(body) == 0 || len(res.Content) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := res.Content[mediaType]
+	if !ok {
+		return []Invalid{{In: "response", Reason: fmt.Sprintf("media type %q is not documented for status %d", contentType, status)}}
+	}
+	return v.content("response", mediaType, media.Schema, body)
+}
+
+// isJSON reports whether mediaType is JSON, e.g. application/json or
+// application/problem+json.
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func (v *Validator) content(in, mediaType string, schema *Schema, body []byte) []Invalid {
+	if !isJSON(mediaType) || schema == nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return []Invalid{{In: in, Reason: "is not valid JSON"}}
+	}
+	invalid := v.Value(schema, value, "")
+	for i := range invalid {
+		invalid[i].In = in
+	}
+	return invalid
+}
+
+// param converts a parameter to the JSON value it stands for and checks it.
+// Arrays are comma separated.
+func (v *Validator) param(schema *Schema, raw string) []Invalid {
+	schema = v.resolve(schema)
+	var value interface{} = raw
+	switch schema.Type {
+	case "integer", "number":
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []Invalid{{Reason: "must be a boolean"}}
+		}
+		value = b
+	case "array":
+		items := strings.Split(raw, ",")
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			return []Invalid{{Reason: fmt.Sprintf("must have at most %d items", *schema.MaxItems)}}
+		}
+		var invalid []Invalid
+		for _, item := range items {
+			invalid = append(invalid, v.param(schema.Items, strings.TrimSpace(item))...)
+		}
+		return invalid
+	}
+	return v.Value(schema, value, "")
+}
+
+// Value checks a value decoded from JSON with UseNumber against schema.
+// Invalid values are named by JSON pointers from pointer.
+func (v *Validator) Value(schema *Schema, value interface{}, pointer string) []Invalid {
+	schema = v.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	invalid := func(format string, args ...interface{}) []Invalid {
+		return []Invalid{{Name: pointer, Reason: fmt.Sprintf(format, args...)}}
+	}
+	if len(schema.OneOf) > 0 {
+		matched := 0
+		for _, alternative := range schema.OneOf {
+			if len(v.Value(alternative, value, pointer)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return invalid("must match exactly one of %d schemas", len(schema.OneOf))
+		}
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("must be an object")
+		}
+		var errs []Invalid
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, Invalid{Name: pointer + "/" + escapePointer(name), Reason: "is required"})
+			}
+		}
+		for _, name := range sortedKeys(obj) {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			errs = append(errs, v.Value(property, obj[name], pointer+"/"+escapePointer(name))...)
+		}
+		return errs
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return invalid("must be an array")
+		}
+		if schema.MaxItems != nil && len(list) > *schema.MaxItems {
+			return invalid("must have at most %d items", *schema.MaxItems)
+		}
+		var errs []Invalid
+		for i, item := range list {
+			errs = append(errs, v.Value(schema.Items, item, pointer+"/"+strconv.Itoa(i))...)
+		}
+		return errs
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return invalid("must be a string")
+		}
+		n := utf8.RuneCountInString(s)
+		if schema.MinLength != nil && n < *schema.MinLength {
+			return invalid("must be at least %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && n > *schema.MaxLength {
+			return invalid("must be at most %d characters", *schema.MaxLength)
+		}
+		if schema.Pattern != "" && !v.pattern(schema.Pattern).MatchString(s) {
+			return invalid("must match %s", schema.Pattern)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return invalid("must be an RFC 3339 date-time")
+			}
+		}
+	case "integer", "number":
+		kind := "a number"
+		if schema.Type == "integer" {
+			kind = "an integer"
+		}
+		n, ok := value.(json.Number)
+		if !ok {
+			return invalid("must be %s", kind)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return invalid("must be %s", kind)
+		}
+		if _, err := n.Int64(); err != nil && schema.Type == "integer" {
+			return invalid("must be %s", kind)
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return invalid("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return invalid("must be at most %v", *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("must be a boolean")
+		}
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		return invalid("must be one of %s", enumString(schema.Enum))
+	}
+	return nil
+}
+
+// resolve follows a reference to the components' schemas.
+func (v *Validator) resolve(schema *Schema) *Schema {
+	if schema != nil && schema.Ref != "" {
+		return v.components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (v *Validator) pattern(expr string) *regexp.Regexp {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	re, ok := v.patterns[expr]
+	if !ok {
+		re = regexp.MustCompile(expr)
+		v.patterns[expr] = re
+	}
+	return re
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumString(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		values[i] = fmt.Sprint(e)
+	}
+	return strings.Join(values, ", ")
+}
+
+// escapePointer escapes a JSON pointer reference token (RFC 6901).
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
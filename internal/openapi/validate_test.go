@@ -0,0 +1,150 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ptrFloat(f float64) *float64 { return &f }
+func ptrInt(i int) *int           { return &i }
+
+var testComponents = Components{
+	Schemas: map[string]*Schema{
+		"Item": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"id":   {Type: "integer", Minimum: ptrFloat(1)},
+				"name": {Type: "string", MinLength: ptrInt(1), MaxLength: ptrInt(5)},
+				"tags": ArrayOf(&Schema{Type: "string", Pattern: "^[a-z]+$"}),
+				"at":   {Type: "string", Format: "date-time"},
+			},
+			Required: []string{"id", "name"},
+		},
+	},
+	Responses: map[string]Response{
+		"401": {Description: "Unauthorized", Content: Content("application/problem+json", &Schema{Type: "object"})},
+	},
+}
+
+var testOp = &Operation{
+	Parameters: []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer"}},
+		{Name: "kind", In: "query", Schema: &Schema{Type: "string", Enum: []interface{}{"a", "b"}}},
+		{Name: "ids", In: "query", Schema: &Schema{Type: "array", Items: &Schema{Type: "integer"}, MaxItems: ptrInt(3)}},
+		{Name: "flag", In: "query", Schema: &Schema{Type: "boolean"}},
+		{Name: "X-Limit", In: "header", Schema: &Schema{Type: "integer", Maximum: ptrFloat(10)}},
+	},
+	RequestBody: &RequestBody{Required: true, Content: JSON(Ref("Item"))},
+	Responses: map[string]Response{
+		"200": {Content: JSON(ArrayOf(Ref("Item")))},
+		"204": {},
+		"401": RefResponse("401"),
+	},
+}
+
+func TestValidatorParams(t *testing.T) {
+	t.Parallel()
+	v := NewValidator(testComponents)
+
+	tests := []struct {
+		name   string
+		url    string
+		id     string
+		header string
+		want   []Invalid
+	}{
+		{name: "valid", url: "/?kind=a&ids=1,2&flag=true", id: "1", header: "10"},
+		{name: "empty query counts as absent", url: "/?kind=", id: "1"},
+		{name: "too many items", url: "/?ids=1,2,3,4", id: "1", want: []Invalid{{In: "query", Name: "ids", Reason: "must have at most 3 items"}}},
+		{name: "missing path", url: "/", want: []Invalid{{In: "path", Name: "id", Reason: "is required"}}},
+		{name: "every invalid parameter", url: "/?kind=c&ids=1,x&flag=maybe", id: "one", header: "11", want: []Invalid{
+			{In: "path", Name: "id", Reason: "must be an integer"},
+			{In: "query", Name: "kind", Reason: "must be one of a, b"},
+			{In: "query", Name: "ids", Reason: "must be an integer"},
+			{In: "query", Name: "flag", Reason: "must be a boolean"},
+			{In: "header", Name: "X-Limit", Reason: "must be at most 10"},
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Limit", tt.header)
+			}
+			got := v.Params(testOp, r, func(string) string { return tt.id })
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidatorBody(t *testing.T) {
+	t.Parallel()
+	v := NewValidator(testComponents)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []Invalid
+	}{
+		{name: "valid", body: `{"id": 1, "name": "x", "tags": ["a"], "at": "2006-01-02T15:04:05Z"}`},
+		{name: "missing", body: ` `, want: []Invalid{{In: "body", Reason: "is required"}}},
+		{name: "not JSON", body: `{`, want: []Invalid{{In: "body", Reason: "is not valid JSON"}}},
+		{name: "media type", contentType: "text/plain", body: `x`, want: []Invalid{{In: "body", Reason: `unsupported media type "text/plain"`}}},
+		{name: "not an object", body: `[]`, want: []Invalid{{In: "body", Reason: "must be an object"}}},
+		{name: "every invalid field", body: `{"id": 1.5, "name": "toolong", "tags": ["a", "B", 3], "at": "yesterday"}`, want: []Invalid{
+			{In: "body", Name: "/at", Reason: "must be an RFC 3339 date-time"},
+			{In: "body", Name: "/id", Reason: "must be an integer"},
+			{In: "body", Name: "/name", Reason: "must be at most 5 characters"},
+			{In: "body", Name: "/tags/1", Reason: "must match ^[a-z]+$"},
+			{In: "body", Name: "/tags/2", Reason: "must be a string"},
+		}},
+		{name: "required", body: `{"id": 0}`, want: []Invalid{
+			{In: "body", Name: "/name", Reason: "is required"},
+			{In: "body", Name: "/id", Reason: "must be at least 1"},
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json; charset=utf-8"
+			}
+			assert.Equal(t, tt.want, v.Body(testOp, contentType, []byte(tt.body)))
+		})
+	}
+}
+
+func TestValidatorResponse(t *testing.T) {
+	t.Parallel()
+	v := NewValidator(testComponents)
+
+	assert.Nil(t, v.Response(testOp, 200, "application/json", []byte(`[{"id": 1, "name": "x"}]`)))
+	assert.Nil(t, v.Response(testOp, 204, "", nil))
+	assert.Nil(t, v.Response(testOp, 401, "application/problem+json", []byte(`{}`)))
+	assert.Nil(t, v.Response(testOp, 503, "text/plain", []byte(`down`)), "server errors need not be documented")
+
+	assert.Equal(t, []Invalid{{In: "response", Reason: "must be an array"}},
+		v.Response(testOp, 200, "application/json", []byte(`null`)))
+	assert.Equal(t, []Invalid{{In: "response", Reason: "status 404 is not documented"}},
+		v.Response(testOp, 404, "text/plain", []byte(`not found`)))
+	assert.Equal(t, []Invalid{{In: "response", Reason: `media type "text/plain" is not documented for status 401`}},
+		v.Response(testOp, 401, "text/plain", []byte(`no`)))
+}
+
+func TestValidatorOneOf(t *testing.T) {
+	t.Parallel()
+	v := NewValidator(testComponents)
+	schema := &Schema{OneOf: []*Schema{ArrayOf(Ref("Item")), {Type: "integer"}}}
+
+	assert.Nil(t, v.Value(schema, []interface{}{map[string]interface{}{"id": json.Number("1"), "name": "x"}}, ""))
+	assert.Nil(t, v.Value(schema, json.Number("1"), ""))
+	assert.Equal(t, []Invalid{{Reason: "must match exactly one of 2 schemas"}}, v.Value(schema, "x", ""))
+}
@@ -0,0 +1,78 @@
+// Package problem writes RFC 7807 problem details responses.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// InvalidParams lists every invalid parameter of a 400.
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam is a parameter, or a field of the body, failing validation.
+type InvalidParam struct {
+	// In is where the parameter is: path, query, header or body.
+	In string `json:"in"`
+	// Name is the parameter name, or a JSON pointer into the body.
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// New returns a problem for status titled with the standard status text.
+func New(status int, detail string) Problem {
+	return Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Renderer sends problems in another shape than RFC 7807, e.g. the error
+// envelope of an API version.
+type Renderer func(w http.ResponseWriter, r *http.Request, p Problem)
+
+type rendererKey struct{}
+
+// WithRenderer returns a copy of ctx in which Write sends problems with
+// render, so that middleware shared between API versions answers in the
+// shape of each.
+func WithRenderer(ctx context.Context, render Renderer) context.Context {
+	return context.WithValue(ctx, rendererKey{}, render)
+}
+
+// Write sends p as the response, using the request path as the instance when
+// none is set.
+func Write(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if render, ok := r.Context().Value(rendererKey{}).(Renderer); ok {
+		render(w, r, p)
+		return
+	}
+	res, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(res)
+}
+
+// Error is shorthand for Write(w, r, New(status, detail)).
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	Write(w, r, New(status, detail))
+}
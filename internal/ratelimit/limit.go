@@ -0,0 +1,93 @@
+// Package ratelimit throttles clients with token buckets.
+//
+// Each client gets a bucket per route group holding up to Limit.Requests
+// tokens, refilled at Requests per Period. A request takes one token and is
+// rejected with 429 when the bucket is empty.
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGroup is the limit used by route groups without their own.
+const DefaultGroup = "default"
+
+// Limit allows Requests per Period, with bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads "<requests>/<period>", e.g. "60/1m".
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("rate limit %q: want <requests>/<period>", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: requests must be a positive integer", s)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: period must be a positive duration", s)
+	}
+	return Limit{Requests: n, Period: period}, nil
+}
+
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Period.String()
+}
+
+// Limits maps route groups to their limit. It is read from config as a comma
+// separated list of <group>=<requests>/<period>, e.g.
+// "default=120/1m,films=60/1m".
+type Limits map[string]Limit
+
+func (ls *Limits) UnmarshalText(text []byte) error {
+	limits := Limits{}
+	for _, entry := range strings.Split(string(text), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("rate limit %q: want <group>=<requests>/<period>", entry)
+		}
+		limit, err := ParseLimit(parts[1])
+		if err != nil {
+			return err
+		}
+		limits[strings.TrimSpace(parts[0])] = limit
+	}
+	*ls = limits
+	return nil
+}
+
+func (ls Limits) String() string {
+	groups := make([]string, 0, len(ls))
+	for group := range ls {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	entries := make([]string, 0, len(ls))
+	for _, group := range groups {
+		entries = append(entries, group+"="+ls[group].String())
+	}
+	return strings.Join(entries, ",")
+}
+
+// For returns the limit for group, falling back to DefaultGroup. The bool is
+// false when neither is configured and the group is unlimited.
+func (ls Limits) For(group string) (Limit, bool) {
+	if l, ok := ls[group]; ok {
+		return l, true
+	}
+	l, ok := ls[DefaultGroup]
+	return l, ok
+}
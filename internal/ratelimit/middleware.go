@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/problem"
+	"go.uber.org/zap"
+)
+
+// TrustedProxies are the networks whose X-Forwarded-For headers are believed.
+// It is read from config as a comma separated list of CIDRs or IPs.
+type TrustedProxies []*net.IPNet
+
+func (tp *TrustedProxies) UnmarshalText(text []byte) error {
+	var nets TrustedProxies
+	for _, entry := range strings.Split(string(text), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return fmt.Errorf("trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, n)
+	}
+	*tp = nets
+	return nil
+}
+
+func (tp TrustedProxies) String() string {
+	entries := make([]string, 0, len(tp))
+	for _, n := range tp {
+		entries = append(entries, n.String())
+	}
+	return strings.Join(entries, ",")
+}
+
+func (tp TrustedProxies) contains(ip net.IP) bool {
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is only used
+// when the request came from a trusted proxy, and is read right to left so
+// the first untrusted hop wins; clients cannot spoof it by prepending entries.
+func ClientIP(r *http.Request, trusted TrustedProxies) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !trusted.contains(ip) {
+		return host
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		host = hop.String()
+		if !trusted.contains(hop) {
+			break
+		}
+	}
+	return host
+}
+
+// Key identifies the client a request counts against: its principal when it
+// authenticated, its IP otherwise.
+func Key(r *http.Request, trusted TrustedProxies) string {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Method != "none" {
+		return p.Method + ":" + p.ID
+	}
+	return "ip:" + ClientIP(r, trusted)
+}
+
+// Config is read on every request so limits can be reloaded.
+type Config struct {
+	Limits         Limits
+	TrustedProxies TrustedProxies
+}
+
+// Middleware limits each client of the route group to the group's limit,
+// setting the RateLimit-* headers on every response and answering 429 with
+// Retry-After when the bucket is empty. Store errors let the request through.
+func Middleware(group string, store Store, config func() Config, logger *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := config()
+			limit, ok := cfg.Limits.For(group)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := group + "|" + Key(r, cfg.TrustedProxies)
+			res, err := store.Take(r.Context(), key, limit, time.Now())
+			if err != nil {
+				logger.Error("Rate limit store failed, allowing request", zap.String("group", group), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				problem.Error(w, r, http.StatusTooManyRequests, "rate limit of "+limit.String()+" exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func trusted(t *testing.T, s string) TrustedProxies {
+	t.Helper()
+	var tp TrustedProxies
+	if err := tp.UnmarshalText([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	return tp
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	proxies := trusted(t, "10.0.0.0/8, 192.168.1.1")
+	tests := []struct {
+		name     string
+		remote   string
+		xff      string
+		expected string
+	}{
+		{name: "direct", remote: "203.0.113.7:1234", expected: "203.0.113.7"},
+		{name: "untrusted proxy ignored", remote: "203.0.113.7:1234", xff: "198.51.100.1", expected: "203.0.113.7"},
+		{name: "trusted proxy", remote: "10.1.2.3:1234", xff: "198.51.100.1", expected: "198.51.100.1"},
+		{name: "proxy chain", remote: "10.1.2.3:1234", xff: "198.51.100.1, 192.168.1.1", expected: "198.51.100.1"},
+		{name: "spoofed prefix", remote: "10.1.2.3:1234", xff: "1.1.1.1, 198.51.100.1", expected: "198.51.100.1"},
+		{name: "no header", remote: "10.1.2.3:1234", expected: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			assert.Equal(t, tt.expected, ClientIP(req, proxies))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Limits: Limits{"films": {Requests: 1, Period: time.Minute}}}
+	handler := Middleware("films", NewMemoryStore(), func() Config { return cfg }, zap.NewNop())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(principal *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/films", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", rr.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=60", rr.Header().Get("RateLimit-Policy"))
+
+	rr = do(nil)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	// an API key from the same address has its own bucket
+	rr = do(&auth.Principal{ID: "kiosk", Method: "api_key"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// unconfigured groups are not limited
+	cfg = Config{}
+	rr = do(nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Result is the state of a bucket after a Take.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed, zero
+	// when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets. Implementations must be safe for concurrent use;
+// an external store lets several instances share limits.
+type Store interface {
+	// Take refills the bucket for key for the time elapsed since it was last
+	// used and removes one token if there is one.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// MemoryStore keeps buckets in process. Full buckets are dropped on a sweep
+// every sweepEvery so idle clients do not accumulate.
+type MemoryStore struct {
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	lastSweep  time.Time
+	sweepEvery time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:    make(map[string]*bucket),
+		sweepEvery: time.Minute,
+	}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds() // tokens per second
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		m.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.last = now
+	}
+	b.period = limit.Period
+	// a lowered limit takes effect immediately
+	b.tokens = math.Min(capacity, b.tokens)
+
+	res := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	return res, nil
+}
+
+// sweep drops buckets that have had time to refill completely. Callers must
+// hold mu.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < m.sweepEvery {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= b.period {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: 2 * time.Second}
+	start := time.Date(2023, 8, 24, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	res, _ := store.Take(ctx, "a", limit, start)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, _ = store.Take(ctx, "a", limit, start)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 2*time.Second, res.Reset)
+
+	res, _ = store.Take(ctx, "a", limit, start)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	// other keys have their own bucket
+	res, _ = store.Take(ctx, "b", limit, start)
+	assert.True(t, res.Allowed)
+
+	// one token is back after a second
+	res, _ = store.Take(ctx, "a", limit, start.Add(time.Second))
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "a", limit, start.Add(time.Second))
+	assert.False(t, res.Allowed)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Period: time.Second}
+	start := time.Date(2023, 8, 24, 12, 0, 0, 0, time.UTC)
+	_, _ = store.Take(context.Background(), "idle", limit, start)
+	_, _ = store.Take(context.Background(), "busy", limit, start.Add(2*time.Minute))
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestLimitsUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	var limits Limits
+	assert.NoError(t, limits.UnmarshalText([]byte("default=120/1m, films=10/1s")))
+	assert.Equal(t, Limits{
+		"default": {Requests: 120, Period: time.Minute},
+		"films":   {Requests: 10, Period: time.Second},
+	}, limits)
+	assert.Equal(t, "default=120/1m0s,films=10/1s", limits.String())
+
+	l, ok := limits.For("comments")
+	assert.True(t, ok)
+	assert.Equal(t, 120, l.Requests)
+
+	assert.Error(t, limits.UnmarshalText([]byte("films=10")))
+	assert.Error(t, limits.UnmarshalText([]byte("films=0/1m")))
+	assert.Error(t, limits.UnmarshalText([]byte("films=10/soon")))
+	assert.Error(t, limits.UnmarshalText([]byte("=10/1m")))
+}
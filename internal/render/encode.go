@@ -0,0 +1,197 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrUnsupported is returned when a value cannot be rendered in a media
+// type, e.g. CSV of something other than structs.
+var ErrUnsupported = errors.New("render: value cannot be rendered in this media type")
+
+// Encode renders v, a struct or a slice of structs, as mediaType. JSON is
+// indented with tabs. NDJSON has a line per element. CSV has a header row of
+// the JSON field names and a row per element. XML elements are named by the
+// xml tags of the struct, the root after its type, e.g. <films><film>.
+//
+// Responses projected to some fields name them, so that CSV has only their
+// columns; the other formats rely on unset fields being omitted.
+func Encode(mediaType string, v interface{}, fields ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		stream, err := NewStream(&buf, mediaType, reflect.Zero(elemType(v)).Interface(), fields...)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := stream.Encode(rv.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		if err := stream.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	switch mediaType {
+	case JSON:
+		return json.MarshalIndent(v, "", "\t")
+	case NDJSON:
+		err := json.NewEncoder(&buf).Encode(v)
+		return buf.Bytes(), err
+	case CSV:
+		if elemType(v).Kind() != reflect.Struct {
+			return nil, ErrUnsupported
+		}
+		w := csv.NewWriter(&buf)
+		cols := columns(elemType(v), fields)
+		_ = w.Write(header(cols))
+		_ = w.Write(row(cols, rv))
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	case XML:
+		if elemType(v).Kind() != reflect.Struct {
+			return nil, ErrUnsupported
+		}
+		buf.WriteString(xml.Header)
+		enc := xml.NewEncoder(&buf)
+		enc.Indent("", "\t")
+		if err := enc.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: elementName(elemType(v))}}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, ErrUnsupported
+}
+
+// ContentType is the Content-Type header of mediaType.
+func ContentType(mediaType string) string {
+	if mediaType == CSV {
+		return CSV + "; charset=utf-8; header=present"
+	}
+	return mediaType
+}
+
+// elemType is the struct type of v or of its elements.
+func elemType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// column is a CSV column: a field of a struct.
+type column struct {
+	name  string
+	index []int
+}
+
+// columns are the scalar fields of t named like their JSON encoding, only
+// those among fields unless it is empty.
+func columns(t reflect.Type, fields []string) []column {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !isScalar(f.Type) || (len(fields) > 0 && !contains(fields, name)) {
+			continue
+		}
+		cols = append(cols, column{name: name, index: f.Index})
+	}
+	return cols
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return t == timeType
+}
+
+func cell(v reflect.Value) string {
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func header(cols []column) []string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	return names
+}
+
+func row(cols []column, v reflect.Value) []string {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	cells := make([]string, len(cols))
+	for i, c := range cols {
+		cells[i] = cell(v.FieldByIndex(c.index))
+	}
+	return cells
+}
+
+// elementName is the XML element name of a type: its name in snake case.
+func elementName(t reflect.Type) string {
+	var b strings.Builder
+	for i, r := range t.Name() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
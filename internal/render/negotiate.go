@@ -0,0 +1,120 @@
+// Package render encodes API responses in the media type a client asks for:
+// JSON, NDJSON, CSV or XML.
+package render
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types responses can be rendered as.
+const (
+	JSON   = "application/json"
+	NDJSON = "application/x-ndjson"
+	CSV    = "text/csv"
+	XML    = "application/xml"
+)
+
+// MediaTypes are the supported media types, the first one being the default.
+var MediaTypes = []string{JSON, NDJSON, CSV, XML}
+
+// Formats maps the values of the format query parameter to media types.
+var Formats = map[string]string{
+	"json":   JSON,
+	"ndjson": NDJSON,
+	"csv":    CSV,
+	"xml":    XML,
+}
+
+// FormatNames are the keys of Formats in the order of MediaTypes.
+var FormatNames = []string{"json", "ndjson", "csv", "xml"}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+	order        int
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	return (m.typ == "*" || m.typ == typ) && (m.subtype == "*" || m.subtype == subtype)
+}
+
+// specificity ranks exact types over type/* over */*.
+func (m mediaRange) specificity() int {
+	switch {
+	case m.typ == "*":
+		return 0
+	case m.subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for i, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+				q = f
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q, order: i})
+	}
+	// the most specific range matching an offer decides its quality
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// Negotiate picks the media type of the response to r among offers: the
+// format query parameter wins, then the offer the Accept header prefers,
+// ties going to the earlier offer. It returns false when the client accepts
+// none of the offers. Without an Accept header the first offer is picked.
+func Negotiate(r *http.Request, offers ...string) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		mediaType, ok := Formats[strings.ToLower(format)]
+		if !ok {
+			return "", false
+		}
+		for _, offer := range offers {
+			if offer == mediaType {
+				return offer, true
+			}
+		}
+		return "", false
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		for _, m := range ranges {
+			if !m.matches(offer) {
+				continue
+			}
+			if m.q > bestQ {
+				best, bestQ = offer, m.q
+			}
+			break
+		}
+	}
+	return best, best != ""
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		url    string
+		accept string
+		offers []string
+		want   string
+	}{
+		{name: "no accept", url: "/", want: JSON},
+		{name: "exact", url: "/", accept: "text/csv", want: CSV},
+		{name: "wildcard", url: "/", accept: "*/*", want: JSON},
+		{name: "type wildcard", url: "/", accept: "text/*", want: CSV},
+		{name: "quality", url: "/", accept: "application/xml;q=0.5, application/x-ndjson", want: NDJSON},
+		{name: "browser", url: "/", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: XML},
+		{name: "specific range decides", url: "/", accept: "*/*, application/json;q=0", want: NDJSON},
+		{name: "ties go to the first offer", url: "/", accept: "application/xml, application/json", want: JSON},
+		{name: "format wins", url: "/?format=CSV", accept: "application/json", want: CSV},
+		{name: "nothing acceptable", url: "/", accept: "text/html"},
+		{name: "unknown format", url: "/?format=yaml"},
+		{name: "format not offered", url: "/?format=csv", offers: []string{JSON}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			offers := tt.offers
+			if offers == nil {
+				offers = MediaTypes
+			}
+			got, ok := Negotiate(r, offers...)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want != "", ok)
+		})
+	}
+}
+
+type testFilm struct {
+	FilmID    int       `json:"film_id" xml:"film_id"`
+	Title     string    `json:"title,omitempty" xml:"title,omitempty"`
+	Tags      []string  `json:"tags,omitempty" xml:"tag"`
+	Added     time.Time `json:"added" xml:"added"`
+	Internal  string    `json:"-" xml:"-"`
+	unexposed int
+}
+
+var testFilms = []testFilm{
+	{FilmID: 1, Title: "ACADEMY, DINOSAUR", Tags: []string{"a"}, Added: time.Date(2006, 2, 15, 5, 3, 42, 0, time.UTC)},
+	{FilmID: 2, Internal: "x"},
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		mediaType string
+		v         interface{}
+		fields    []string
+		want      string
+	}{
+		{JSON, testFilms[1], nil, "{\n\t\"film_id\": 2,\n\t\"added\": \"0001-01-01T00:00:00Z\"\n}"},
+		{NDJSON, testFilms, nil, `{"film_id":1,"title":"ACADEMY, DINOSAUR","tags":["a"],"added":"2006-02-15T05:03:42Z"}
+{"film_id":2,"added":"0001-01-01T00:00:00Z"}
+`},
+		{CSV, testFilms, nil, `film_id,title,added
+1,"ACADEMY, DINOSAUR",2006-02-15T05:03:42Z
+2,,
+`},
+		{CSV, []testFilm{}, nil, "film_id,title,added\n"},
+		{CSV, testFilms, []string{"film_id", "added"}, `film_id,added
+1,2006-02-15T05:03:42Z
+2,
+`},
+		{XML, testFilms, nil, `<?xml version="1.0" encoding="UTF-8"?>
+<test_films>
+	<test_film>
+		<film_id>1</film_id>
+		<title>ACADEMY, DINOSAUR</title>
+		<tag>a</tag>
+		<added>2006-02-15T05:03:42Z</added>
+	</test_film>
+	<test_film>
+		<film_id>2</film_id>
+		<added>0001-01-01T00:00:00Z</added>
+	</test_film>
+</test_films>`},
+		{XML, testFilms[1], nil, `<?xml version="1.0" encoding="UTF-8"?>
+<test_film>
+	<film_id>2</film_id>
+	<added>0001-01-01T00:00:00Z</added>
+</test_film>`},
+	}
+	for _, tt := range tests {
+		got, err := Encode(tt.mediaType, tt.v, tt.fields...)
+		assert.NoError(t, err, tt.mediaType)
+		assert.Equal(t, tt.want, string(got), tt.mediaType)
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	t.Parallel()
+	_, err := Encode(CSV, map[string]int{"a": 1})
+	assert.Equal(t, ErrUnsupported, err)
+	_, err = Encode("text/html", testFilms)
+	assert.Equal(t, ErrUnsupported, err)
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+	for _, mediaType := range MediaTypes {
+		for _, list := range [][]testFilm{testFilms, {}} {
+			var buf bytes.Buffer
+			s, err := NewStream(&buf, mediaType, testFilm{})
+			assert.NoError(t, err, mediaType)
+			for i, f := range list {
+				assert.NoError(t, s.Encode(f), mediaType)
+				assert.Equal(t, i+1, s.Len())
+			}
+			if len(list) == 0 {
+				assert.Zero(t, buf.Len(), "%s: written before the first element", mediaType)
+			}
+			assert.NoError(t, s.Close(), mediaType)
+
+			want, err := Encode(mediaType, list)
+			assert.NoError(t, err, mediaType)
+			assert.Equal(t, string(want), buf.String(), mediaType)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"reflect"
+)
+
+// Stream encodes a collection an element at a time, producing the same
+// output as Encode of the whole slice. Nothing is written before the first
+// element or Close, so a failure before then can still be answered with an
+// error response.
+type Stream struct {
+	w         io.Writer
+	mediaType string
+	n         int
+
+	cols []column
+	csv  *csv.Writer
+	name string
+	xml  *xml.Encoder
+	buf  bytes.Buffer
+}
+
+// NewStream returns a stream writing elements like elem, a struct, as
+// mediaType to w. fields restrict CSV columns as they do for Encode.
+func NewStream(w io.Writer, mediaType string, elem interface{}, fields ...string) (*Stream, error) {
+	s := &Stream{w: w, mediaType: mediaType}
+	switch mediaType {
+	case JSON, NDJSON:
+	case CSV, XML:
+		t := elemType(elem)
+		if t.Kind() != reflect.Struct {
+			return nil, ErrUnsupported
+		}
+		s.cols = columns(t, fields)
+		s.name = elementName(t)
+	default:
+		return nil, ErrUnsupported
+	}
+	return s, nil
+}
+
+// Len is the number of elements encoded so far.
+func (s *Stream) Len() int {
+	return s.n
+}
+
+// start writes what precedes the first element.
+func (s *Stream) start() error {
+	switch s.mediaType {
+	case CSV:
+		s.csv = csv.NewWriter(s.w)
+		return s.csv.Write(header(s.cols))
+	case XML:
+		if _, err := io.WriteString(s.w, xml.Header); err != nil {
+			return err
+		}
+		s.xml = xml.NewEncoder(s.w)
+		s.xml.Indent("", "\t")
+		return s.xml.EncodeToken(xml.StartElement{Name: xml.Name{Local: s.name + "s"}})
+	}
+	return nil
+}
+
+// Encode writes the next element. Writes are buffered by the stream only as
+// much as the encoding needs: callers flush the underlying writer.
+func (s *Stream) Encode(v interface{}) error {
+	if s.n == 0 {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	s.n++
+
+	switch s.mediaType {
+	case JSON:
+		b, err := json.MarshalIndent(v, "\t", "\t")
+		if err != nil {
+			return err
+		}
+		sep := ",\n\t"
+		if s.n == 1 {
+			sep = "[\n\t"
+		}
+		s.buf.Reset()
+		s.buf.WriteString(sep)
+		s.buf.Write(b)
+		_, err = s.w.Write(s.buf.Bytes())
+		return err
+	case NDJSON:
+		return json.NewEncoder(s.w).Encode(v)
+	case CSV:
+		if err := s.csv.Write(row(s.cols, reflect.ValueOf(v))); err != nil {
+			return err
+		}
+		s.csv.Flush()
+		return s.csv.Error()
+	case XML:
+		if err := s.xml.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: s.name}}); err != nil {
+			return err
+		}
+		return s.xml.Flush()
+	}
+	return ErrUnsupported
+}
+
+// Close writes what follows the last element, the whole collection if it is
+// empty.
+func (s *Stream) Close() error {
+	if s.n == 0 {
+		if s.mediaType == JSON {
+			_, err := io.WriteString(s.w, "[]")
+			return err
+		}
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+
+	switch s.mediaType {
+	case JSON:
+		if s.n > 0 {
+			_, err := io.WriteString(s.w, "\n]")
+			return err
+		}
+	case CSV:
+		s.csv.Flush()
+		return s.csv.Error()
+	case XML:
+		if err := s.xml.EncodeToken(xml.EndElement{Name: xml.Name{Local: s.name + "s"}}); err != nil {
+			return err
+		}
+		return s.xml.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	rxv1 "github.com/dhaskew/rx/api/rx/v1"
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+)
+
+type commentService struct {
+	rxv1.UnimplementedCommentServiceServer
+	comments comments.CommentRepository
+	created  func(comments.Comment)
+}
+
+func commentMessage(c comments.Comment) *rxv1.Comment {
+	return &rxv1.Comment{
+		CommentId: int32(c.CommentID),
+		FilmId:    int32(c.FilmID),
+		Author:    c.Author,
+		Body:      c.Body,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+	}
+}
+
+func (s *commentService) ListComments(ctx context.Context, req *rxv1.ListCommentsRequest) (*rxv1.ListCommentsResponse, error) {
+	list, err := s.comments.GetByFilm(ctx, int(req.FilmId))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error getting comments")
+	}
+	res := &rxv1.ListCommentsResponse{Comments: make([]*rxv1.Comment, 0, len(list))}
+	for _, c := range list {
+		res.Comments = append(res.Comments, commentMessage(c))
+	}
+	return res, nil
+}
+
+func (s *commentService) CreateComment(ctx context.Context, req *rxv1.CreateCommentRequest) (*rxv1.Comment, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "credentials required")
+	}
+	comment := comments.Comment{FilmID: int(req.FilmId), Author: principal.ID, Body: req.Body}
+	if err := comment.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	comment, err := s.comments.Create(ctx, comment)
+	if err == comments.ErrFilmNotFound {
+		return nil, status.Error(codes.NotFound, "film not found")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "error creating comment")
+	}
+	s.created(comment)
+	return commentMessage(comment), nil
+}
@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	rxv1 "github.com/dhaskew/rx/api/rx/v1"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+type filmService struct {
+	rxv1.UnimplementedFilmServiceServer
+	films films.FilmRepository
+}
+
+func filmMessage(f films.Film) *rxv1.Film {
+	return &rxv1.Film{
+		FilmId:      int32(f.FilmID),
+		Title:       f.Title,
+		Description: f.Description,
+		ReleaseYear: int32(f.ReleaseYear),
+		Rating:      f.Rating,
+		Category:    f.Category,
+	}
+}
+
+// page returns the films of one page and the token of the next. Page tokens
+// are offsets, clients must treat them as opaque.
+func page(list []films.Film, size int32, token string) ([]*rxv1.Film, string, error) {
+	if size < 0 || size > maxPageSize {
+		return nil, "", status.Errorf(codes.InvalidArgument, "page_size must be between 0 and %d", maxPageSize)
+	}
+	if size == 0 {
+		size = defaultPageSize
+	}
+	offset := 0
+	if token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 {
+			return nil, "", status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		offset = n
+	}
+	if offset > len(list) {
+		offset = len(list)
+	}
+	end := offset + int(size)
+	next := strconv.Itoa(end)
+	if end >= len(list) {
+		end = len(list)
+		next = ""
+	}
+
+	messages := make([]*rxv1.Film, 0, end-offset)
+	for _, f := range list[offset:end] {
+		messages = append(messages, filmMessage(f))
+	}
+	return messages, next, nil
+}
+
+func (s *filmService) ListFilms(ctx context.Context, req *rxv1.ListFilmsRequest) (*rxv1.ListFilmsResponse, error) {
+	var list []films.Film
+	var err error
+	switch {
+	case req.Rating != "":
+		list, err = s.films.GetAllByRating(ctx, req.Rating)
+	case req.Category != "":
+		list, err = s.films.GetAllByCategory(ctx, req.Category)
+	default:
+		list, err = s.films.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error getting films")
+	}
+
+	messages, next, err := page(list, req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &rxv1.ListFilmsResponse{Films: messages, NextPageToken: next}, nil
+}
+
+func (s *filmService) GetFilm(ctx context.Context, req *rxv1.GetFilmRequest) (*rxv1.Film, error) {
+	film, err := s.films.GetByID(ctx, int(req.FilmId))
+	if err == films.ErrNotFound {
+		return nil, status.Error(codes.NotFound, "film not found")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "error getting film")
+	}
+	return filmMessage(film), nil
+}
+
+// SearchFilms filters the full, usually cached, list rather than querying:
+// the catalog is small.
+func (s *filmService) SearchFilms(ctx context.Context, req *rxv1.SearchFilmsRequest) (*rxv1.SearchFilmsResponse, error) {
+	query := strings.ToLower(strings.TrimSpace(req.Query))
+	if query == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+
+	all, err := s.films.GetAll(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error getting films")
+	}
+	var matches []films.Film
+	for _, f := range all {
+		if strings.Contains(strings.ToLower(f.Title), query) || strings.Contains(strings.ToLower(f.Description), query) {
+			matches = append(matches, f)
+		}
+	}
+
+	messages, next, err := page(matches, req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &rxv1.SearchFilmsResponse{Films: messages, NextPageToken: next}, nil
+}
@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/dhaskew/rx/internal/auth"
+)
+
+// methodScopes are the scopes each method requires, like the routes of the
+// REST API. Methods that are neither listed here nor in publicMethods are
+// denied, so that new ones are not served by accident.
+var methodScopes = map[string][]auth.Scope{
+	"/rx.v1.FilmService/ListFilms":        {auth.FilmsRead},
+	"/rx.v1.FilmService/GetFilm":          {auth.FilmsRead},
+	"/rx.v1.FilmService/SearchFilms":      {auth.FilmsRead},
+	"/rx.v1.CommentService/ListComments":  {auth.FilmsRead},
+	"/rx.v1.CommentService/CreateComment": {auth.CommentsWrite},
+}
+
+// publicMethods are open to everyone: health checks, which load balancers
+// make without credentials, and reflection.
+var publicMethods = map[string]bool{
+	"/grpc.health.v1.Health/Check":                                   true,
+	"/grpc.health.v1.Health/Watch":                                   true,
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+}
+
+// credentialsRequest presents the call's metadata as the headers of a
+// request, so that the HTTP authenticators can be reused: clients send the
+// same authorization or x-api-key as they would over HTTP.
+func credentialsRequest(ctx context.Context) *http.Request {
+	r := &http.Request{Header: http.Header{}}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, v := range values {
+			r.Header.Add(key, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
+
+// authenticate stores the caller's principal in ctx and checks it holds the
+// scopes of the method.
+func authenticate(ctx context.Context, authenticators []auth.Authenticator, method string) (context.Context, error) {
+	var authErr error
+	r := credentialsRequest(ctx)
+	for _, a := range authenticators {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, auth.ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			authErr = err
+		} else {
+			ctx = auth.WithPrincipal(ctx, p)
+		}
+		break
+	}
+
+	if publicMethods[method] {
+		return ctx, nil
+	}
+	scopes, ok := methodScopes[method]
+	if !ok {
+		return ctx, status.Error(codes.PermissionDenied, "method "+method+" is not open to clients")
+	}
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		if authErr != nil {
+			return ctx, status.Error(codes.Unauthenticated, authErr.Error())
+		}
+		return ctx, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	for _, scope := range scopes {
+		if !p.HasScope(scope) {
+			return ctx, status.Error(codes.PermissionDenied, "missing scope "+string(scope))
+		}
+	}
+	return ctx, nil
+}
+
+func unaryAuthenticate(authenticators []auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, authenticators, info.FullMethod)
+		recordPrincipal(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authenticatedStream carries the context with the principal to the handler.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamAuthenticate(authenticators []auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), authenticators, info.FullMethod)
+		recordPrincipal(ctx)
+		if err != nil {
+			return err
+		}
+		return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// logCall logs a finished call with the fields ZapRequestLogger uses for
+// HTTP requests.
+func logCall(l *zap.Logger, ctx context.Context, method string, start time.Time, err error) {
+	fields := []zap.Field{
+		zap.String("proto", "grpc"),
+		zap.String("method", method),
+		zap.Duration("lat", time.Since(start)),
+		zap.String("code", status.Code(err).String()),
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		fields = append(fields, zap.String("principal", p.ID), zap.String("authMethod", p.Method))
+	}
+	l.Info("Served", fields...)
+}
+
+// principalRecorder hands the principal found by authentication back to the
+// logger, which runs first.
+type principalRecorder struct {
+	ctx context.Context
+}
+
+func unaryLogger(l *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		rec := &principalRecorder{ctx: ctx}
+		res, err := handler(context.WithValue(ctx, principalRecorderKey{}, rec), req)
+		logCall(l, rec.ctx, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+func streamLogger(l *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		rec := &principalRecorder{ctx: ss.Context()}
+		err := handler(srv, authenticatedStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), principalRecorderKey{}, rec)})
+		logCall(l, rec.ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+type principalRecorderKey struct{}
+
+// recordPrincipal hands the authenticated context back to the logger.
+func recordPrincipal(ctx context.Context) {
+	if rec, ok := ctx.Value(principalRecorderKey{}).(*principalRecorder); ok {
+		rec.ctx = ctx
+	}
+}
+
+func unaryRecoverer(l *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				l.Error("Recovered from panic", zap.String("method", info.FullMethod), zap.Any("panic", rvr), zap.Stack("stack"))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func streamRecoverer(l *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				l.Error("Recovered from panic", zap.String("method", info.FullMethod), zap.Any("panic", rvr), zap.Stack("stack"))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// MethodStats counts the calls of one method.
+type MethodStats struct {
+	Calls uint64            `json:"calls"`
+	Codes map[string]uint64 `json:"codes"`
+	// Seconds is the total time spent handling calls.
+	Seconds float64 `json:"seconds"`
+}
+
+// Metrics counts calls per method and status code, published at /debug/vars
+// next to the HTTP counters.
+type Metrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{methods: make(map[string]*MethodStats)}
+}
+
+func (m *Metrics) record(method string, start time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.methods[method]
+	if !ok {
+		stats = &MethodStats{Codes: make(map[string]uint64)}
+		m.methods[method] = stats
+	}
+	stats.Calls++
+	stats.Codes[status.Code(err).String()]++
+	stats.Seconds += time.Since(start).Seconds()
+}
+
+// Snapshot copies the counters, keyed by full method name.
+func (m *Metrics) Snapshot() map[string]MethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]MethodStats, len(m.methods))
+	methods := make([]string, 0, len(m.methods))
+	for method := range m.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		stats := *m.methods[method]
+		stats.Codes = make(map[string]uint64, len(m.methods[method].Codes))
+		for code, n := range m.methods[method].Codes {
+			stats.Codes[code] = n
+		}
+		snapshot[method] = stats
+	}
+	return snapshot
+}
+
+func (m *Metrics) unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		m.record(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+func (m *Metrics) stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.record(info.FullMethod, start, err)
+		return err
+	}
+}
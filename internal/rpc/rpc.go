@@ -0,0 +1,102 @@
+// Package rpc serves the gRPC API defined in api/rx/v1 against the same
+// repositories as the REST API, with health checking and reflection.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	rxv1 "github.com/dhaskew/rx/api/rx/v1"
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+// Config is what the services are served from.
+type Config struct {
+	Films    films.FilmRepository
+	Comments comments.CommentRepository
+	// CommentCreated is called with every comment created through the API,
+	// e.g. to push it to live subscribers.
+	CommentCreated func(comments.Comment)
+
+	Authenticators []auth.Authenticator
+	Logger         *zap.Logger
+	Metrics        *Metrics
+	// TLSConfig serves TLS when set. Servers only reached through
+	// ServeHTTP leave TLS to the HTTP server.
+	TLSConfig *tls.Config
+}
+
+// Server is a gRPC server with the rx services registered.
+type Server struct {
+	*grpc.Server
+	health *health.Server
+}
+
+// NewServer registers the film, comment, health and reflection services.
+// Calls are logged, recovered from panics, counted and authenticated, in
+// that order.
+func NewServer(cfg Config) *Server {
+	if cfg.Metrics == nil {
+		cfg.Metrics = NewMetrics()
+	}
+	if cfg.CommentCreated == nil {
+		cfg.CommentCreated = func(comments.Comment) {}
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			unaryLogger(cfg.Logger),
+			unaryRecoverer(cfg.Logger),
+			cfg.Metrics.unary(),
+			unaryAuthenticate(cfg.Authenticators),
+		),
+		grpc.ChainStreamInterceptor(
+			streamLogger(cfg.Logger),
+			streamRecoverer(cfg.Logger),
+			cfg.Metrics.stream(),
+			streamAuthenticate(cfg.Authenticators),
+		),
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg.TLSConfig)))
+	}
+	s := grpc.NewServer(opts...)
+	rxv1.RegisterFilmServiceServer(s, &filmService{films: cfg.Films})
+	rxv1.RegisterCommentServiceServer(s, &commentService{comments: cfg.Comments, created: cfg.CommentCreated})
+
+	h := health.NewServer()
+	for _, name := range []string{"", rxv1.FilmService_ServiceDesc.ServiceName, rxv1.CommentService_ServiceDesc.ServiceName} {
+		h.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	healthpb.RegisterHealthServer(s, h)
+	reflection.Register(s)
+
+	return &Server{Server: s, health: h}
+}
+
+// Shutdown reports NOT_SERVING to health checks and stops once in-flight
+// calls are done, or forcibly when ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
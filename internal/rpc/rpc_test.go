@@ -0,0 +1,232 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	rxv1 "github.com/dhaskew/rx/api/rx/v1"
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+type testServer struct {
+	conn    *grpc.ClientConn
+	metrics *Metrics
+	created []comments.Comment
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys([]string{
+		"kiosk|secret|films:read comments:write",
+		"reader|read-only|films:read",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := films.NewMemFilmRepository([]films.Film{
+		{FilmID: 1, Title: "ACADEMY DINOSAUR", Description: "An epic drama", Rating: "PG"},
+		{FilmID: 2, Title: "ACE GOLDFINGER", Description: "An astounding epistle", Rating: "G"},
+		{FilmID: 3, Title: "ADAPTATION HOLES", Description: "An astounding reflection", Rating: "NC-17"},
+	})
+
+	ts := &testServer{metrics: NewMetrics()}
+	srv := NewServer(Config{
+		Films:          repo,
+		Comments:       comments.NewMemCommentRepository(1, 2, 3),
+		CommentCreated: func(c comments.Comment) { ts.created = append(ts.created, c) },
+		Authenticators: []auth.Authenticator{keys},
+		Logger:         zap.NewNop(),
+		Metrics:        ts.metrics,
+	})
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	ts.conn = conn
+	return ts
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), strings.ToLower(auth.APIKeyHeader), key)
+}
+
+func titles(list []*rxv1.Film) []string {
+	var titles []string
+	for _, f := range list {
+		titles = append(titles, f.Title)
+	}
+	return titles
+}
+
+func TestFilmService(t *testing.T) {
+	ts := newTestServer(t)
+	client := rxv1.NewFilmServiceClient(ts.conn)
+	ctx := withKey("read-only")
+
+	t.Run("list pages", func(t *testing.T) {
+		res, err := client.ListFilms(ctx, &rxv1.ListFilmsRequest{PageSize: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ACADEMY DINOSAUR", "ACE GOLDFINGER"}, titles(res.Films))
+		assert.Equal(t, "2", res.NextPageToken)
+
+		res, err = client.ListFilms(ctx, &rxv1.ListFilmsRequest{PageSize: 2, PageToken: res.NextPageToken})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ADAPTATION HOLES"}, titles(res.Films))
+		assert.Equal(t, "", res.NextPageToken)
+	})
+
+	t.Run("list by rating", func(t *testing.T) {
+		res, err := client.ListFilms(ctx, &rxv1.ListFilmsRequest{Rating: "G"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ACE GOLDFINGER"}, titles(res.Films))
+	})
+
+	t.Run("invalid page", func(t *testing.T) {
+		_, err := client.ListFilms(ctx, &rxv1.ListFilmsRequest{PageToken: "x"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.ListFilms(ctx, &rxv1.ListFilmsRequest{PageSize: maxPageSize + 1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("get", func(t *testing.T) {
+		film, err := client.GetFilm(ctx, &rxv1.GetFilmRequest{FilmId: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, "ADAPTATION HOLES", film.Title)
+		assert.Equal(t, "NC-17", film.Rating)
+
+		_, err = client.GetFilm(ctx, &rxv1.GetFilmRequest{FilmId: 99})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		res, err := client.SearchFilms(ctx, &rxv1.SearchFilmsRequest{Query: "Astounding"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ACE GOLDFINGER", "ADAPTATION HOLES"}, titles(res.Films))
+
+		_, err = client.SearchFilms(ctx, &rxv1.SearchFilmsRequest{Query: " "})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestCommentService(t *testing.T) {
+	ts := newTestServer(t)
+	client := rxv1.NewCommentServiceClient(ts.conn)
+	ctx := withKey("secret")
+
+	comment, err := client.CreateComment(ctx, &rxv1.CreateCommentRequest{FilmId: 1, Body: "great"})
+	assert.NoError(t, err)
+	assert.Equal(t, "kiosk", comment.Author)
+	assert.Equal(t, "great", comment.Body)
+	assert.NotNil(t, comment.CreatedAt)
+	if assert.Len(t, ts.created, 1) {
+		assert.Equal(t, int(comment.CommentId), ts.created[0].CommentID)
+	}
+
+	res, err := client.ListComments(ctx, &rxv1.ListCommentsRequest{FilmId: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, res.Comments, 1) {
+		assert.Equal(t, "great", res.Comments[0].Body)
+	}
+
+	_, err = client.CreateComment(ctx, &rxv1.CreateCommentRequest{FilmId: 1, Body: ""})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.CreateComment(ctx, &rxv1.CreateCommentRequest{FilmId: 99, Body: "lost"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Len(t, ts.created, 1)
+}
+
+func TestAuthentication(t *testing.T) {
+	ts := newTestServer(t)
+	films := rxv1.NewFilmServiceClient(ts.conn)
+	comments := rxv1.NewCommentServiceClient(ts.conn)
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"no credentials", func() error {
+			_, err := films.GetFilm(context.Background(), &rxv1.GetFilmRequest{FilmId: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"unknown key", func() error {
+			_, err := films.GetFilm(withKey("wrong"), &rxv1.GetFilmRequest{FilmId: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"missing scope", func() error {
+			_, err := comments.CreateComment(withKey("read-only"), &rxv1.CreateCommentRequest{FilmId: 1, Body: "hi"})
+			return err
+		}, codes.PermissionDenied},
+		{"granted", func() error {
+			_, err := comments.ListComments(withKey("read-only"), &rxv1.ListCommentsRequest{FilmId: 1})
+			return err
+		}, codes.OK},
+		{"health is open", func() error {
+			_, err := healthpb.NewHealthClient(ts.conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			return err
+		}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status.Code(tt.call()))
+		})
+	}
+
+	stats := ts.metrics.Snapshot()["/rx.v1.FilmService/GetFilm"]
+	assert.Equal(t, uint64(2), stats.Calls)
+	assert.Equal(t, uint64(2), stats.Codes[codes.Unauthenticated.String()])
+}
+
+func TestHealth(t *testing.T) {
+	ts := newTestServer(t)
+	client := healthpb.NewHealthClient(ts.conn)
+
+	for _, service := range []string{"", "rx.v1.FilmService", "rx.v1.CommentService"} {
+		res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status, service)
+	}
+}
+
+// TestMethodScopes keeps new methods from being denied to everyone: every
+// registered method is either scoped or public.
+func TestMethodScopes(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(Config{Logger: zap.NewNop()})
+	for service, info := range srv.GetServiceInfo() {
+		for _, m := range info.Methods {
+			method := "/" + service + "/" + m.Name
+			_, scoped := methodScopes[method]
+			assert.True(t, scoped != publicMethods[method], "%s must be either scoped or public", method)
+		}
+	}
+}
+
+func TestAuthenticateUnknownMethod(t *testing.T) {
+	t.Parallel()
+	_, err := authenticate(context.Background(), []auth.Authenticator{auth.Anonymous(auth.AllScopes...)}, "/rx.v1.FilmService/DeleteFilm")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/dhaskew/rx/internal/auth"
+)
+
+// Authenticators builds the authenticators described by the auth settings:
+// static API keys first, then JWTs signed with the shared secret or a key
+// from the JWKS file.
+func (s Settings) Authenticators() ([]auth.Authenticator, error) {
+	if !s.AuthEnabled {
+		return []auth.Authenticator{auth.Anonymous(auth.AllScopes...)}, nil
+	}
+
+	var authenticators []auth.Authenticator
+	if len(s.AuthAPIKeys) > 0 {
+		keys, err := auth.ParseAPIKeys(s.AuthAPIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, keys)
+	}
+
+	keySet := &auth.KeySet{}
+	if s.AuthJWKSFile != "" {
+		jwks, err := auth.LoadJWKS(s.AuthJWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keySet.Keys = append(keySet.Keys, jwks.Keys...)
+	}
+	if s.AuthJWTSecret != "" {
+		keySet.Keys = append(keySet.Keys, auth.Key{Alg: auth.HS256, Secret: []byte(s.AuthJWTSecret)})
+	}
+	if len(keySet.Keys) > 0 {
+		authenticators = append(authenticators, auth.NewJWT(keySet, s.AuthJWTIssuer, s.AuthJWTAudience))
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("AUTH_ENABLED is set but no API keys, JWT secret or JWKS file are configured")
+	}
+	return authenticators, nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/render"
+)
+
+// maxBatchIDs is the most films a batch get may ask for.
+const maxBatchIDs = 1000
+
+// batchMediaTypes are those a films.Batch can be rendered as, it being
+// neither a table nor a list.
+var batchMediaTypes = []string{render.JSON, render.XML}
+
+// idsParam reads the ids query parameter, a comma separated list of film IDs
+// validated against the route's description.
+func idsParam(r *http.Request) []int {
+	var ids []int
+	for _, item := range listParam(r.URL.Query().Get("ids")) {
+		if id, err := strconv.Atoi(item); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// batchGetRequest is the body of POST /v1/films:batchGet.
+type batchGetRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// batchGetFilmsHandler answers GET /v1/films:batchGet?ids= and, for lists of
+// IDs too long for a URL, POST /v1/films:batchGet with the IDs in the body.
+// Only GETs are conditional: they are validated like the list of films.
+func (s Server) batchGetFilmsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, ok := negotiate(w, r, batchMediaTypes...)
+		if !ok {
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req batchGetRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxValidatedBody)).Decode(&req); err != nil {
+				problem.Error(w, r, http.StatusBadRequest, "Request body must be a JSON list of ids")
+				return
+			}
+			s.writeBatch(w, r, mediaType, req.IDs)
+			return
+		}
+
+		lastModified, err := s.FilmRepository.LastModified(r.Context())
+		if err != nil {
+			// serve the films anyway, just without validators
+			s.Logger.Error("Error getting films last modified", zap.Error(err))
+		} else if notModified(w, r, s.Settings().CacheControlFilms, collectionETag(r, mediaType, lastModified), lastModified) {
+			return
+		}
+		s.writeBatch(w, r, mediaType, idsParam(r))
+	}
+}
+
+// writeBatch writes the films with ids in the order asked for, and the IDs
+// of no film.
+func (s Server) writeBatch(w http.ResponseWriter, r *http.Request, mediaType string, ids []int) {
+	batch, err := films.GetBatch(r.Context(), s.FilmRepository, ids, selectionParam(r))
+	if err == films.ErrNoRelations {
+		problem.Error(w, r, http.StatusBadRequest, "Relations cannot be included in batches")
+		return
+	} else if err != nil {
+		s.Logger.Error("Error getting films", zap.Error(err))
+		problem.Error(w, r, http.StatusInternalServerError, "Error getting films")
+		return
+	}
+	body, ok := s.encode(w, r, mediaType, batch)
+	if !ok {
+		return
+	}
+	write(w, http.StatusOK, mediaType, body)
+}
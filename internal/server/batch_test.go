@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+func TestBatchGetFilms(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{
+		{FilmID: 1, Title: "ACADEMY DINOSAUR", Rating: "PG"},
+		{FilmID: 2, Title: "ACE GOLDFINGER", Rating: "G"},
+	})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+
+	tooMany := strings.Repeat("1,", maxBatchIDs) + "1"
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+		want   string
+	}{
+		{"in the order asked for", "GET", "/v1/films:batchGet?ids=2,3,1&fields=title", "", http.StatusOK,
+			`{"films":[{"film_id":2,"title":"ACE GOLDFINGER"},{"film_id":1,"title":"ACADEMY DINOSAUR"}],"missing":[3]}`},
+		{"post", "POST", "/v1/films:batchGet?fields=rating", `{"ids": [4, 1, 1]}`, http.StatusOK,
+			`{"films":[{"film_id":1,"rating":"PG"}],"missing":[4]}`},
+		{"unprefixed", "POST", "/films:batchGet?fields=rating", `{"ids": [2]}`, http.StatusOK,
+			`{"films":[{"film_id":2,"rating":"G"}],"missing":[]}`},
+		{"xml", "GET", "/v1/films:batchGet?ids=1,3&fields=title&format=xml", "", http.StatusOK,
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<batch>\n\t<film>\n\t\t<film_id>1</film_id>\n\t\t<title>ACADEMY DINOSAUR</title>\n\t</film>\n\t<missing>3</missing>\n</batch>"},
+		{"not a table", "GET", "/v1/films:batchGet?ids=1&format=csv", "", http.StatusNotAcceptable, ""},
+		{"relations", "GET", "/v1/films:batchGet?ids=1&include=actors", "", http.StatusBadRequest, ""},
+		{"too many", "GET", "/v1/films:batchGet?ids=" + tooMany, "", http.StatusBadRequest, ""},
+		{"too many posted", "POST", "/v1/films:batchGet", `{"ids": [` + tooMany + `]}`, http.StatusBadRequest, ""},
+		{"no ids", "GET", "/v1/films:batchGet", "", http.StatusBadRequest, ""},
+		{"no ids posted", "POST", "/v1/films:batchGet", `{}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+			if tt.want == "" {
+				return
+			}
+			if strings.HasPrefix(tt.want, "{") {
+				assert.JSONEq(t, tt.want, rr.Body.String())
+			} else {
+				assert.Equal(t, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
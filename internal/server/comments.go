@@ -0,0 +1,297 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+const (
+	// commentWriteWait bounds a single write to a comment socket.
+	commentWriteWait = 10 * time.Second
+	// commentReadLimit is the largest message accepted from a client, which
+	// only ever sends control frames.
+	commentReadLimit = 512
+	// commentsProtocol is the subprotocol of comment sockets, which browsers
+	// offer along with their credentials, see auth.WebSocketCredentials.
+	commentsProtocol = "rx.comments"
+)
+
+func WithCommentRepository(repo comments.CommentRepository) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.CommentRepository = repo
+		return s
+	}
+}
+
+// WithCommentHub pushes new comments to the subscribers of hub instead of one
+// sized from the settings.
+func WithCommentHub(hub *comments.Hub) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.CommentHub = hub
+		return s
+	}
+}
+
+// filmIDParam reads the filmID route parameter, answering 400 if it is not a
+// number.
+func filmIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	filmID, err := strconv.Atoi(chi.URLParam(r, "filmID"))
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "filmID must be a number")
+		return 0, false
+	}
+	return filmID, true
+}
+
+// commentIDParam reads the commentID route parameter, answering 400 if it is
+// not a number.
+func commentIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "commentID"))
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "commentID must be a number")
+		return 0, false
+	}
+	return commentID, true
+}
+
+func (s Server) filmCommentsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		list, err := s.CommentRepository.GetByFilm(r.Context(), filmID)
+		if err != nil {
+			s.Logger.Error("Error getting comments", zap.Error(err))
+			problem.Error(w, r, http.StatusInternalServerError, "Error getting comments")
+			return
+		}
+
+		if list == nil {
+			list = []comments.Comment{}
+		}
+		s.respond(w, r, http.StatusOK, list)
+	}
+}
+
+// getComment loads the comment of the filmID and commentID route parameters,
+// answering the errors itself. Both API versions get comments with it.
+func (s Server) getComment(w http.ResponseWriter, r *http.Request) (comments.Comment, bool) {
+	filmID, ok := filmIDParam(w, r)
+	if !ok {
+		return comments.Comment{}, false
+	}
+	commentID, ok := commentIDParam(w, r)
+	if !ok {
+		return comments.Comment{}, false
+	}
+
+	comment, err := s.CommentRepository.GetByID(r.Context(), filmID, commentID)
+	if err == comments.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound, "Comment Not Found")
+		return comments.Comment{}, false
+	} else if err != nil {
+		s.Logger.Error("Error getting comment", zap.Error(err))
+		problem.Error(w, r, http.StatusInternalServerError, "Error getting comment")
+		return comments.Comment{}, false
+	}
+	return comment, true
+}
+
+// getFilmCommentHandler answers the Location of a created comment.
+func (s Server) getFilmCommentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		comment, ok := s.getComment(w, r)
+		if !ok {
+			return
+		}
+		s.respond(w, r, http.StatusOK, comment)
+	}
+}
+
+// createCommentRequest is the body of POST /v1/films/{filmID}/comments and
+// its v2 counterpart.
+type createCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// createFilmCommentHandler stores a comment by the authenticated principal and
+// pushes it to the film's live subscribers and the event stream.
+func (s Server) createFilmCommentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		// before creating anything, the comment could not be returned
+		mediaType, ok := negotiate(w, r)
+		if !ok {
+			return
+		}
+
+		comment, ok := s.createComment(w, r, filmID)
+		if !ok {
+			return
+		}
+
+		body, ok := s.encode(w, r, mediaType, comment)
+		if !ok {
+			return
+		}
+		w.Header().Set("Location", r.URL.Path+"/"+strconv.Itoa(comment.CommentID))
+		write(w, http.StatusCreated, mediaType, body)
+	}
+}
+
+// createComment stores the comment in the body of r by the authenticated
+// principal and pushes it to the film's live subscribers and the event
+// stream, answering the errors itself. Both API versions create comments
+// with it.
+func (s Server) createComment(w http.ResponseWriter, r *http.Request, filmID int) (comments.Comment, bool) {
+	var req createCommentRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Request body must be a JSON comment")
+		return comments.Comment{}, false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	comment := comments.Comment{FilmID: filmID, Author: principal.ID, Body: req.Body}
+	if err := comment.Validate(); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return comments.Comment{}, false
+	}
+
+	comment, err := s.CommentRepository.Create(r.Context(), comment)
+	if err == comments.ErrFilmNotFound {
+		problem.Error(w, r, http.StatusNotFound, "Film Not Found")
+		return comments.Comment{}, false
+	} else if err != nil {
+		s.Logger.Error("Error creating comment", zap.Error(err))
+		problem.Error(w, r, http.StatusInternalServerError, "Error creating comment")
+		return comments.Comment{}, false
+	}
+
+	s.commentCreated(comment)
+	return comment, true
+}
+
+// checkCommentOrigin accepts same-origin browsers, non-browser clients that
+// send no Origin, and the origins allowed by the CORS settings.
+func (s Server) checkCommentOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	return s.Settings().CORSOptions().allowsOrigin(origin)
+}
+
+// filmCommentsSocketHandler pushes every new comment on a film to a
+// WebSocket as JSON. The server pings every COMMENTS_WS_PING and closes
+// connections that stop answering; on shutdown it closes them with 1001
+// (going away), and clients that fell behind with 1013 (try again later).
+func (s Server) filmCommentsSocketHandler() http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     s.checkCommentOrigin,
+		Subprotocols:    []string{commentsProtocol},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+		if _, err := s.FilmRepository.GetByID(r.Context(), filmID); err == films.ErrNotFound {
+			problem.Error(w, r, http.StatusNotFound, "Film Not Found")
+			return
+		} else if err != nil {
+			s.Logger.Error("Error getting film", zap.Error(err))
+			problem.Error(w, r, http.StatusInternalServerError, "Error getting film")
+			return
+		}
+
+		sub, err := s.CommentHub.Subscribe(filmID)
+		if err != nil {
+			w.Header().Set("Retry-After", "30")
+			problem.Error(w, r, http.StatusServiceUnavailable, "Too many live comment connections, try again later")
+			return
+		}
+		defer sub.Cancel()
+
+		// Upgrade answers the handshake errors itself
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		ping := s.Settings().CommentsWSPing
+		s.serveCommentSocket(conn, sub, ping)
+	}
+}
+
+func (s Server) serveCommentSocket(conn *websocket.Conn, sub *comments.Subscriber, ping time.Duration) {
+	// a client is gone once it misses a pong
+	pongWait := ping * 2
+	conn.SetReadLimit(commentReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	// reading is what processes pongs and the client's close frame
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(ping)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case comment, ok := <-sub.C:
+			if !ok {
+				code, reason := websocket.CloseGoingAway, "server shutting down"
+				if sub.Lagged() {
+					code, reason = websocket.CloseTryAgainLater, "too slow"
+				}
+				msg := websocket.FormatCloseMessage(code, reason)
+				_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(commentWriteWait))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(commentWriteWait))
+			if err := conn.WriteJSON(comment); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(commentWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/events"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func commentsServer(t *testing.T, hub *comments.Hub) (*Server, *httptest.Server) {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys([]string{"kiosk|secret|films:read comments:write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := films.NewMemFilmRepository([]films.Film{{FilmID: 1, Title: "title"}})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithCommentRepository(comments.NewMemCommentRepository(1)),
+		WithCommentHub(hub),
+		WithEvents(events.NewBroker(10, 10)),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(keys),
+	)
+	srv.SetupRoutes()
+	ts := httptest.NewServer(srv.Router)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func postComment(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.APIKeyHeader, "secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func dialComments(t *testing.T, url string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	header.Set(auth.APIKeyHeader, "secret")
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), header)
+}
+
+func TestFilmComments(t *testing.T) {
+	t.Parallel()
+
+	srv, ts := commentsServer(t, comments.NewHub(0, 0, 10))
+	events, _ := srv.Events.Subscribe(events.Filter{}, 0)
+	url := ts.URL + "/v1/films/1/comments"
+
+	res := postComment(t, url, `{"body": "great"}`)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "/v1/films/1/comments/1", res.Header.Get("Location"))
+
+	// the Location is the comment
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.Header.Set(auth.APIKeyHeader, "secret")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	res = get(res.Header.Get("Location"))
+	var created comments.Comment
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 1, created.CommentID)
+	assert.Equal(t, "great", created.Body)
+	res = get("/v1/films/1/comments/2")
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res = postComment(t, url, `{"body": ""}`)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res = postComment(t, ts.URL+"/v1/films/2/comments", `{"body": "great"}`)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set(auth.APIKeyHeader, "secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var got []comments.Comment
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "kiosk", got[0].Author)
+		assert.Equal(t, "great", got[0].Body)
+	}
+
+	e := <-events.C
+	assert.Equal(t, "comment.added", e.Type)
+}
+
+// TestFilmCommentsSocketBrowserCredentials dials like a browser, which can
+// only offer its credentials as subprotocols.
+func TestFilmCommentsSocketBrowserCredentials(t *testing.T) {
+	t.Parallel()
+
+	_, ts := commentsServer(t, comments.NewHub(0, 0, 10))
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/films/1/comments/ws"
+
+	tests := []struct {
+		name      string
+		protocols []string
+		status    int
+	}{
+		{"api key", []string{commentsProtocol, "apikey.secret"}, http.StatusSwitchingProtocols},
+		{"no credentials", []string{commentsProtocol}, http.StatusUnauthorized},
+		{"unknown api key", []string{commentsProtocol, "apikey.nope"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dialer := websocket.Dialer{Subprotocols: tt.protocols}
+			conn, res, err := dialer.Dial(url, nil)
+			if assert.NotNil(t, res) {
+				assert.Equal(t, tt.status, res.StatusCode)
+			}
+			if tt.status != http.StatusSwitchingProtocols {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			// the credentials are never echoed as the protocol
+			assert.Equal(t, commentsProtocol, conn.Subprotocol())
+		})
+	}
+}
+
+func TestFilmCommentsSocket(t *testing.T) {
+	t.Parallel()
+
+	srv, ts := commentsServer(t, comments.NewHub(0, 1, 10))
+	url := ts.URL + "/v1/films/1/comments"
+
+	_, res, err := dialComments(t, ts.URL+"/v1/films/2/comments/ws")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	conn, _, err := dialComments(t, url+"/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// one connection per film
+	_, res, err = dialComments(t, url+"/ws")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	posted := postComment(t, url, `{"body": "live"}`)
+	_ = posted.Body.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var got comments.Comment
+	assert.NoError(t, conn.ReadJSON(&got))
+	assert.Equal(t, "live", got.Body)
+	assert.Equal(t, 1, got.FilmID)
+
+	// shutting down says goodbye
+	srv.CommentHub.Close()
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+}
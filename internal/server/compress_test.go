@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+	var list []films.Film
+	for i := 1; i <= 50; i++ {
+		list = append(list, films.Film{FilmID: i, Title: "FILM " + strconv.Itoa(i), Description: "A Epic Drama of a Feminist And a Mad Scientist"})
+	}
+	mem := films.NewMemFilmRepository(list)
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithCommentRepository(comments.NewMemCommentRepository(1)),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/films", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"Accept", "API-Version", "Accept-Encoding"}, rr.Header().Values("Vary"))
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []films.Film
+	assert.NoError(t, json.NewDecoder(zr).Decode(&got))
+	assert.Equal(t, list, got)
+
+	// the weakened validator still revalidates
+	etag := rr.Header().Get("ETag")
+	assert.Contains(t, etag, "W/")
+	rr = get(http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = get(nil)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+
+	// turned off
+	settings := srv.Settings()
+	settings.CompressionEncodings = nil
+	srv.settings.current.Store(settings)
+	rr = get(http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+
+	// gzip request bodies
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	_, _ = io.WriteString(zw, `{"body": "great"}`)
+	_ = zw.Close()
+	req := httptest.NewRequest("POST", "/v1/films/1/comments", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
@@ -1,10 +1,20 @@
 package server
 
 import (
+	"encoding"
+	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dhaskew/rx/internal/compress"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/ratelimit"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 const DefaultEnvPath = "./config/local.env"
@@ -24,3 +34,390 @@ func (c *Config) Load(envPath string) (map[string]string, error) {
 	c.Logger.Info(fmt.Sprintf("Loaded %d environment variables", cnt))
 	return c.env, err
 }
+
+// Reload re-reads the env file given to Load. Unlike Load, the previously
+// loaded values are kept if the file cannot be read or its settings are
+// invalid.
+func (c *Config) Reload() (map[string]string, error) {
+	c.Logger.Info("Reloading env config file: " + c.path)
+	env, err := godotenv.Read(c.path)
+	if err != nil {
+		return c.env, err
+	}
+	if _, err := ParseSettings(env); err != nil {
+		return c.env, err
+	}
+	c.env = env
+	c.Logger.Info(fmt.Sprintf("Loaded %d environment variables", len(c.env)))
+	return c.env, nil
+}
+
+// Settings parses the values loaded by Load into a Settings.
+func (c *Config) Settings() (Settings, error) {
+	return ParseSettings(c.env)
+}
+
+// Settings is the typed view of the env file.
+//
+// Each field names its variable with an `env` tag and may carry a `default`.
+// Fields tagged `reload:"true"` are swapped in on SIGHUP, any other change
+// needs a restart. Fields tagged `secret:"true"` are never logged.
+type Settings struct {
+	// Environment is development, test or production
+	Environment string `env:"ENVIRONMENT" default:"production"`
+
+	// Database Info
+	DBHost     string `env:"DB_HOST" default:"localhost"`
+	DBPort     int    `env:"DB_PORT" default:"5432"`
+	DBUser     string `env:"DB_USER" default:"postgres"`
+	DBPassword string `env:"DB_PASSWORD" secret:"true"`
+	DBName     string `env:"DB_NAME" default:"dvdrental"`
+
+	// DBMigrate applies pending migrations on start
+	DBMigrate bool `env:"DB_MIGRATE" default:"true"`
+	// DBNotify evicts cached films on catalog change notifications
+	DBNotify             bool          `env:"DB_NOTIFY" default:"true"`
+	DBNotifyMinReconnect time.Duration `env:"DB_NOTIFY_MIN_RECONNECT" default:"1s"`
+	DBNotifyMaxReconnect time.Duration `env:"DB_NOTIFY_MAX_RECONNECT" default:"1m"`
+
+	// HTTP Info
+	HTTPPort        string        `env:"HTTP_PORT" default:"8080"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+
+	// TLS Info, TLS is terminated in-process when a cert is configured
+	TLSCertFile       string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE"`
+	TLSMinVersion     string        `env:"TLS_MIN_VERSION" default:"1.2"`
+	TLSCipherPolicy   string        `env:"TLS_CIPHER_POLICY" default:"modern"`
+	TLSClientCAFile   string        `env:"TLS_CLIENT_CA_FILE"`
+	TLSClientAuth     string        `env:"TLS_CLIENT_AUTH" default:"require"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" default:"30s"`
+	HTTPRedirectPort  string        `env:"HTTP_REDIRECT_PORT"`
+
+	// gRPC Info, served on its own port when GRPC_PORT is set and/or on the
+	// HTTP port when multiplexed
+	GRPCPort      string `env:"GRPC_PORT"`
+	GRPCMultiplex bool   `env:"GRPC_MULTIPLEX" default:"false"`
+
+	// Authentication, disabling it grants every request all scopes
+	AuthEnabled     bool     `env:"AUTH_ENABLED" default:"true"`
+	AuthAPIKeys     []string `env:"AUTH_API_KEYS" secret:"true"`
+	AuthJWTSecret   string   `env:"AUTH_JWT_SECRET" secret:"true"`
+	AuthJWKSFile    string   `env:"AUTH_JWKS_FILE"`
+	AuthJWTIssuer   string   `env:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience string   `env:"AUTH_JWT_AUDIENCE"`
+
+	// HTTP caching, Cache-Control for the film collection and single film routes
+	CacheControlFilms string `env:"HTTP_CACHE_CONTROL_FILMS" default:"public, max-age=60" reload:"true"`
+	CacheControlFilm  string `env:"HTTP_CACHE_CONTROL_FILM" default:"public, max-age=300" reload:"true"`
+
+	// Film cache, a size of 0 disables it
+	FilmCacheSize        int           `env:"FILM_CACHE_SIZE" default:"1000"`
+	FilmCacheTTLAll      time.Duration `env:"FILM_CACHE_TTL_ALL" default:"5m" reload:"true"`
+	FilmCacheTTLByID     time.Duration `env:"FILM_CACHE_TTL_BY_ID" default:"10m" reload:"true"`
+	FilmCacheTTLFiltered time.Duration `env:"FILM_CACHE_TTL_FILTERED" default:"5m" reload:"true"`
+
+	// Rate limiting, <group>=<requests>/<period> entries, e.g. "default=120/1m,films=60/1m"
+	RateLimits     ratelimit.Limits         `env:"RATE_LIMITS" reload:"true"`
+	TrustedProxies ratelimit.TrustedProxies `env:"TRUSTED_PROXIES" reload:"true"`
+
+	// CORS for the /v1 routes
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" reload:"true"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" default:"GET,HEAD,POST" reload:"true"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" default:"Accept,API-Version,Authorization,Content-Type,Idempotency-Key,X-API-Key" reload:"true"`
+	CORSExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" default:"RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After,API-Version,Deprecation,Sunset,Link,Idempotent-Replayed" reload:"true"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" reload:"true"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m" reload:"true"`
+
+	// Idempotency keys, how long the response to a POST with an
+	// Idempotency-Key is replayed to its retries
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL" default:"24h" reload:"true"`
+
+	// Deprecation of the /v1 routes, announced on their responses from when
+	// it is set: the time v1 is deprecated at, the time it goes away and a
+	// page documenting the migration, times in RFC 3339
+	APIV1DeprecatedAt    time.Time `env:"API_V1_DEPRECATED_AT" reload:"true"`
+	APIV1SunsetAt        time.Time `env:"API_V1_SUNSET_AT" reload:"true"`
+	APIV1DeprecationLink string    `env:"API_V1_DEPRECATION_LINK" reload:"true"`
+
+	// Response compression, in the content codings offered (most preferred
+	// first, none turns it off), for bodies of at least the minimum size in
+	// bytes and of the listed media types
+	CompressionEncodings []string `env:"COMPRESSION_ENCODINGS" default:"br,zstd,gzip" reload:"true"`
+	CompressionMinSize   int      `env:"COMPRESSION_MIN_SIZE" default:"1024" reload:"true"`
+	CompressionTypes     []string `env:"COMPRESSION_TYPES" default:"application/json,application/problem+json,application/x-ndjson,text/csv,application/xml,text/html,text/plain" reload:"true"`
+
+	// Event stream, how many events are kept for resuming clients, how far a
+	// client may fall behind before it is disconnected, and how often idle
+	// streams get a keep-alive
+	EventsReplaySize  int           `env:"EVENTS_REPLAY_SIZE" default:"1000"`
+	EventsClientQueue int           `env:"EVENTS_CLIENT_QUEUE" default:"64"`
+	EventsHeartbeat   time.Duration `env:"EVENTS_HEARTBEAT" default:"15s" reload:"true"`
+	EventsRetry       time.Duration `env:"EVENTS_RETRY" default:"3s" reload:"true"`
+
+	// Live comment sockets, connection limits in total and per film (0 for
+	// none), how many comments a slow client may fall behind and how often
+	// clients are pinged
+	CommentsWSMaxConnections int           `env:"COMMENTS_WS_MAX_CONNECTIONS" default:"1000"`
+	CommentsWSMaxPerFilm     int           `env:"COMMENTS_WS_MAX_PER_FILM" default:"100"`
+	CommentsWSQueue          int           `env:"COMMENTS_WS_QUEUE" default:"16"`
+	CommentsWSPing           time.Duration `env:"COMMENTS_WS_PING" default:"30s" reload:"true"`
+
+	// GraphQL query limits, 0 disables a limit
+	GraphQLMaxDepth      int `env:"GRAPHQL_MAX_DEPTH" default:"8" reload:"true"`
+	GraphQLMaxComplexity int `env:"GRAPHQL_MAX_COMPLEXITY" default:"5000" reload:"true"`
+
+	// Logging
+	LogLevel zapcore.Level `env:"LOG_LEVEL" default:"debug" reload:"true"`
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// ParseSettings builds Settings from env, falling back to the field defaults
+// for missing keys, and validates the result.
+func ParseSettings(env map[string]string) (Settings, error) {
+	var s Settings
+	v := reflect.ValueOf(&s).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := env[key]
+		if !ok {
+			raw = field.Tag.Get("default")
+		}
+		if err := setField(v.Field(i), raw); err != nil {
+			return Settings{}, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return s, s.Validate()
+}
+
+func setField(f reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	// an unset time is the zero time
+	if f.Type() == reflect.TypeOf(time.Time{}) && raw == "" {
+		f.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	if f.CanAddr() && f.Addr().Type().Implements(textUnmarshalerType) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+	if f.Type() == reflect.TypeOf(time.Duration(0)) {
+		if raw == "" {
+			f.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		if raw == "" {
+			f.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		if raw == "" {
+			f.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", f.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Type())
+	}
+	return nil
+}
+
+// Validate checks the settings for values that parse but cannot be used.
+func (s Settings) Validate() error {
+	switch s.Environment {
+	case EnvDevelopment, EnvTest, EnvProduction:
+	default:
+		return fmt.Errorf("ENVIRONMENT: want %s, %s or %s, got %q", EnvDevelopment, EnvTest, EnvProduction, s.Environment)
+	}
+	port, err := strconv.Atoi(s.HTTPPort)
+	if err != nil || port < 0 || port > 65535 {
+		return fmt.Errorf("HTTP_PORT: invalid port %q", s.HTTPPort)
+	}
+	if s.GRPCPort != "" {
+		port, err := strconv.Atoi(s.GRPCPort)
+		if err != nil || port < 0 || port > 65535 {
+			return fmt.Errorf("GRPC_PORT: invalid port %q", s.GRPCPort)
+		}
+	}
+	if s.DBPort <= 0 || s.DBPort > 65535 {
+		return fmt.Errorf("DB_PORT: invalid port %d", s.DBPort)
+	}
+	if s.DBNotifyMinReconnect <= 0 || s.DBNotifyMaxReconnect < s.DBNotifyMinReconnect {
+		return errors.New("DB_NOTIFY_MIN_RECONNECT must be positive and at most DB_NOTIFY_MAX_RECONNECT")
+	}
+	if s.FilmCacheSize < 0 {
+		return errors.New("FILM_CACHE_SIZE cannot be negative")
+	}
+	if s.EventsReplaySize < 0 || s.EventsClientQueue < 1 {
+		return errors.New("EVENTS_REPLAY_SIZE cannot be negative and EVENTS_CLIENT_QUEUE must be positive")
+	}
+	if s.EventsHeartbeat <= 0 {
+		return errors.New("EVENTS_HEARTBEAT must be positive")
+	}
+	if s.CommentsWSMaxConnections < 0 || s.CommentsWSMaxPerFilm < 0 || s.CommentsWSQueue < 1 {
+		return errors.New("COMMENTS_WS_MAX_CONNECTIONS and COMMENTS_WS_MAX_PER_FILM cannot be negative and COMMENTS_WS_QUEUE must be positive")
+	}
+	if s.GraphQLMaxDepth < 0 || s.GraphQLMaxComplexity < 0 {
+		return errors.New("GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY cannot be negative")
+	}
+	if s.CommentsWSPing <= 0 {
+		return errors.New("COMMENTS_WS_PING must be positive")
+	}
+	for _, coding := range s.CompressionEncodings {
+		if !compress.Supported(coding) {
+			return fmt.Errorf("COMPRESSION_ENCODINGS: unknown encoding %q, want some of %s", coding, strings.Join(compress.Encodings, ","))
+		}
+	}
+	if s.CompressionMinSize < 0 {
+		return errors.New("COMPRESSION_MIN_SIZE cannot be negative")
+	}
+	if s.IdempotencyTTL <= 0 {
+		return errors.New("IDEMPOTENCY_TTL must be positive")
+	}
+	if !s.APIV1DeprecatedAt.IsZero() && !s.APIV1SunsetAt.IsZero() && s.APIV1SunsetAt.Before(s.APIV1DeprecatedAt) {
+		return errors.New("API_V1_SUNSET_AT cannot be before API_V1_DEPRECATED_AT")
+	}
+	if s.CORSAllowCredentials && containsFold(s.CORSAllowedOrigins, "*") {
+		return errors.New("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS \"*\"")
+	}
+	return s.validateTLS()
+}
+
+// CORSOptions are the CORS settings for the /v1 routes.
+func (s Settings) CORSOptions() CORSOptions {
+	return CORSOptions{
+		AllowedOrigins:   s.CORSAllowedOrigins,
+		AllowedMethods:   s.CORSAllowedMethods,
+		AllowedHeaders:   s.CORSAllowedHeaders,
+		ExposedHeaders:   s.CORSExposedHeaders,
+		AllowCredentials: s.CORSAllowCredentials,
+		MaxAge:           s.CORSMaxAge,
+	}
+}
+
+// APIV1Deprecation is the deprecation of the /v1 routes.
+func (s Settings) APIV1Deprecation() Deprecation {
+	return Deprecation{
+		At:     s.APIV1DeprecatedAt,
+		Sunset: s.APIV1SunsetAt,
+		Link:   s.APIV1DeprecationLink,
+	}
+}
+
+// CompressionOptions are the response compression settings.
+func (s Settings) CompressionOptions() compress.Options {
+	return compress.Options{
+		Encodings: s.CompressionEncodings,
+		MinSize:   s.CompressionMinSize,
+		Types:     s.CompressionTypes,
+	}
+}
+
+// FilmCacheTTLs are the TTLs for films.CachedRepository.
+func (s Settings) FilmCacheTTLs() films.CacheTTLs {
+	return films.CacheTTLs{
+		GetAll:   s.FilmCacheTTLAll,
+		GetByID:  s.FilmCacheTTLByID,
+		Filtered: s.FilmCacheTTLFiltered,
+	}
+}
+
+// Environments.
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvProduction  = "production"
+)
+
+// Development reports whether development only tooling, e.g. GraphiQL, is
+// served.
+func (s Settings) Development() bool {
+	return s.Environment == EnvDevelopment
+}
+
+// DSN is the lib/pq connection string for the configured database.
+func (s Settings) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", s.DBHost, s.DBPort, s.DBUser, s.DBPassword, s.DBName)
+}
+
+// SettingChange describes a single setting that differs between two Settings.
+type SettingChange struct {
+	Key        string
+	Old        string
+	New        string
+	Reloadable bool
+}
+
+// DiffSettings lists the settings that differ between old and new, in field
+// order. Secret values are redacted.
+func DiffSettings(old, new Settings) []SettingChange {
+	var changes []SettingChange
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		change := SettingChange{
+			Key:        field.Tag.Get("env"),
+			Old:        fmt.Sprint(ov.Field(i).Interface()),
+			New:        fmt.Sprint(nv.Field(i).Interface()),
+			Reloadable: field.Tag.Get("reload") == "true",
+		}
+		if field.Tag.Get("secret") == "true" {
+			change.Old, change.New = "<redacted>", "<redacted>"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// withReloadable returns s with every reloadable field copied from next.
+func (s Settings) withReloadable(next Settings) Settings {
+	sv := reflect.ValueOf(&s).Elem()
+	nv := reflect.ValueOf(next)
+	t := sv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("reload") == "true" {
+			sv.Field(i).Set(nv.Field(i))
+		}
+	}
+	return s
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestConfig_Load(t *testing.T) {
@@ -58,3 +59,57 @@ func TestConfig_Load(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSettings(t *testing.T) {
+	got, err := ParseSettings(map[string]string{
+		"DB_HOST":   "db",
+		"DB_PORT":   "5555",
+		"HTTP_PORT": "9090",
+		"LOG_LEVEL": "warn",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "db", got.DBHost)
+	assert.Equal(t, 5555, got.DBPort)
+	assert.Equal(t, "9090", got.HTTPPort)
+	assert.Equal(t, zapcore.WarnLevel, got.LogLevel)
+	// defaults for missing keys
+	assert.Equal(t, "dvdrental", got.DBName)
+
+	_, err = ParseSettings(map[string]string{"DB_PORT": "nope"})
+	assert.Error(t, err)
+
+	_, err = ParseSettings(map[string]string{"HTTP_PORT": "70000"})
+	assert.Error(t, err)
+
+	_, err = ParseSettings(map[string]string{"LOG_LEVEL": "loud"})
+	assert.Error(t, err)
+
+	// times are RFC 3339, unset by default
+	assert.True(t, got.APIV1DeprecatedAt.IsZero())
+	got, err = ParseSettings(map[string]string{"API_V1_DEPRECATED_AT": "2026-01-01T00:00:00Z"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1767225600), got.APIV1DeprecatedAt.Unix())
+
+	_, err = ParseSettings(map[string]string{"API_V1_DEPRECATED_AT": "2026-01-01T00:00:00Z", "API_V1_SUNSET_AT": "2025-01-01T00:00:00Z"})
+	assert.Error(t, err)
+}
+
+func TestDiffSettings(t *testing.T) {
+	old, _ := ParseSettings(nil)
+	next := old
+	next.HTTPPort = "9090"
+	next.LogLevel = zapcore.ErrorLevel
+	next.DBPassword = "hunter2"
+
+	changes := DiffSettings(old, next)
+	assert.Equal(t, []SettingChange{
+		{Key: "DB_PASSWORD", Old: "<redacted>", New: "<redacted>", Reloadable: false},
+		{Key: "HTTP_PORT", Old: "8080", New: "9090", Reloadable: false},
+		{Key: "LOG_LEVEL", Old: "debug", New: "error", Reloadable: true},
+	}, changes)
+
+	merged := old.withReloadable(next)
+	assert.Equal(t, "8080", merged.HTTPPort)
+	assert.Equal(t, "", merged.DBPassword)
+	assert.Equal(t, zapcore.ErrorLevel, merged.LogLevel)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures CORS for a route group.
+type CORSOptions struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"), wildcard
+	// subdomains ("https://*.example.com") or "*" for any origin.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// allowsOrigin reports whether origin matches one of the allowed origins.
+func (o CORSOptions) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		i := strings.Index(allowed, "*.")
+		if i < 0 {
+			continue
+		}
+		prefix, suffix := allowed[:i], allowed[i+1:]
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) && len(origin) > len(prefix)+len(suffix) {
+			// the wildcard covers subdomain labels only, not ports or paths
+			sub := origin[len(prefix) : len(origin)-len(suffix)]
+			if !strings.ContainsAny(sub, "/:@") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// preflightAnswered carries a *bool that CORS sets once it has answered a
+// preflight, so that ZapRequestLogger leaves it out of the log.
+type preflightAnswered struct{}
+
+// markPreflightAnswered tells the request logger of r, if any, that the
+// preflight was answered.
+func markPreflightAnswered(r *http.Request) {
+	if answered, ok := r.Context().Value(preflightAnswered{}).(*bool); ok {
+		*answered = true
+	}
+}
+
+// withPreflightFlag returns r with a flag for markPreflightAnswered.
+func withPreflightFlag(r *http.Request) (*http.Request, *bool) {
+	answered := new(bool)
+	return r.WithContext(context.WithValue(r.Context(), preflightAnswered{}, answered)), answered
+}
+
+// CORS applies the options returned by options, read per request so they
+// can be reloaded. Preflight requests are answered here and never reach the
+// handlers.
+func CORS(options func() CORSOptions) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			opts := options()
+			if len(opts.AllowedOrigins) == 0 {
+				// no CORS, the same response for every origin
+				next.ServeHTTP(w, r)
+				return
+			}
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			// the response depends on the origin, so caches must key on it,
+			// even without one: a response cached for a same-origin request
+			// would otherwise be served to other origins without CORS headers
+			anyOrigin := allowsAnyOrigin(opts)
+			if !anyOrigin {
+				h.Add("Vary", "Origin")
+			}
+			if origin == "" && !anyOrigin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if isPreflight(r) {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				if opts.allowsOrigin(origin) && preflightAllowed(opts, r) {
+					setAllowOrigin(h, opts, origin)
+					h.Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+					if len(opts.AllowedHeaders) > 0 {
+						h.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+					}
+					if opts.MaxAge > 0 {
+						h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+					}
+				}
+				markPreflightAnswered(r)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if anyOrigin || opts.allowsOrigin(origin) {
+				setAllowOrigin(h, opts, origin)
+				if len(opts.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowsAnyOrigin reports whether opts answer every origin alike, with a
+// literal "*".
+func allowsAnyOrigin(opts CORSOptions) bool {
+	return containsFold(opts.AllowedOrigins, "*") && !opts.AllowCredentials
+}
+
+func preflightAllowed(opts CORSOptions, r *http.Request) bool {
+	if !containsFold(opts.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+		return false
+	}
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !containsFold(opts.AllowedHeaders, header) {
+			return false
+		}
+	}
+	return true
+}
+
+// setAllowOrigin echoes the origin, except for "*" without credentials where
+// the literal wildcard is enough.
+func setAllowOrigin(h http.Header, opts CORSOptions, origin string) {
+	if allowsAnyOrigin(opts) {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if opts.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
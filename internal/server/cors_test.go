@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCORSOptionsAllowsOrigin(t *testing.T) {
+	t.Parallel()
+
+	opts := CORSOptions{AllowedOrigins: []string{"https://app.example.com", "https://*.mockbuster.com"}}
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "https://app.example.com", allowed: true},
+		{origin: "https://APP.example.com", allowed: true},
+		{origin: "http://app.example.com"},
+		{origin: "https://kiosk.mockbuster.com", allowed: true},
+		{origin: "https://a.b.mockbuster.com", allowed: true},
+		{origin: "https://mockbuster.com"},
+		{origin: "https://evilmockbuster.com"},
+		{origin: "https://evil.com/.mockbuster.com"},
+		{origin: "https://evil.com:1.mockbuster.com"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, opts.allowsOrigin(tt.origin), tt.origin)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	opts := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-API-Key"},
+		ExposedHeaders:   []string{"RateLimit-Remaining"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	called := 0
+	handler := CORS(func() CORSOptions { return opts })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	}))
+
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/v1/films", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-api-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Equal(t, 0, called, "preflight must not reach the handler")
+		assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "GET, POST", rr.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, X-API-Key", rr.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", rr.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rr.Header().Values("Vary"))
+	})
+
+	t.Run("preflight with disallowed header", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/v1/films", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "X-Evil")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("simple request", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/v1/films", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, 1, called)
+		assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "RateLimit-Remaining", rr.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/v1/films", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+	})
+
+	t.Run("no origin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/v1/films", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, 3, called)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Origin", rr.Header().Get("Vary"), "a cached same-origin response must not be served to other origins")
+	})
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	t.Parallel()
+
+	handler := CORS(func() CORSOptions {
+		return CORSOptions{AllowedOrigins: []string{"*"}}
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/v1/films", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, rr.Header().Get("Vary"))
+
+	// every origin gets the same response, with or without one
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/films", nil))
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rr.Header().Get("Vary"))
+
+	_, err := ParseSettings(map[string]string{"CORS_ALLOWED_ORIGINS": "*", "CORS_ALLOW_CREDENTIALS": "true"})
+	assert.Error(t, err)
+}
+
+func TestRequestLoggerPreflights(t *testing.T) {
+	t.Parallel()
+
+	preflight := func() *http.Request {
+		req := httptest.NewRequest("OPTIONS", "/v1/films", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		return req
+	}
+	notAllowed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+
+	tests := []struct {
+		name   string
+		opts   CORSOptions
+		logged int
+	}{
+		{name: "answered by CORS", opts: CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{"GET"}}},
+		{name: "CORS off", logged: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			core, logs := observer.New(zap.InfoLevel)
+			handler := ZapRequestLogger(zap.New(core))(CORS(func() CORSOptions { return tt.opts })(notAllowed))
+			handler.ServeHTTP(httptest.NewRecorder(), preflight())
+			assert.Equal(t, tt.logged, logs.FilterMessage("Served").Len())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Deprecation announces that an API version is deprecated, or will be, and
+// when it goes away. The zero Deprecation announces nothing.
+type Deprecation struct {
+	At     time.Time
+	Sunset time.Time
+	// Link documents the deprecation, e.g. a migration guide.
+	Link string
+}
+
+func (d Deprecation) announced() bool {
+	return !d.At.IsZero() || !d.Sunset.IsZero()
+}
+
+// Deprecate sets the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+// on every response of the routes it wraps, with links to the documentation
+// and, when successor names one, to the resource replacing the requested
+// one.
+func Deprecate(options func() Deprecation, successor func(r *http.Request) string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := options()
+			if !d.announced() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			if !d.At.IsZero() {
+				h.Set("Deprecation", "@"+strconv.FormatInt(d.At.Unix(), 10))
+			}
+			if !d.Sunset.IsZero() {
+				h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				h.Add("Link", "<"+d.Link+`>; rel="deprecation"; type="text/html"`)
+			}
+			if path := successor(r); path != "" {
+				h.Add("Link", "<"+path+`>; rel="successor-version"`)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// v2Successor is the v2 path of a v1 request when the v2 routes serve it.
+func (s Server) v2Successor(r *http.Request) string {
+	path := "/v2" + strings.TrimPrefix(r.URL.Path, "/v1")
+	if !s.Router.Match(chi.NewRouteContext(), r.Method, path) {
+		return ""
+	}
+	return path
+}
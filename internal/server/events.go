@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/events"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// WithEvents publishes events on broker instead of one sized from the
+// settings.
+func WithEvents(broker *events.Broker) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.Events = broker
+		return s
+	}
+}
+
+// filmEvent is the data of film events.
+type filmEvent struct {
+	FilmID int `json:"film_id"`
+}
+
+// inventoryEvent is the data of inventory events.
+type inventoryEvent struct {
+	FilmID      int `json:"film_id"`
+	StoreID     int `json:"store_id"`
+	InventoryID int `json:"inventory_id"`
+}
+
+// catalogEvent maps a catalog change to the event published for it, if any.
+// Films being deleted and copies being added or removed have no event.
+func catalogEvent(c films.CatalogChange) (string, int, interface{}, bool) {
+	switch {
+	case c.Table == "film" && c.Op == "INSERT":
+		return events.FilmCreated, 0, filmEvent{FilmID: c.FilmID}, true
+	case c.Table == "film" && c.Op == "UPDATE",
+		c.Table == "film_category" || c.Table == "film_actor":
+		return events.FilmUpdated, 0, filmEvent{FilmID: c.FilmID}, true
+	case c.Table == "rental" && c.Op == "INSERT":
+		return events.InventoryRented, c.StoreID, inventoryEvent{c.FilmID, c.StoreID, c.InventoryID}, true
+	case c.Table == "rental" && c.Op == "UPDATE":
+		return events.InventoryReturned, c.StoreID, inventoryEvent{c.FilmID, c.StoreID, c.InventoryID}, true
+	}
+	return "", 0, nil, false
+}
+
+func (s Server) publishCatalogChange(c films.CatalogChange) {
+	eventType, storeID, data, ok := catalogEvent(c)
+	if !ok {
+		return
+	}
+	if _, err := s.Events.Publish(eventType, storeID, data); err != nil {
+		s.Logger.Error("Could not publish event", zap.String("type", eventType), zap.Error(err))
+	}
+}
+
+// eventsFilter reads the type and store query parameters.
+func eventsFilter(r *http.Request) (events.Filter, error) {
+	var filter events.Filter
+	q := r.URL.Query()
+	if types := q.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !containsFold(events.Types, t) {
+				return filter, fmt.Errorf("unknown event type %q, want one of %s", t, strings.Join(events.Types, ", "))
+			}
+			filter.Types = append(filter.Types, strings.ToLower(t))
+		}
+	}
+	if store := q.Get("store"); store != "" {
+		id, err := strconv.Atoi(store)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("invalid store %q", store)
+		}
+		filter.StoreID = id
+	}
+	return filter, nil
+}
+
+// lastEventID reads the ID to resume after from the Last-Event-ID header
+// EventSource sends when reconnecting, or the last_event_id query parameter
+// for clients that cannot set headers.
+func lastEventID(r *http.Request) (uint64, error) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	if id == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(id, 10, 64)
+}
+
+// eventsHandler streams events as text/event-stream until the client goes
+// away, falls too far behind or the server shuts down. Clients that were
+// dropped reconnect with Last-Event-ID and catch up from the replay buffer.
+func (s Server) eventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := eventsFilter(r)
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		lastID, err := lastEventID(r)
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, "Last-Event-ID must be an event ID")
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// the stream outlives the server's WriteTimeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			s.Logger.Debug("Could not clear the write deadline", zap.Error(err))
+		}
+
+		sub, complete := s.Events.Subscribe(filter, lastID)
+		defer sub.Cancel()
+		if !complete {
+			s.Logger.Debug("Resuming event stream with a gap", zap.Uint64("last_event_id", lastID))
+		}
+
+		settings := s.Settings()
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		// keep nginx from buffering the stream
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", settings.EventsRetry.Milliseconds())
+		if err := rc.Flush(); err != nil {
+			s.Logger.Error("Event stream cannot be flushed", zap.Error(err))
+			return
+		}
+
+		heartbeat := time.NewTicker(settings.EventsHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					if sub.Lagged() {
+						s.Logger.Info("Dropped slow event stream client", zap.Uint64("last_event_id", lastID))
+					}
+					return
+				}
+				lastID = e.ID
+				_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
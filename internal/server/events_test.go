@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/events"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestCatalogEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		change    films.CatalogChange
+		eventType string
+		storeID   int
+	}{
+		{films.CatalogChange{Table: "film", Op: "INSERT", FilmID: 1}, events.FilmCreated, 0},
+		{films.CatalogChange{Table: "film", Op: "UPDATE", FilmID: 1}, events.FilmUpdated, 0},
+		{films.CatalogChange{Table: "film_actor", Op: "DELETE", FilmID: 1}, events.FilmUpdated, 0},
+		{films.CatalogChange{Table: "rental", Op: "INSERT", FilmID: 1, StoreID: 2}, events.InventoryRented, 2},
+		{films.CatalogChange{Table: "rental", Op: "UPDATE", FilmID: 1, StoreID: 2}, events.InventoryReturned, 2},
+		{films.CatalogChange{Table: "film", Op: "DELETE", FilmID: 1}, "", 0},
+		{films.CatalogChange{Table: "inventory", Op: "INSERT", FilmID: 1, StoreID: 2}, "", 0},
+	}
+
+	for _, tt := range tests {
+		eventType, storeID, _, ok := catalogEvent(tt.change)
+		assert.Equal(t, tt.eventType != "", ok, tt.change)
+		assert.Equal(t, tt.eventType, eventType, tt.change)
+		assert.Equal(t, tt.storeID, storeID, tt.change)
+	}
+}
+
+// readEvents reads n events from an event stream, skipping comments and the
+// retry field.
+func readEvents(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+	var got []string
+	var event []string
+	for len(got) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if len(event) > 0 {
+				got = append(got, strings.Join(event, "|"))
+			}
+			event = nil
+		case strings.HasPrefix(line, ":"), strings.HasPrefix(line, "retry:"):
+		default:
+			event = append(event, line)
+		}
+	}
+	return got
+}
+
+func TestEventsHandler(t *testing.T) {
+	t.Parallel()
+
+	broker := events.NewBroker(10, 10)
+	srv := NewServer(
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+		WithEvents(broker),
+	)
+	srv.SetupRoutes()
+	ts := httptest.NewServer(srv.Router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/v1/events?type=film.deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	_, _ = broker.Publish(events.FilmCreated, 0, filmEvent{FilmID: 1})
+	_, _ = broker.Publish(events.InventoryRented, 1, inventoryEvent{1, 1, 10})
+	_, _ = broker.Publish(events.InventoryRented, 2, inventoryEvent{1, 2, 20})
+
+	req, _ := http.NewRequest("GET", ts.URL+"/v1/events?type=inventory.rented,inventory.returned&store=2", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	body := bufio.NewReader(res.Body)
+	assert.Equal(t, []string{
+		`id: 3|event: inventory.rented|data: {"film_id":1,"store_id":2,"inventory_id":20}`,
+	}, readEvents(t, body, 1))
+
+	_, _ = broker.Publish(events.InventoryReturned, 1, inventoryEvent{1, 1, 10})
+	_, _ = broker.Publish(events.InventoryReturned, 2, inventoryEvent{1, 2, 20})
+	assert.Equal(t, []string{
+		`id: 5|event: inventory.returned|data: {"film_id":1,"store_id":2,"inventory_id":20}`,
+	}, readEvents(t, body, 1))
+
+	// closing the broker on shutdown ends the stream
+	broker.Close()
+	_, err = body.ReadString('\n')
+	assert.Error(t, err)
+}
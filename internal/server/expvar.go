@@ -0,0 +1,41 @@
+package server
+
+import (
+	"expvar"
+	"sync"
+)
+
+var (
+	expvarMu    sync.Mutex
+	expvarFuncs = map[string]*expvarFunc{}
+)
+
+// expvarFunc lets a published variable be pointed at a new source, since
+// expvar panics when a name is published twice (e.g. by several servers in
+// tests).
+type expvarFunc struct {
+	mu sync.Mutex
+	fn func() interface{}
+}
+
+func (f *expvarFunc) value() interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fn()
+}
+
+// publishExpvar publishes fn under name at /debug/vars, replacing the source
+// of an earlier publication of the same name.
+func publishExpvar(name string, fn func() interface{}) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if f, ok := expvarFuncs[name]; ok {
+		f.mu.Lock()
+		f.fn = fn
+		f.mu.Unlock()
+		return
+	}
+	f := &expvarFunc{fn: fn}
+	expvarFuncs[name] = f
+	expvar.Publish(name, expvar.Func(f.value))
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dhaskew/rx/internal/films"
+)
+
+// selectionParam reads the fields and include query parameters, comma
+// separated lists of the film fields to return and the relations to embed.
+// They are validated against the route's description.
+func selectionParam(r *http.Request) films.Selection {
+	q := r.URL.Query()
+	return films.Selection{
+		Fields:  listParam(q.Get("fields")),
+		Include: listParam(q.Get("include")),
+	}
+}
+
+func listParam(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+func TestFilmsSparseFieldsets(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{{
+		FilmID:      1,
+		Title:       "ACADEMY DINOSAUR",
+		Description: "description",
+		Rating:      "PG",
+		Actors:      films.Actors{{ActorID: 1, FirstName: "PENELOPE", LastName: "GUINESS"}},
+		Language:    &films.Language{LanguageID: 1, Name: "English"},
+	}})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		body   string
+	}{
+		{"fields", "/v1/films?format=ndjson&fields=title,rating", http.StatusOK,
+			`{"film_id":1,"title":"ACADEMY DINOSAUR","rating":"PG"}` + "\n"},
+		{"include", "/v1/films/1?format=ndjson&fields=film_id&include=actors,language,categories", http.StatusOK,
+			`{"film_id":1,"actors":[{"actor_id":1,"first_name":"PENELOPE","last_name":"GUINESS"}],"language":{"language_id":1,"name":"English"}}` + "\n"},
+		{"csv columns", "/v1/films?format=csv&fields=rating,title", http.StatusOK,
+			"film_id,title,rating\n1,ACADEMY DINOSAUR,PG\n"},
+		{"xml relations", "/v1/films/1?format=xml&fields=film_id&include=actors", http.StatusOK,
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<film>\n\t<film_id>1</film_id>\n\t<actors>\n\t\t<actor>\n\t\t\t<actor_id>1</actor_id>\n\t\t\t<first_name>PENELOPE</first_name>\n\t\t\t<last_name>GUINESS</last_name>\n\t\t</actor>\n\t</actors>\n</film>"},
+		{"unknown field", "/v1/films?fields=title,budget", http.StatusBadRequest, ""},
+		{"unknown relation", "/v1/films/1?include=inventory", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, tt.status, rr.Code, tt.name)
+		if tt.body != "" {
+			assert.Equal(t, tt.body, rr.Body.String(), tt.name)
+		}
+	}
+}
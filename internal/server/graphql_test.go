@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestGraphQLRoutes(t *testing.T) {
+	t.Parallel()
+
+	for _, env := range []string{EnvDevelopment, EnvProduction} {
+		keys, err := auth.ParseAPIKeys([]string{"test|secret|films:read"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mem := films.NewMemFilmRepository([]films.Film{{FilmID: 1, Title: "title"}})
+		srv := NewServer(
+			WithFilmRepository(&mem),
+			WithLogger(zap.NewNop()),
+			WithRouterFunc(chi.NewRouter),
+			WithAuthenticators(keys),
+		)
+		settings := srv.Settings()
+		settings.Environment = env
+		srv.settings.current.Store(settings)
+		srv.SetupRoutes()
+
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ film(id: 1) { title } }"}`)))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ film(id: 1) { title } }"}`))
+		req.Header.Set(auth.APIKeyHeader, "secret")
+		rr = httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"data": {"film": {"title": "title"}}}`, rr.Body.String())
+
+		// GraphiQL is only served in development
+		rr = httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/graphiql", nil))
+		if env == EnvDevelopment {
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Contains(t, rr.Body.String(), "'/graphql'")
+		} else {
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		}
+	}
+}
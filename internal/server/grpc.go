@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/events"
+	"github.com/dhaskew/rx/internal/rpc"
+)
+
+// commentCreated pushes a new comment to the film's live subscribers and the
+// event stream, whichever API it was created through.
+func (s Server) commentCreated(c comments.Comment) {
+	s.CommentHub.Publish(c)
+	if _, err := s.Events.Publish(events.CommentAdded, 0, c); err != nil {
+		s.Logger.Error("Could not publish event", zap.String("type", events.CommentAdded), zap.Error(err))
+	}
+}
+
+// grpcServer builds the gRPC server when it is served on GRPC_PORT or
+// multiplexed with HTTP, nil otherwise. It must be called after serveFunc so
+// that it shares the TLS configuration.
+func (s Server) grpcServer() *rpc.Server {
+	settings := s.Settings()
+	if settings.GRPCPort == "" && !settings.GRPCMultiplex {
+		return nil
+	}
+	metrics := rpc.NewMetrics()
+	publishExpvar("grpc", func() interface{} { return metrics.Snapshot() })
+	cfg := rpc.Config{
+		Films:          s.FilmRepository,
+		Comments:       s.CommentRepository,
+		CommentCreated: s.commentCreated,
+		Authenticators: s.Authenticators,
+		Logger:         s.Logger,
+		Metrics:        metrics,
+	}
+	if settings.GRPCPort != "" {
+		cfg.TLSConfig = s.TLSConfig
+	}
+	return rpc.NewServer(cfg)
+}
+
+// isGRPC reports whether r is a gRPC call rather than a REST request.
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// multiplexGRPC serves gRPC calls on the HTTP port. Without TLS, HTTP/2 is
+// only spoken with prior knowledge (h2c), which is what gRPC clients do.
+func (s Server) multiplexGRPC(g *rpc.Server) {
+	router := s.Handler
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPC(r) {
+			g.ServeHTTP(w, r)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	if !s.Settings().TLSEnabled() {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: s.IdleTimeout})
+	}
+	s.Handler = handler
+}
+
+// serveGRPC serves g on GRPC_PORT, sending the error it stops with, if any,
+// on serveErr.
+func (s Server) serveGRPC(g *rpc.Server, serveErr chan<- error) error {
+	l, err := net.Listen("tcp", ":"+s.Settings().GRPCPort)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := g.Serve(l); err != nil {
+			serveErr <- err
+		}
+	}()
+	s.Logger.Info("Serving gRPC", zap.String("addr", l.Addr().String()))
+	return nil
+}
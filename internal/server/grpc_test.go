@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	rxv1 "github.com/dhaskew/rx/api/rx/v1"
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+func TestRunMultiplexesGRPC(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.ParseAPIKeys([]string{"test|secret|films:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := films.NewMemFilmRepository([]films.Film{{FilmID: 1, Title: "title"}})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(keys),
+		WithListener(l),
+	)
+	settings := srv.Settings()
+	settings.GRPCMultiplex = true
+	srv.settings.current.Store(settings)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(ctx)
+	}()
+	<-srv.Ready()
+	addr := srv.ListenAddr().String()
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	callCtx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret")
+	film, err := rxv1.NewFilmServiceClient(conn).GetFilm(callCtx, &rxv1.GetFilmRequest{FilmId: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "title", film.Title)
+	}
+
+	// REST is still served on the same port
+	req, _ := http.NewRequest("GET", "http://"+addr+"/v1/films/1", nil)
+	req.Header.Set(auth.APIKeyHeader, "secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	_ = conn.Close()
+	cancel()
+	assert.NoError(t, <-done)
+}
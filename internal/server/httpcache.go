@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// strongETag quotes the first 128 bits of the SHA-256 of parts.
+func strongETag(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// collectionETag identifies a collection response without rendering it: it
+// only changes when a film changes or the request asks for something else.
+func collectionETag(r *http.Request, mediaType string, lastModified time.Time) string {
+	version, _ := r.Context().Value(ApiVersion{}).(string)
+	return strongETag(
+		[]byte(version),
+		[]byte(mediaType),
+		[]byte(r.URL.Path),
+		[]byte(r.URL.RawQuery),
+		[]byte(strconv.FormatInt(lastModified.UnixNano(), 10)),
+	)
+}
+
+// etagMatches implements the weak comparison If-None-Match uses.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the validators and cache policy on the response and, when
+// the request's conditions show the client's copy is current, answers 304
+// and returns true. If-None-Match takes precedence over If-Modified-Since.
+func notModified(w http.ResponseWriter, r *http.Request, cacheControl, etag string, lastModified time.Time) bool {
+	h := w.Header()
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" || !etagMatches(inm, etag) {
+			return false
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	// a 304 carries the validators but no representation headers
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func cachingServer(t *testing.T, lastUpdate time.Time) *Server {
+	t.Helper()
+	mem := films.NewMemFilmRepository([]films.Film{
+		{FilmID: 1, Title: "title", Rating: "PG", LastUpdate: lastUpdate},
+	})
+	return NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+	)
+}
+
+func TestFilmsHandlerConditionalGet(t *testing.T) {
+	t.Parallel()
+
+	lastUpdate := time.Date(2013, 5, 26, 14, 50, 58, 0, time.UTC)
+	srv := cachingServer(t, lastUpdate)
+	handler := srv.filmsHandler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/films?rating=PG", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "Sun, 26 May 2013 14:50:58 GMT", rr.Header().Get("Last-Modified"))
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+
+	req := httptest.NewRequest("GET", "/v1/films?rating=PG", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	// a different query is a different representation
+	req = httptest.NewRequest("GET", "/v1/films?rating=G", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	req = httptest.NewRequest("GET", "/v1/films?rating=PG", nil)
+	req.Header.Set("If-Modified-Since", "Sun, 26 May 2013 14:50:58 GMT")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	req = httptest.NewRequest("GET", "/v1/films?rating=PG", nil)
+	req.Header.Set("If-Modified-Since", "Sun, 26 May 2013 14:50:57 GMT")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestGetFilmHandlerConditionalGet(t *testing.T) {
+	t.Parallel()
+
+	srv := cachingServer(t, time.Date(2013, 5, 26, 14, 50, 58, 0, time.UTC))
+	srv.Router.Get("/v1/films/{filmID}", srv.getFilmHandler())
+
+	rr := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/films/1", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.Equal(t, strongETag(rr.Body.Bytes()), etag)
+	assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+
+	req := httptest.NewRequest("GET", "/v1/films/1", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	rr = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	// If-None-Match wins over If-Modified-Since
+	req = httptest.NewRequest("GET", "/v1/films/1", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", "Mon, 27 May 2013 00:00:00 GMT")
+	rr = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestConditionalGetWithRelations(t *testing.T) {
+	t.Parallel()
+
+	// the actors changed after the film did
+	srv := cachingServer(t, time.Date(2013, 5, 26, 14, 50, 58, 0, time.UTC))
+	srv.Router.Get("/v1/films", srv.filmsHandler())
+	srv.Router.Get("/v1/films/{filmID}", srv.getFilmHandler())
+	since := "Mon, 27 May 2013 00:00:00 GMT"
+
+	t.Run("collection", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/v1/films?include=actors", nil)
+		req.Header.Set("If-Modified-Since", since)
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("ETag"))
+		assert.Empty(t, rr.Header().Get("Last-Modified"))
+		assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	})
+
+	t.Run("film", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/v1/films/1?include=actors", nil)
+		req.Header.Set("If-Modified-Since", since)
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("Last-Modified"))
+		etag := rr.Header().Get("ETag")
+		assert.Equal(t, strongETag(rr.Body.Bytes()), etag)
+
+		req = httptest.NewRequest("GET", "/v1/films/1?include=actors", nil)
+		req.Header.Set("If-None-Match", etag)
+		rr = httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/idempotency"
+)
+
+// idempotencyPurgeEvery is how often expired idempotency keys are dropped.
+const idempotencyPurgeEvery = 10 * time.Minute
+
+// WithIdempotencyStore keeps the responses to POSTs with an Idempotency-Key
+// in store instead of in process.
+func WithIdempotencyStore(store idempotency.Store) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.IdempotencyStore = store
+		return s
+	}
+}
+
+// idempotent replays the stored response to retried POSTs for the current
+// IDEMPOTENCY_TTL, see idempotency.Middleware.
+func (s Server) idempotent(next http.Handler) http.Handler {
+	return idempotency.Middleware(s.IdempotencyStore, func() time.Duration { return s.Settings().IdempotencyTTL }, s.Logger)(next)
+}
+
+// purgeIdempotencyKeys drops expired idempotency keys every
+// idempotencyPurgeEvery.
+func (s Server) purgeIdempotencyKeys(ctx context.Context) error {
+	ticker := time.NewTicker(idempotencyPurgeEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			n, err := s.IdempotencyStore.Purge(ctx, now)
+			if err != nil {
+				s.Logger.Error("Could not purge idempotency keys", zap.Error(err))
+				continue
+			}
+			s.Logger.Debug("Purged idempotency keys", zap.Int64("keys", n))
+		}
+	}
+}
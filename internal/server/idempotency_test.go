@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/idempotency"
+)
+
+func TestIdempotentComments(t *testing.T) {
+	t.Parallel()
+	srv, ts := commentsServer(t, comments.NewHub(0, 0, 1))
+
+	post := func(url, key, body string) (*http.Response, string) {
+		req, _ := http.NewRequest("POST", ts.URL+url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.APIKeyHeader, "secret")
+		req.Header.Set(idempotency.Header, key)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res, string(b)
+	}
+
+	first, body := post("/v1/films/1/comments", "c1", `{"body": "great"}`)
+	assert.Equal(t, http.StatusCreated, first.StatusCode)
+	retry, replayed := post("/v1/films/1/comments", "c1", `{"body": "great"}`)
+	assert.Equal(t, http.StatusCreated, retry.StatusCode)
+	assert.Equal(t, "true", retry.Header.Get(idempotency.ReplayedHeader))
+	assert.Equal(t, first.Header.Get("Location"), retry.Header.Get("Location"))
+	assert.Equal(t, body, replayed)
+
+	list, err := srv.CommentRepository.GetByFilm(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// the same key on another route is another request
+	res, _ := post("/v2/films/1/comments", "c1", `{"body": "great"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	res, _ = post("/v1/films/1/comments", "c1", `{"body": "awful"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+
+	// v2 answers in documents
+	res, body = post("/v2/films/1/comments", "c2", `{"body": "great"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	res, replayed = post("/v2/films/1/comments", "c2", `{"body": "great"}`)
+	assert.Equal(t, "true", res.Header.Get(idempotency.ReplayedHeader))
+	assert.Equal(t, body, replayed)
+	res, body = post("/v2/films/1/comments", "c2", `{"body": "awful"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	var doc struct{ Errors []struct{ Status int } }
+	assert.NoError(t, json.Unmarshal([]byte(body), &doc))
+	assert.Equal(t, http.StatusUnprocessableEntity, doc.Errors[0].Status)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/catalog"
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/render"
+)
+
+// importFormats are the formats of the media types files are imported from.
+var importFormats = map[string]string{
+	"text/csv":  catalog.CSV,
+	render.JSON: catalog.JSON,
+}
+
+// importFilmsHandler answers POST /v1/admin/films:import with the report of
+// the import, a 422 when rows were invalid and nothing was written. Files
+// larger than validate reads are imported with the import command.
+func (s Server) importFilmsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, ok := negotiate(w, r, render.JSON)
+		if !ok {
+			return
+		}
+		if s.Importer == nil {
+			problem.Error(w, r, http.StatusNotImplemented, "Imports are not supported by this server")
+			return
+		}
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format, ok := importFormats[contentType]
+		if !ok {
+			problem.Error(w, r, http.StatusUnsupportedMediaType, "Files are imported from text/csv or application/json")
+			return
+		}
+		rows, err := catalog.Parse(r.Body, format)
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, "Could not read the file: "+err.Error())
+			return
+		}
+		// validated as a boolean
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+		report, err := s.Importer.Import(r.Context(), rows, dryRun)
+		if err != nil {
+			s.Logger.Error("Error importing films", zap.Error(err))
+			problem.Error(w, r, http.StatusInternalServerError, "Error importing films")
+			return
+		}
+		if ids := report.FilmIDs(); len(ids) > 0 && s.filmCache != nil {
+			s.filmCache.Invalidate(ids...)
+		}
+
+		body, ok := s.encode(w, r, mediaType, report)
+		if !ok {
+			return
+		}
+		status := http.StatusOK
+		if len(report.Errors) > 0 {
+			status = http.StatusUnprocessableEntity
+		}
+		write(w, status, mediaType, body)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/catalog"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// fakeImporter adds every row with a title as a new film, numbered from
+// 1001, and reports the others.
+type fakeImporter struct{}
+
+func (fakeImporter) Import(ctx context.Context, rows []catalog.Row, dryRun bool) (catalog.Report, error) {
+	report := catalog.Report{DryRun: dryRun, Rows: len(rows), Results: []catalog.Result{}, Errors: []catalog.RowError{}}
+	for i, row := range rows {
+		if row.Title == "" {
+			report.Errors = append(report.Errors, catalog.RowError{Row: row.Line, Field: "title", Reason: "is required"})
+			continue
+		}
+		result := catalog.Result{Row: row.Line, FilmID: 1001 + i, Action: catalog.Inserted}
+		if dryRun {
+			result.FilmID = 0
+		}
+		report.Results = append(report.Results, result)
+		report.Inserted++
+	}
+	if len(report.Errors) > 0 {
+		report.Results, report.Inserted = []catalog.Result{}, 0
+	}
+	return report, nil
+}
+
+func TestImportFilms(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{{FilmID: 1, Title: "ACADEMY DINOSAUR"}})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithImporter(fakeImporter{}),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+		status      int
+		want        string
+	}{
+		{"csv", "/v1/admin/films:import", "text/csv; charset=utf-8", "title,language\nNEW RELEASE,English\n", http.StatusOK,
+			`{"dry_run":false,"rows":1,"inserted":1,"updated":0,"results":[{"row":2,"film_id":1001,"action":"inserted"}],"errors":[]}`},
+		{"json dry run", "/v1/admin/films:import?dry_run=true", "application/json", `[{"title": "NEW RELEASE", "language": "English"}]`, http.StatusOK,
+			`{"dry_run":true,"rows":1,"inserted":1,"updated":0,"results":[{"row":1,"action":"inserted"}],"errors":[]}`},
+		{"invalid rows", "/v1/admin/films:import", "application/json", `[{"language": "English"}, {"title": 1}]`, http.StatusUnprocessableEntity,
+			`{"dry_run":false,"rows":2,"inserted":0,"updated":0,"results":[],"errors":[{"row":1,"field":"title","reason":"is required"},{"row":2,"field":"title","reason":"is required"}]}`},
+		{"unknown column", "/v1/admin/films:import", "text/csv", "title,director\n", http.StatusBadRequest, ""},
+		{"not an array", "/v1/admin/films:import", "application/json", `{"title": "NEW RELEASE"}`, http.StatusBadRequest, ""},
+		{"unsupported media type", "/v1/admin/films:import", "application/xml", "<films/>", http.StatusBadRequest, ""},
+		{"not a boolean", "/v1/admin/films:import?dry_run=maybe", "text/csv", "title\n", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestImportFilmsNotSupported(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.FilmsRead)),
+	)
+	srv.SetupRoutes()
+
+	req := httptest.NewRequest("POST", "/v1/admin/films:import", strings.NewReader("title\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "importing takes films:write")
+
+	srv = NewServer(
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/v1/admin/films:import", strings.NewReader("title\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	srv.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+}
+
+func TestImportFilmsMediaType(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(
+		WithImporter(fakeImporter{}),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+	)
+
+	// the handler does not rely on the route's validation to reject a file
+	req := httptest.NewRequest("POST", "/v1/admin/films:import", strings.NewReader("<films/>"))
+	req.Header.Set("Content-Type", "application/xml")
+	rr := httptest.NewRecorder()
+	srv.importFilmsHandler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+}
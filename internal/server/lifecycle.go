@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Exit codes returned by Start, suitable for os.Exit.
+const (
+	// ExitOK means the server was asked to stop and drained cleanly.
+	ExitOK = 0
+	// ExitServeError means the server could not listen or stopped serving on its own.
+	ExitServeError = 1
+	// ExitShutdownError means draining did not finish in time or a resource failed to close.
+	ExitShutdownError = 2
+)
+
+// Worker is a long running background task. It must return once ctx is done.
+type Worker func(ctx context.Context) error
+
+type closer struct {
+	name string
+	fn   func() error
+}
+
+// Lifecycle owns the background work and resources that live as long as the
+// server: workers are started with Go and cancelled on Shutdown, closers
+// registered with OnShutdown run once the workers have drained.
+type Lifecycle struct {
+	logger *zap.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	closers []closer
+	// closed is set by Shutdown, after which Go starts no worker
+	closed bool
+}
+
+func NewLifecycle(logger *zap.Logger) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Go runs w in its own goroutine until Shutdown is called. Workers cannot be
+// drained once Shutdown has begun waiting, so Go then refuses to start w and
+// returns false.
+func (lc *Lifecycle) Go(name string, w Worker) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.closed {
+		lc.logger.Warn("Background worker not started, shutting down", zap.String("worker", name))
+		return false
+	}
+	lc.wg.Add(1)
+	go func() {
+		defer lc.wg.Done()
+		lc.logger.Info("Background worker started", zap.String("worker", name))
+		err := w(lc.ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			lc.logger.Error("Background worker failed", zap.String("worker", name), zap.Error(err))
+			return
+		}
+		lc.logger.Info("Background worker stopped", zap.String("worker", name))
+	}()
+	return true
+}
+
+// OnShutdown registers fn to release a resource, e.g. the DB pool, after all
+// workers have stopped. Closers run in reverse registration order.
+func (lc *Lifecycle) OnShutdown(name string, fn func() error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.closers = append(lc.closers, closer{name: name, fn: fn})
+}
+
+// Shutdown cancels the workers, waits for them until ctx is done and then runs
+// the closers. Closers run even if the workers did not drain in time.
+func (lc *Lifecycle) Shutdown(ctx context.Context) error {
+	lc.mu.Lock()
+	lc.closed = true
+	lc.mu.Unlock()
+	lc.cancel()
+
+	var errs []error
+	drained := make(chan struct{})
+	go func() {
+		lc.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		lc.logger.Info("Background workers drained")
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for background workers: %w", ctx.Err()))
+	}
+
+	lc.mu.Lock()
+	closers := lc.closers
+	lc.closers = nil
+	lc.mu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		c := closers[i]
+		if err := c.fn(); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s: %w", c.name, err))
+			continue
+		}
+		lc.logger.Info("Closed", zap.String("resource", c.name))
+	}
+
+	return errors.Join(errs...)
+}
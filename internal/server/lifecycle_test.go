@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLifecycleShutdown(t *testing.T) {
+	t.Parallel()
+
+	lc := NewLifecycle(zap.NewNop())
+
+	stopped := make(chan struct{})
+	lc.Go("waiter", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+
+	var order []string
+	lc.OnShutdown("first", func() error {
+		order = append(order, "first")
+		return nil
+	})
+	lc.OnShutdown("second", func() error {
+		order = append(order, "second")
+		return nil
+	})
+
+	assert.NoError(t, lc.Shutdown(context.Background()))
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("worker was not stopped before Shutdown returned")
+	}
+	assert.Equal(t, []string{"second", "first"}, order)
+}
+
+func TestLifecycleShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	lc := NewLifecycle(zap.NewNop())
+	release := make(chan struct{})
+	defer close(release)
+	lc.Go("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	closed := false
+	lc.OnShutdown("db", func() error {
+		closed = true
+		return errors.New("boom")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := lc.Shutdown(ctx)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "closing db: boom")
+	assert.True(t, closed, "closers should run even when workers do not drain")
+}
+
+func TestLifecycleGoAfterShutdown(t *testing.T) {
+	t.Parallel()
+
+	lc := NewLifecycle(zap.NewNop())
+	assert.NoError(t, lc.Shutdown(context.Background()))
+	started := lc.Go("late", func(ctx context.Context) error {
+		t.Error("a worker started after shutdown would never be drained")
+		return nil
+	})
+	assert.False(t, started)
+}
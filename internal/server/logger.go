@@ -5,8 +5,12 @@ import (
 )
 
 func NewLogger() *zap.Logger {
-	atom := zap.NewAtomicLevelAt(zap.DebugLevel)
+	return NewLeveledLogger(zap.NewAtomicLevelAt(zap.DebugLevel))
+}
 
+// NewLeveledLogger builds the logger around atom so the level can be changed
+// at runtime, e.g. on a config reload.
+func NewLeveledLogger(atom zap.AtomicLevel) *zap.Logger {
 	var err error
 	config := zap.NewProductionConfig()
 	config.Development = true
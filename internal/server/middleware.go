@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/dhaskew/rx/internal/auth"
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 )
@@ -12,16 +13,27 @@ func ZapRequestLogger(l *zap.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			r, preflight := withPreflightFlag(r)
 
 			t1 := time.Now()
 			defer func() {
-				l.Info("Served",
+				// preflights answered by the CORS middleware are just noise,
+				// other OPTIONS requests are logged like any request
+				if *preflight {
+					return
+				}
+				fields := []zap.Field{
 					zap.String("proto", r.Proto),
 					zap.String("path", r.URL.Path),
 					zap.Duration("lat", time.Since(t1)),
 					zap.Int("status", ww.Status()),
 					zap.Int("size", ww.BytesWritten()),
-					zap.String("reqId", middleware.GetReqID(r.Context())))
+					zap.String("reqId", middleware.GetReqID(r.Context())),
+				}
+				if p, ok := auth.FromContext(r.Context()); ok {
+					fields = append(fields, zap.String("principal", p.ID), zap.String("authMethod", p.Method))
+				}
+				l.Info("Served", fields...)
 			}()
 
 			next.ServeHTTP(ww, r)
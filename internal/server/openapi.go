@@ -0,0 +1,639 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dhaskew/rx/internal/apiv2"
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/catalog"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/events"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/idempotency"
+	"github.com/dhaskew/rx/internal/openapi"
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/render"
+)
+
+// public marks an operation as not requiring authentication.
+var public = &[]openapi.SecurityRequirement{}
+
+// requires is the security of an operation needing scope, granted by either
+// an API key or a token.
+func requires(scope auth.Scope) *[]openapi.SecurityRequirement {
+	return &[]openapi.SecurityRequirement{
+		{"apiKey": {string(scope)}},
+		{"bearer": {string(scope)}},
+	}
+}
+
+// withErrors adds the responses of the middleware in front of an operation:
+// authentication, authorization and rate limiting.
+func withErrors(responses map[string]openapi.Response, statuses ...string) map[string]openapi.Response {
+	for _, status := range statuses {
+		responses[status] = openapi.RefResponse(status)
+	}
+	return responses
+}
+
+func queryParam(name, description string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func headerParam(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "header", Description: description, Schema: openapi.String("")}
+}
+
+var filmIDPathParam = openapi.Parameter{
+	Name: "filmID", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Description: "Film ID.", Minimum: floatPtr(1)},
+}
+
+var commentIDPathParam = openapi.Parameter{
+	Name: "commentID", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Description: "Comment ID.", Minimum: floatPtr(1)},
+}
+
+// problemResponse is an error response with a problem details body.
+func problemResponse(description string) openapi.Response {
+	return openapi.Response{Description: description, Content: openapi.Content(problem.ContentType, openapi.Ref("Problem"))}
+}
+
+// withV2Errors is withErrors for the v2 routes, whose errors are documents.
+func withV2Errors(responses map[string]openapi.Response, statuses ...string) map[string]openapi.Response {
+	for _, status := range statuses {
+		responses[status] = openapi.RefResponse(status + "V2")
+	}
+	return responses
+}
+
+// errorsV2Response is a v2 error response.
+func errorsV2Response(description string) openapi.Response {
+	return openapi.Response{Description: description, Content: openapi.JSON(openapi.Ref("ErrorsV2"))}
+}
+
+// documentV2 is the schema of a v2 document of data, paged when meta is.
+func documentV2(data *openapi.Schema, paged bool) *openapi.Schema {
+	doc := &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"data": data, "links": openapi.Ref("LinksV2")},
+		Required:   []string{"data", "links"},
+	}
+	if paged {
+		doc.Properties["meta"] = openapi.Ref("MetaV2")
+		doc.Required = append(doc.Required, "meta")
+	}
+	return doc
+}
+
+// pageQueryParams page v2 collections, see pageParams.
+var pageQueryParams = []openapi.Parameter{
+	queryParam("limit", "Items per page.", &openapi.Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(maxPageLimit), Example: defaultPageLimit}),
+	queryParam("offset", "Items to skip.", &openapi.Schema{Type: "integer", Minimum: floatPtr(0)}),
+}
+
+// negotiated is the content of a response rendered in the media type the
+// client asks for. Only JSON has a schema.
+func negotiated(schema *openapi.Schema) map[string]openapi.MediaType {
+	content := make(map[string]openapi.MediaType, len(render.MediaTypes))
+	for _, mediaType := range render.MediaTypes {
+		content[mediaType] = openapi.MediaType{}
+	}
+	content[render.JSON] = openapi.MediaType{Schema: schema}
+	return content
+}
+
+// formatParam overrides the Accept header.
+var formatParam = openapi.Parameter{
+	Name: "format", In: "query", Description: "Media type of the response, overriding Accept.",
+	Schema: &openapi.Schema{Type: "string", Enum: stringsToEnum(render.FormatNames)},
+}
+
+// fieldsParam and includeParam project film responses, see selectionParam.
+var (
+	fieldsParam  = listQueryParam("fields", "Only these fields of each film, film_id always being one.", films.Fields)
+	includeParam = listQueryParam("include", "Embed these relations in each film. CSV has no room for them.", films.Includes)
+)
+
+// listQueryParam describes a comma separated list of some of values.
+func listQueryParam(name, description string, values []string) openapi.Parameter {
+	return commaSeparated(queryParam(name, description, openapi.ArrayOf(&openapi.Schema{Type: "string", Enum: stringsToEnum(values)})))
+}
+
+// commaSeparated has the items of an array parameter separated by commas.
+func commaSeparated(p openapi.Parameter) openapi.Parameter {
+	explode := false
+	p.Style, p.Explode = "form", &explode
+	return p
+}
+
+// filmIDsSchema is a list of IDs to batch get, see writeBatch.
+var filmIDsSchema = &openapi.Schema{
+	Type:     "array",
+	Items:    &openapi.Schema{Type: "integer", Minimum: floatPtr(1)},
+	MaxItems: intPtr(maxBatchIDs),
+}
+
+// filmIDsParam is the IDs of GET /v1/films:batchGet.
+var filmIDsParam = commaSeparated(openapi.Parameter{
+	Name: "ids", In: "query", Required: true, Description: "The IDs of the films, in the order they are returned.", Schema: filmIDsSchema,
+})
+
+// batchContent is a films.Batch, which has no CSV or NDJSON form.
+var batchContent = map[string]openapi.MediaType{
+	render.JSON: {Schema: openapi.Ref("FilmBatch")},
+	render.XML:  {},
+}
+
+func stringsToEnum(values []string) []interface{} {
+	enum := make([]interface{}, len(values))
+	for i, v := range values {
+		enum[i] = v
+	}
+	return enum
+}
+
+// textResponse is a response with a plain text body.
+func textResponse(description string) openapi.Response {
+	return openapi.Response{Description: description, Content: openapi.Content("text/plain", openapi.String(""))}
+}
+
+// idempotencyKeyParam makes a POST safe to retry, see idempotency.Middleware.
+var idempotencyKeyParam = openapi.Parameter{
+	Name: idempotency.Header, In: "header",
+	Description: "Replay the response to the first request with this key instead of repeating it.",
+	Schema:      &openapi.Schema{Type: "string", MinLength: intPtr(1), MaxLength: intPtr(idempotency.MaxKeyLength)},
+}
+
+// cacheParams are the conditional request headers of cached resources.
+var cacheParams = []openapi.Parameter{
+	headerParam("If-None-Match", "Answer 304 if the ETag still matches."),
+	headerParam("If-Modified-Since", "Answer 304 if not modified since, ignored with If-None-Match."),
+}
+
+// cacheHeaders are the validators and caching policy of cached resources.
+var cacheHeaders = map[string]openapi.Header{
+	"ETag":          {Schema: openapi.String("")},
+	"Last-Modified": {Schema: openapi.String("")},
+	"Cache-Control": {Schema: openapi.String("")},
+}
+
+// apiComponents are the schemas, responses and security schemes operations
+// refer to.
+var apiComponents = openapi.Components{
+	Schemas: map[string]*openapi.Schema{
+		"Film":         openapi.SchemaOf(films.Film{}),
+		"FilmBatch":    openapi.SchemaOf(films.Batch{}),
+		"ImportReport": openapi.SchemaOf(catalog.Report{}),
+		"Comment":      openapi.SchemaOf(comments.Comment{}),
+		"Problem":      openapi.SchemaOf(problem.Problem{}),
+		"FilmV2":       openapi.SchemaOf(apiv2.Film{}),
+		"CommentV2":    openapi.SchemaOf(apiv2.Comment{}),
+		"MetaV2":       openapi.SchemaOf(apiv2.Meta{}),
+		"LinksV2":      openapi.SchemaOf(apiv2.Links{}),
+		"ErrorsV2": {
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"errors": openapi.ArrayOf(openapi.SchemaOf(apiv2.Error{}))},
+			Required:   []string{"errors"},
+		},
+		"BatchGetFilms": {
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"ids": filmIDsSchema},
+			Required:   []string{"ids"},
+		},
+		"CreateComment": {
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"body": {Type: "string", MinLength: intPtr(1), MaxLength: intPtr(comments.MaxBodyLength)}},
+			Required:   []string{"body"},
+		},
+		"GraphQLRequest": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"query":         openapi.String("The query document."),
+				"operationName": openapi.String("The operation to run if the document has several."),
+				"variables":     {Type: "object", Description: "Values of the operation's variables."},
+			},
+			Required: []string{"query"},
+		},
+		"GraphQLResponse": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"data":   {Type: "object"},
+				"errors": openapi.ArrayOf(&openapi.Schema{Type: "object"}),
+			},
+		},
+	},
+	Responses: map[string]openapi.Response{
+		"400": problemResponse("Invalid parameters, listed in invalid-params."),
+		"401": {
+			Description: "No valid credentials.",
+			Headers:     map[string]openapi.Header{"WWW-Authenticate": {Schema: openapi.String("")}},
+			Content:     openapi.Content(problem.ContentType, openapi.Ref("Problem")),
+		},
+		"403": problemResponse("The credentials lack a required scope."),
+		"406": problemResponse("None of the accepted media types can be rendered."),
+		"409": problemResponse("A request with the same Idempotency-Key is in progress."),
+		"422": problemResponse("The Idempotency-Key was used with a different request."),
+		"429": {
+			Description: "Rate limited, see the RateLimit-* headers sent with every response.",
+			Headers:     map[string]openapi.Header{"Retry-After": {Schema: openapi.Integer("Seconds to wait.")}},
+			Content:     openapi.Content(problem.ContentType, openapi.Ref("Problem")),
+		},
+		"400V2": errorsV2Response("Invalid parameters, listed in invalidParams."),
+		"401V2": {
+			Description: "No valid credentials.",
+			Headers:     map[string]openapi.Header{"WWW-Authenticate": {Schema: openapi.String("")}},
+			Content:     openapi.JSON(openapi.Ref("ErrorsV2")),
+		},
+		"403V2": errorsV2Response("The credentials lack a required scope."),
+		"409V2": errorsV2Response("A request with the same Idempotency-Key is in progress."),
+		"422V2": errorsV2Response("The Idempotency-Key was used with a different request."),
+		"429V2": {
+			Description: "Rate limited, see the RateLimit-* headers sent with every response.",
+			Headers:     map[string]openapi.Header{"Retry-After": {Schema: openapi.Integer("Seconds to wait.")}},
+			Content:     openapi.JSON(openapi.Ref("ErrorsV2")),
+		},
+	},
+	SecuritySchemes: map[string]openapi.SecurityScheme{
+		"apiKey": {Type: "apiKey", Name: auth.APIKeyHeader, In: "header"},
+		"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+	},
+}
+
+// ratings are the MPAA ratings films are rated with.
+var ratings = stringsToEnum(films.Ratings)
+
+var (
+	pingOp = &openapi.Operation{
+		OperationID: "ping",
+		Summary:     "Liveness check",
+		Tags:        []string{"meta"},
+		Responses:   map[string]openapi.Response{"200": textResponse("Always \".\".")},
+		Security:    public,
+	}
+	metricsOp = &openapi.Operation{
+		OperationID: "getMetrics",
+		Summary:     "Runtime and server metrics (expvar)",
+		Tags:        []string{"meta"},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "Metrics by name.", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+		}, "401", "403"),
+		Security: requires(auth.ReportsRead),
+	}
+	openAPIOp = &openapi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "This document",
+		Tags:        []string{"meta"},
+		Responses:   map[string]openapi.Response{"200": {Description: "The OpenAPI document.", Content: openapi.JSON(&openapi.Schema{Type: "object"})}},
+		Security:    public,
+	}
+	docsOp = &openapi.Operation{
+		OperationID: "getDocs",
+		Summary:     "Swagger UI for this document",
+		Tags:        []string{"meta"},
+		Responses:   map[string]openapi.Response{"200": {Description: "An HTML page.", Content: openapi.Content("text/html", openapi.String(""))}},
+		Security:    public,
+	}
+
+	// the GraphQL endpoint answers errors the GraphQL way, so it is not
+	// validated
+	queryGraphQLOp = &openapi.Operation{
+		OperationID: "queryGraphQL",
+		Summary:     "Run a GraphQL query",
+		Tags:        []string{"graphql"},
+		Parameters: []openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Schema: openapi.String("The query document.")},
+			queryParam("operationName", "The operation to run if the document has several.", openapi.String("")),
+			queryParam("variables", "JSON object of the operation's variables.", openapi.String("")),
+		},
+		Responses: graphQLResponses(),
+		Security:  requires(auth.FilmsRead),
+	}
+	postGraphQLOp = &openapi.Operation{
+		OperationID: "postGraphQL",
+		Summary:     "Run a GraphQL query",
+		Tags:        []string{"graphql"},
+		Parameters:  []openapi.Parameter{idempotencyKeyParam},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("GraphQLRequest"))},
+		Responses:   withErrors(graphQLResponses(), "409", "422"),
+		Security:    requires(auth.FilmsRead),
+	}
+	graphiQLOp = &openapi.Operation{
+		OperationID: "getGraphiQL",
+		Summary:     "GraphiQL IDE, in development only",
+		Tags:        []string{"graphql"},
+		Responses:   map[string]openapi.Response{"200": {Description: "An HTML page.", Content: openapi.Content("text/html", openapi.String(""))}},
+		Security:    public,
+	}
+
+	eventsOp = &openapi.Operation{
+		OperationID: "streamEvents",
+		Summary:     "Stream catalog and inventory events",
+		Description: "A text/event-stream of events, resumable with Last-Event-ID.",
+		Tags:        []string{"events"},
+		Parameters: []openapi.Parameter{
+			queryParam("type", "Comma separated event types.", &openapi.Schema{Type: "string", Example: strings.Join(events.Types, ",")}),
+			queryParam("store", "Only events of this store and those of no store.", &openapi.Schema{Type: "integer", Minimum: floatPtr(1)}),
+			queryParam("last_event_id", "Resume after this event, for clients that cannot send Last-Event-ID.", &openapi.Schema{Type: "integer", Minimum: floatPtr(0)}),
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this event.", Schema: &openapi.Schema{Type: "integer", Minimum: floatPtr(0)}},
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The event stream.", Content: openapi.Content("text/event-stream", openapi.String(""))},
+		}, "400", "401", "403", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+
+	listFilmsOp = &openapi.Operation{
+		OperationID: "listFilms",
+		Summary:     "List films",
+		Tags:        []string{"films"},
+		Parameters: append([]openapi.Parameter{
+			queryParam("rating", "Only films of this rating.", &openapi.Schema{Type: "string", Enum: ratings}),
+			queryParam("category", "Only films of this category, ignored with rating.", openapi.String("")),
+			fieldsParam,
+			includeParam,
+			formatParam,
+		}, cacheParams...),
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The films.", Headers: cacheHeaders, Content: negotiated(openapi.ArrayOf(openapi.Ref("Film")))},
+			"304": {Description: "Not modified."},
+			"500": problemResponse("The films could not be loaded."),
+		}, "400", "401", "403", "406", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	batchGetFilmsByQueryOp = &openapi.Operation{
+		OperationID: "batchGetFilmsByQuery",
+		Summary:     "Get films by ID",
+		Description: "The films are in the order asked for and the IDs of no film are listed as missing.",
+		Tags:        []string{"films"},
+		Parameters: append([]openapi.Parameter{
+			filmIDsParam,
+			fieldsParam,
+			formatParam,
+		}, cacheParams...),
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The films found and the IDs missing.", Headers: cacheHeaders, Content: batchContent},
+			"304": {Description: "Not modified."},
+			"500": problemResponse("The films could not be loaded."),
+		}, "400", "401", "403", "406", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	batchGetFilmsOp = &openapi.Operation{
+		OperationID: "batchGetFilms",
+		Summary:     "Get films by ID",
+		Description: "GET /v1/films:batchGet for lists of IDs too long for a URL. The films are in the order asked for and the IDs of no film are listed as missing.",
+		Tags:        []string{"films"},
+		Parameters:  []openapi.Parameter{fieldsParam, formatParam},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("BatchGetFilms"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The films found and the IDs missing.", Content: batchContent},
+			"415": textResponse("The body is not JSON."),
+			"500": problemResponse("The films could not be loaded."),
+		}, "400", "401", "403", "406", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	importFilmsOp = &openapi.Operation{
+		OperationID: "importFilms",
+		Summary:     "Import films from a CSV or JSON file",
+		Description: "Adds or replaces films, matched by film_id or else by title and release_year, in one transaction. The CSV header names the columns, and JSON files are arrays of objects with the same fields. Nothing is written unless every row is valid.",
+		Tags:        []string{"films"},
+		Parameters: []openapi.Parameter{
+			queryParam("dry_run", "Validate and match the rows without writing them.", openapi.Boolean("")),
+			idempotencyKeyParam,
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"text/csv": {Schema: openapi.String("")},
+			// rows are checked by the import, to report them by position
+			render.JSON: {Schema: openapi.ArrayOf(&openapi.Schema{Type: "object"})},
+		}},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "What was done with every row.", Content: openapi.JSON(openapi.Ref("ImportReport"))},
+			"413": problemResponse("The file is larger than 1 MiB."),
+			"415": problemResponse("The file is neither CSV nor JSON."),
+			"422": {Description: "The errors of the invalid rows, or the Idempotency-Key was used with a different request.", Content: map[string]openapi.MediaType{
+				render.JSON:         {Schema: openapi.Ref("ImportReport")},
+				problem.ContentType: {Schema: openapi.Ref("Problem")},
+			}},
+			"500": problemResponse("The films could not be imported."),
+			"501": problemResponse("Imports are not supported by this server."),
+		}, "400", "401", "403", "406", "409", "429"),
+		Security: requires(auth.FilmsWrite),
+	}
+	getFilmOp = &openapi.Operation{
+		OperationID: "getFilm",
+		Summary:     "Get a film",
+		Tags:        []string{"films"},
+		Parameters:  append([]openapi.Parameter{filmIDPathParam, fieldsParam, includeParam, formatParam}, cacheParams...),
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The film.", Headers: cacheHeaders, Content: negotiated(openapi.Ref("Film"))},
+			"304": {Description: "Not modified."},
+			"404": problemResponse("No such film."),
+			"500": problemResponse("The film could not be loaded."),
+		}, "400", "401", "403", "406", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+
+	listFilmCommentsOp = &openapi.Operation{
+		OperationID: "listFilmComments",
+		Summary:     "List a film's comments, oldest first",
+		Tags:        []string{"comments"},
+		Parameters:  []openapi.Parameter{filmIDPathParam, formatParam},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The comments.", Content: negotiated(openapi.ArrayOf(openapi.Ref("Comment")))},
+		}, "400", "401", "403", "406", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	getFilmCommentOp = &openapi.Operation{
+		OperationID: "getFilmComment",
+		Summary:     "Get a comment on a film",
+		Tags:        []string{"comments"},
+		Parameters:  []openapi.Parameter{filmIDPathParam, commentIDPathParam, formatParam},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The comment.", Content: negotiated(openapi.Ref("Comment"))},
+			"404": problemResponse("The film has no such comment."),
+			"500": problemResponse("The comment could not be loaded."),
+		}, "400", "401", "403", "406", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	createFilmCommentOp = &openapi.Operation{
+		OperationID: "createFilmComment",
+		Summary:     "Comment on a film",
+		Description: "The author is the authenticated principal.",
+		Tags:        []string{"comments"},
+		Parameters:  []openapi.Parameter{filmIDPathParam, formatParam, idempotencyKeyParam},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("CreateComment"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"201": {
+				Description: "The comment.",
+				Headers:     map[string]openapi.Header{"Location": {Schema: openapi.String("")}},
+				Content:     negotiated(openapi.Ref("Comment")),
+			},
+			"404": problemResponse("No such film."),
+			"415": textResponse("The body is not JSON."),
+		}, "400", "401", "403", "406", "409", "422", "429"),
+		Security: requires(auth.CommentsWrite),
+	}
+	listFilmsV2Op = &openapi.Operation{
+		OperationID: "listFilmsV2",
+		Summary:     "List a page of films",
+		Tags:        []string{"v2"},
+		Parameters: append(append([]openapi.Parameter{
+			queryParam("rating", "Only films of this rating.", &openapi.Schema{Type: "string", Enum: ratings}),
+			queryParam("category", "Only films of this category, ignored with rating.", openapi.String("")),
+		}, pageQueryParams...), cacheParams...),
+		Responses: withV2Errors(map[string]openapi.Response{
+			"200": {Description: "The films, with self, next and prev links.", Headers: cacheHeaders, Content: openapi.JSON(documentV2(openapi.ArrayOf(openapi.Ref("FilmV2")), true))},
+			"304": {Description: "Not modified."},
+			"500": errorsV2Response("The films could not be loaded."),
+		}, "400", "401", "403", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	getFilmV2Op = &openapi.Operation{
+		OperationID: "getFilmV2",
+		Summary:     "Get a film",
+		Tags:        []string{"v2"},
+		Parameters:  append([]openapi.Parameter{filmIDPathParam}, cacheParams...),
+		Responses: withV2Errors(map[string]openapi.Response{
+			"200": {Description: "The film, with self and comments links.", Headers: cacheHeaders, Content: openapi.JSON(documentV2(openapi.Ref("FilmV2"), false))},
+			"304": {Description: "Not modified."},
+			"404": errorsV2Response("No such film."),
+			"500": errorsV2Response("The film could not be loaded."),
+		}, "400", "401", "403", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	listFilmCommentsV2Op = &openapi.Operation{
+		OperationID: "listFilmCommentsV2",
+		Summary:     "List a film's comments, oldest first",
+		Tags:        []string{"v2"},
+		Parameters:  []openapi.Parameter{filmIDPathParam},
+		Responses: withV2Errors(map[string]openapi.Response{
+			"200": {Description: "The comments, with self and film links.", Content: openapi.JSON(documentV2(openapi.ArrayOf(openapi.Ref("CommentV2")), false))},
+		}, "400", "401", "403", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	getFilmCommentV2Op = &openapi.Operation{
+		OperationID: "getFilmCommentV2",
+		Summary:     "Get a comment on a film",
+		Tags:        []string{"v2"},
+		Parameters:  []openapi.Parameter{filmIDPathParam, commentIDPathParam},
+		Responses: withV2Errors(map[string]openapi.Response{
+			"200": {Description: "The comment, with self and film links.", Content: openapi.JSON(documentV2(openapi.Ref("CommentV2"), false))},
+			"404": errorsV2Response("The film has no such comment."),
+			"500": errorsV2Response("The comment could not be loaded."),
+		}, "400", "401", "403", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+	createFilmCommentV2Op = &openapi.Operation{
+		OperationID: "createFilmCommentV2",
+		Summary:     "Comment on a film",
+		Description: "The author is the authenticated principal.",
+		Tags:        []string{"v2"},
+		Parameters:  []openapi.Parameter{filmIDPathParam, idempotencyKeyParam},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("CreateComment"))},
+		Responses: withV2Errors(map[string]openapi.Response{
+			"201": {
+				Description: "The comment, with self and film links.",
+				Headers:     map[string]openapi.Header{"Location": {Schema: openapi.String("")}},
+				Content:     openapi.JSON(documentV2(openapi.Ref("CommentV2"), false)),
+			},
+			"404": errorsV2Response("No such film."),
+		}, "400", "401", "403", "409", "422", "429"),
+		Security: requires(auth.CommentsWrite),
+	}
+
+	watchFilmCommentsOp = &openapi.Operation{
+		OperationID: "watchFilmComments",
+		Summary:     "Receive a film's new comments over a WebSocket",
+		Description: "Every new comment is sent as a JSON Comment message. Browsers, which cannot set headers on the handshake, offer their credentials as subprotocols: rx.comments and bearer.<token> or apikey.<key>.",
+		Tags:        []string{"comments"},
+		Parameters: []openapi.Parameter{
+			filmIDPathParam,
+			headerParam("Sec-WebSocket-Protocol", "rx.comments, and bearer.<token> or apikey.<key> for clients that cannot send Authorization or X-API-Key."),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"101": {Description: "Switching to the WebSocket protocol."},
+			"404": problemResponse("No such film."),
+			"503": {
+				Description: "Too many connections.",
+				Headers:     map[string]openapi.Header{"Retry-After": {Schema: openapi.Integer("Seconds to wait.")}},
+				Content:     openapi.Content(problem.ContentType, openapi.Ref("Problem")),
+			},
+		}, "400", "401", "403", "429"),
+		Security: requires(auth.FilmsRead),
+	}
+)
+
+func graphQLResponses() map[string]openapi.Response {
+	return withErrors(map[string]openapi.Response{
+		"200": {Description: "The result, with errors if some fields failed.", Content: openapi.JSON(openapi.Ref("GraphQLResponse"))},
+		"400": {Description: "The query is invalid or too expensive.", Content: openapi.JSON(openapi.Ref("GraphQLResponse"))},
+	}, "401", "403", "429")
+}
+
+// openAPIDocument describes the routes set up by SetupRoutes. The GraphiQL
+// IDE is only described in development, where it is served.
+func openAPIDocument(development bool) *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "rx",
+			Version:     "1.0.0",
+			Description: "Films of the mockbuster rental stores. Collections and films can be rendered as JSON, NDJSON, CSV or XML, chosen with Accept or the format parameter. Errors are RFC 7807 problem details unless noted otherwise. The API version can also be chosen with the API-Version header or an application/vnd.mockbuster.v<N>+json Accept media type, which take precedence over the path prefix.",
+		},
+		Tags: []openapi.Tag{
+			{Name: "films", Description: "The film catalog."},
+			{Name: "comments", Description: "Comments on films."},
+			{Name: "events", Description: "Catalog and inventory changes."},
+			{Name: "graphql", Description: "The GraphQL API."},
+			{Name: "meta", Description: "Health, metrics and documentation."},
+			{Name: "v2", Description: "Films and comments in v2 documents: data, meta, links and errors in camelCase."},
+		},
+		Components: apiComponents,
+	}
+
+	doc.Add(http.MethodGet, "/ping", pingOp)
+	doc.Add(http.MethodGet, "/debug/vars", metricsOp)
+	doc.Add(http.MethodGet, "/openapi.json", openAPIOp)
+	doc.Add(http.MethodGet, "/docs", docsOp)
+	doc.Add(http.MethodGet, "/graphql", queryGraphQLOp)
+	doc.Add(http.MethodPost, "/graphql", postGraphQLOp)
+	if development {
+		doc.Add(http.MethodGet, "/graphiql", graphiQLOp)
+	}
+	doc.Add(http.MethodGet, "/v1/events", eventsOp)
+	doc.Add(http.MethodGet, "/v1/films", listFilmsOp)
+	doc.Add(http.MethodGet, "/v1/films:batchGet", batchGetFilmsByQueryOp)
+	doc.Add(http.MethodPost, "/v1/films:batchGet", batchGetFilmsOp)
+	doc.Add(http.MethodPost, "/v1/admin/films:import", importFilmsOp)
+	doc.Add(http.MethodGet, "/v1/films/{filmID}", getFilmOp)
+	doc.Add(http.MethodGet, "/v1/films/{filmID}/comments", listFilmCommentsOp)
+	doc.Add(http.MethodPost, "/v1/films/{filmID}/comments", createFilmCommentOp)
+	doc.Add(http.MethodGet, "/v1/films/{filmID}/comments/{commentID}", getFilmCommentOp)
+	doc.Add(http.MethodGet, "/v1/films/{filmID}/comments/ws", watchFilmCommentsOp)
+	doc.Add(http.MethodGet, "/v2/films", listFilmsV2Op)
+	doc.Add(http.MethodGet, "/v2/films/{filmID}", getFilmV2Op)
+	doc.Add(http.MethodGet, "/v2/films/{filmID}/comments", listFilmCommentsV2Op)
+	doc.Add(http.MethodPost, "/v2/films/{filmID}/comments", createFilmCommentV2Op)
+	doc.Add(http.MethodGet, "/v2/films/{filmID}/comments/{commentID}", getFilmCommentV2Op)
+	return doc
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// openAPIHandler serves doc as JSON.
+func openAPIHandler(doc *openapi.Document) http.HandlerFunc {
+	res, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(res)
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/openapi"
+)
+
+// specPath turns a chi route into an OpenAPI path: mounted routers register
+// their root with a trailing slash.
+func specPath(route string) string {
+	if route != "/" {
+		route = strings.TrimSuffix(route, "/")
+	}
+	// {commentID:[0-9]+} is {commentID} in the document
+	return routePattern.ReplaceAllString(route, "{$1}")
+}
+
+var routePattern = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+// TestOpenAPICoversRoutes fails when a route is added without being
+// described, or a described route is removed.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	t.Parallel()
+	for _, env := range []string{EnvDevelopment, EnvProduction} {
+		env := env
+		t.Run(env, func(t *testing.T) {
+			t.Parallel()
+			mem := films.NewMemFilmRepository([]films.Film{})
+			srv := NewServer(WithFilmRepository(&mem), WithLogger(zap.NewNop()), WithRouterFunc(chi.NewRouter))
+			settings := srv.Settings()
+			settings.Environment = env
+			srv.settings.current.Store(settings)
+			srv.SetupRoutes()
+			doc := openAPIDocument(settings.Development())
+
+			routed := map[string]bool{}
+			err := chi.Walk(srv.Router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+				path := specPath(route)
+				routed[method+" "+path] = true
+				assert.NotNil(t, doc.Operation(method, path), "%s %s is not in the OpenAPI document", method, path)
+				return nil
+			})
+			assert.NoError(t, err)
+
+			for path, item := range doc.Paths {
+				for method := range item {
+					key := strings.ToUpper(method) + " " + path
+					// answered by the heartbeat middleware rather than a route
+					if key == "GET /ping" {
+						continue
+					}
+					assert.True(t, routed[key], "%s is documented but not routed", key)
+				}
+			}
+		})
+	}
+}
+
+// TestOpenAPIRefs checks that every reference resolves.
+func TestOpenAPIRefs(t *testing.T) {
+	t.Parallel()
+	doc := openAPIDocument(true)
+
+	var checkSchema func(s *openapi.Schema)
+	checkSchema = func(s *openapi.Schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			assert.Contains(t, doc.Components.Schemas, strings.TrimPrefix(s.Ref, "#/components/schemas/"))
+		}
+		checkSchema(s.Items)
+		checkSchema(s.AdditionalProperties)
+		for _, p := range s.Properties {
+			checkSchema(p)
+		}
+	}
+	checkContent := func(content map[string]openapi.MediaType) {
+		for _, m := range content {
+			checkSchema(m.Schema)
+		}
+	}
+
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			for _, p := range op.Parameters {
+				checkSchema(p.Schema)
+			}
+			if op.RequestBody != nil {
+				checkContent(op.RequestBody.Content)
+			}
+			for _, res := range op.Responses {
+				if res.Ref != "" {
+					assert.Contains(t, doc.Components.Responses, strings.TrimPrefix(res.Ref, "#/components/responses/"))
+				}
+				checkContent(res.Content)
+			}
+			if op.Security != nil {
+				for _, req := range *op.Security {
+					for scheme := range req {
+						assert.Contains(t, doc.Components.SecuritySchemes, scheme)
+					}
+				}
+			}
+		}
+	}
+	for _, res := range doc.Components.Responses {
+		checkContent(res.Content)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{})
+	srv := NewServer(WithFilmRepository(&mem), WithLogger(zap.NewNop()), WithRouterFunc(chi.NewRouter))
+	srv.SetupRoutes()
+
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+		if path == "/openapi.json" {
+			var doc map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+			assert.Equal(t, "3.1.0", doc["openapi"])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// ReloadFunc is called after reloadable settings have been swapped in.
+type ReloadFunc func(old, new Settings)
+
+// settingsStore holds the live settings so handlers always read a consistent
+// snapshot while a reload swaps in a new one.
+type settingsStore struct {
+	current atomic.Value // Settings
+
+	mu    sync.Mutex
+	hooks []ReloadFunc
+}
+
+func newSettingsStore(s Settings) *settingsStore {
+	store := &settingsStore{}
+	store.current.Store(s)
+	return store
+}
+
+func (st *settingsStore) load() Settings {
+	return st.current.Load().(Settings)
+}
+
+func (st *settingsStore) onReload(fn ReloadFunc) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.hooks = append(st.hooks, fn)
+}
+
+// swap stores next and runs the reload hooks. The lock serialises reloads so
+// hooks see them in order.
+func (st *settingsStore) swap(next Settings) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	old := st.load()
+	st.current.Store(next)
+	for _, fn := range st.hooks {
+		fn(old, next)
+	}
+}
+
+var defaultSettings, _ = ParseSettings(nil)
+
+func WithConfig(cfg *Config) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.Config = cfg
+		settings, err := cfg.Settings()
+		if err != nil {
+			cfg.Logger.Warn("Invalid settings, using defaults", zap.Error(err))
+			settings = defaultSettings
+		}
+		s.settings.current.Store(settings)
+		return s
+	}
+}
+
+// WithLogLevel lets reloads change the level of the logger built around level.
+func WithLogLevel(level zap.AtomicLevel) func(*Server) *Server {
+	return func(s *Server) *Server {
+		level.SetLevel(s.Settings().LogLevel)
+		s.OnReload(func(old, new Settings) {
+			level.SetLevel(new.LogLevel)
+		})
+		return s
+	}
+}
+
+// Settings returns the current settings snapshot.
+func (s Server) Settings() Settings {
+	return s.settings.load()
+}
+
+// OnReload registers fn to be called whenever a reload changes settings.
+func (s Server) OnReload(fn ReloadFunc) {
+	s.settings.onReload(fn)
+}
+
+// ReloadConfig re-reads the env file, validates it and swaps in any changed
+// reloadable settings. Changes to settings that need a restart are logged and
+// ignored.
+func (s Server) ReloadConfig() error {
+	if s.Config == nil {
+		return errors.New("server has no config to reload")
+	}
+	if _, err := s.Config.Reload(); err != nil {
+		return err
+	}
+	next, err := s.Config.Settings()
+	if err != nil {
+		return err
+	}
+
+	current := s.Settings()
+	changes := DiffSettings(current, next)
+	if len(changes) == 0 {
+		s.Logger.Info("Config reloaded, nothing changed")
+		return nil
+	}
+
+	reloaded := 0
+	for _, c := range changes {
+		if !c.Reloadable {
+			s.Logger.Warn("Setting requires a restart, ignoring change",
+				zap.String("key", c.Key), zap.String("old", c.Old), zap.String("new", c.New))
+			continue
+		}
+		s.Logger.Info("Setting changed",
+			zap.String("key", c.Key), zap.String("old", c.Old), zap.String("new", c.New))
+		reloaded++
+	}
+	if reloaded > 0 {
+		s.settings.swap(current.withReloadable(next))
+	}
+	s.Logger.Info("Config reloaded", zap.Int("changed", reloaded), zap.Int("ignored", len(changes)-reloaded))
+	return nil
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func writeFile(t *testing.T, path string, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.env")
+	writeFile(t, path, "HTTP_PORT: \"8080\"\nLOG_LEVEL: \"info\"\n")
+
+	log := zap.NewNop()
+	cfg := &Config{Logger: log}
+	_, err := cfg.Load(path)
+	assert.NoError(t, err)
+
+	level := zap.NewAtomicLevel()
+	srv := NewServer(
+		WithLogger(log),
+		WithConfig(cfg),
+		WithLogLevel(level),
+		WithRouterFunc(chi.NewRouter),
+	)
+	assert.Equal(t, zapcore.InfoLevel, level.Level())
+
+	var reloads int
+	srv.OnReload(func(old, new Settings) { reloads++ })
+
+	// reloadable change is applied, the port change is ignored
+	writeFile(t, path, "HTTP_PORT: \"9090\"\nLOG_LEVEL: \"error\"\n")
+	assert.NoError(t, srv.ReloadConfig())
+	assert.Equal(t, zapcore.ErrorLevel, level.Level())
+	assert.Equal(t, zapcore.ErrorLevel, srv.Settings().LogLevel)
+	assert.Equal(t, "8080", srv.Settings().HTTPPort)
+	assert.Equal(t, 1, reloads)
+
+	// invalid files are rejected and the current settings kept
+	writeFile(t, path, "HTTP_PORT: \"8080\"\nLOG_LEVEL: \"loud\"\n")
+	assert.Error(t, srv.ReloadConfig())
+	assert.Equal(t, zapcore.ErrorLevel, srv.Settings().LogLevel)
+	assert.Equal(t, 1, reloads)
+	// and so are the loaded values
+	settings, err := cfg.Settings()
+	assert.NoError(t, err)
+	assert.Equal(t, zapcore.ErrorLevel, settings.LogLevel)
+}
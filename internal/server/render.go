@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/render"
+)
+
+// negotiate picks the media type of the response from the Accept header or
+// the format query parameter, answering 406 when the client accepts none of
+// mediaTypes, by default render.MediaTypes.
+func negotiate(w http.ResponseWriter, r *http.Request, mediaTypes ...string) (string, bool) {
+	if len(mediaTypes) == 0 {
+		mediaTypes = render.MediaTypes
+	}
+	addVary(w.Header(), "Accept")
+	mediaType, ok := render.Negotiate(r, mediaTypes...)
+	if !ok {
+		problem.Error(w, r, http.StatusNotAcceptable, "Acceptable media types are "+strings.Join(mediaTypes, ", "))
+		return "", false
+	}
+	return mediaType, true
+}
+
+// addVary adds a header to Vary unless it is already listed.
+func addVary(h http.Header, header string) {
+	for _, v := range h.Values("Vary") {
+		if containsFold(strings.Split(v, ","), header) {
+			return
+		}
+	}
+	h.Add("Vary", header)
+}
+
+// encode renders v as mediaType, answering 406 if v cannot be and 500 if
+// encoding fails. fields are those of a projected v, see render.Encode.
+func (s Server) encode(w http.ResponseWriter, r *http.Request, mediaType string, v interface{}, fields ...string) ([]byte, bool) {
+	body, err := render.Encode(mediaType, v, fields...)
+	if err == render.ErrUnsupported {
+		problem.Error(w, r, http.StatusNotAcceptable, "The response cannot be rendered as "+mediaType)
+		return nil, false
+	} else if err != nil {
+		s.Logger.Error("Error rendering response", zap.String("mediaType", mediaType), zap.Error(err))
+		problem.Error(w, r, http.StatusInternalServerError, "Error rendering response")
+		return nil, false
+	}
+	return body, true
+}
+
+// write sends a body rendered as mediaType.
+func write(w http.ResponseWriter, status int, mediaType string, body []byte) {
+	w.Header().Set("Content-Type", render.ContentType(mediaType))
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// respond negotiates the media type of v, renders and sends it.
+func (s Server) respond(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	mediaType, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	body, ok := s.encode(w, r, mediaType, v)
+	if !ok {
+		return
+	}
+	write(w, status, mediaType, body)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+func TestFilmsContentNegotiation(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{
+		{FilmID: 1, Title: "ACADEMY DINOSAUR", Rating: "PG", LastUpdate: time.Date(2006, 2, 15, 5, 3, 42, 0, time.UTC)},
+	})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"csv", "/v1/films", "text/csv", http.StatusOK, "text/csv; charset=utf-8; header=present",
+			"film_id,title,description,release_year,rating,category\n1,ACADEMY DINOSAUR,,0,PG,\n"},
+		{"ndjson", "/v1/films?format=ndjson", "", http.StatusOK, "application/x-ndjson",
+			`{"film_id":1,"title":"ACADEMY DINOSAUR","rating":"PG"}` + "\n"},
+		{"xml", "/v1/films/1", "application/xml", http.StatusOK, "application/xml",
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<film>\n\t<film_id>1</film_id>\n\t<title>ACADEMY DINOSAUR</title>\n\t<rating>PG</rating>\n</film>"},
+		{"not acceptable", "/v1/films", "text/html", http.StatusNotAcceptable, problem.ContentType, ""},
+		{"unknown format", "/v1/films?format=yaml", "", http.StatusBadRequest, problem.ContentType, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"))
+			if tt.status != http.StatusBadRequest {
+				assert.Contains(t, rr.Header().Values("Vary"), "Accept")
+			}
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+		})
+	}
+
+	// representations are cached separately
+	etag := func(accept string) string {
+		req := httptest.NewRequest("GET", "/v1/films", nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		return rr.Header().Get("ETag")
+	}
+	assert.NotEqual(t, etag("application/json"), etag("text/csv"))
+}
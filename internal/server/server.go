@@ -3,15 +3,29 @@ package server
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
-	"log"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/catalog"
+	"github.com/dhaskew/rx/internal/comments"
+	"github.com/dhaskew/rx/internal/compress"
+	"github.com/dhaskew/rx/internal/events"
 	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/gql"
+	"github.com/dhaskew/rx/internal/idempotency"
+	"github.com/dhaskew/rx/internal/openapi"
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/ratelimit"
+	"github.com/dhaskew/rx/internal/rpc"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
@@ -24,6 +38,27 @@ type Server struct {
 	Router         *chi.Mux
 	MoviesDB       *sql.DB
 	FilmRepository films.FilmRepository
+	Config         *Config
+	Lifecycle      *Lifecycle
+	Authenticators []auth.Authenticator
+	RateLimitStore ratelimit.Store
+	// IdempotencyStore keeps the responses to POSTs with an Idempotency-Key
+	IdempotencyStore idempotency.Store
+	// CatalogListener is run alongside the server when set
+	CatalogListener   *films.ChangeListener
+	CommentRepository comments.CommentRepository
+	// RelationRepository loads the actors, categories, etc. of films
+	RelationRepository films.RelationRepository
+	// CommentHub pushes new comments to live subscribers
+	CommentHub *comments.Hub
+	// Importer writes the films imported at /v1/admin/films:import, which
+	// is not supported when nil
+	Importer catalog.Importer
+	// Events is streamed at /v1/events
+	Events    *events.Broker
+	filmCache *films.CachedRepository
+	settings  *settingsStore
+	run       *runState
 	*http.Server
 }
 
@@ -33,12 +68,38 @@ func NewServer(options ...func(*Server) *Server) *Server {
 			ReadTimeout:  5 * time.Second,
 			WriteTimeout: 10 * time.Second,
 			IdleTimeout:  15 * time.Second},
+		settings:         newSettingsStore(defaultSettings),
+		run:              &runState{ready: make(chan struct{})},
+		RateLimitStore:   ratelimit.NewMemoryStore(),
+		IdempotencyStore: idempotency.NewMemoryStore(),
 	}
 
 	for _, o := range options {
 		o(server)
 	}
 
+	if server.Lifecycle == nil {
+		server.Lifecycle = NewLifecycle(server.Logger)
+	}
+	if server.Events == nil {
+		settings := server.Settings()
+		server.Events = events.NewBroker(settings.EventsReplaySize, settings.EventsClientQueue)
+	}
+	broker := server.Events
+	publishExpvar("events", func() interface{} { return broker.Stats() })
+	if server.CommentRepository == nil {
+		server.CommentRepository = comments.NewMemCommentRepository()
+	}
+	if server.RelationRepository == nil {
+		server.RelationRepository = films.NewMemRelationRepository(films.MemRelations{})
+	}
+	if server.CommentHub == nil {
+		settings := server.Settings()
+		server.CommentHub = comments.NewHub(settings.CommentsWSMaxConnections, settings.CommentsWSMaxPerFilm, settings.CommentsWSQueue)
+	}
+	hub := server.CommentHub
+	publishExpvar("comment_hub", func() interface{} { return hub.Stats() })
+
 	return server
 }
 
@@ -49,6 +110,65 @@ func WithFilmRepository(rep *films.FilmRepository) func(*Server) *Server {
 	}
 }
 
+func WithLifecycle(lc *Lifecycle) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.Lifecycle = lc
+		return s
+	}
+}
+
+func WithAuthenticators(authenticators ...auth.Authenticator) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.Authenticators = authenticators
+		return s
+	}
+}
+
+func WithRateLimitStore(store ratelimit.Store) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.RateLimitStore = store
+		return s
+	}
+}
+
+// WithFilmCache serves films through cache, keeping its TTLs in line with the
+// settings and publishing its stats as the "film_cache" expvar.
+func WithFilmCache(cache *films.CachedRepository) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.FilmRepository = cache
+		s.filmCache = cache
+		cache.SetTTLs(s.Settings().FilmCacheTTLs())
+		s.OnReload(func(old, new Settings) {
+			cache.SetTTLs(new.FilmCacheTTLs())
+		})
+		publishExpvar("film_cache", func() interface{} { return cache.Stats() })
+		return s
+	}
+}
+
+func WithRelationRepository(repo films.RelationRepository) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.RelationRepository = repo
+		return s
+	}
+}
+
+// WithCatalogListener runs l while serving and evicts cached films when the
+// catalog changes.
+func WithCatalogListener(l *films.ChangeListener) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.CatalogListener = l
+		return s
+	}
+}
+
+func WithImporter(im catalog.Importer) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.Importer = im
+		return s
+	}
+}
+
 func WithPort(port string) func(*Server) *Server {
 	return func(s *Server) *Server {
 		s.Addr = ":" + port
@@ -83,13 +203,29 @@ func apiVersionCtx(version string) func(next http.Handler) http.Handler {
 	}
 }
 
+// rateLimit limits the route group with the current RATE_LIMITS.
+func (s Server) rateLimit(group string) func(next http.Handler) http.Handler {
+	return ratelimit.Middleware(group, s.RateLimitStore, func() ratelimit.Config {
+		settings := s.Settings()
+		return ratelimit.Config{Limits: settings.RateLimits, TrustedProxies: settings.TrustedProxies}
+	}, s.Logger)
+}
+
 func (s Server) SetupRoutes() {
 	//global middleware - all routes
 	s.Router.Use(middleware.RequestID)
+	s.Router.Use(auth.WebSocketCredentials)
+	s.Router.Use(auth.Authenticate(s.Authenticators...))
 	s.Router.Use(ZapRequestLogger(s.Logger))
 	s.Router.Use(middleware.Recoverer)
+	s.Router.Use(compress.Decompress)
+	s.Router.Use(compress.Middleware(func() compress.Options { return s.Settings().CompressionOptions() }))
 	s.Router.Use(middleware.Heartbeat("/ping"))
-	s.Router.Use(middleware.Timeout(60 * time.Second))
+	s.Router.Use(s.negotiateVersion)
+
+	// applied per group rather than globally so that streaming routes can
+	// stay open
+	timeout := middleware.Timeout(60 * time.Second)
 
 	// if you want to use other buckets than the default (300, 1200, 5000) you can run:
 	// m := negroniprometheus.NewMiddleware("serviceName", 400, 1600, 700)
@@ -100,19 +236,76 @@ func (s Server) SetupRoutes() {
 	// /ping route provide by chi heartbeat middleware
 	//s.Router.Get("/healthcheck", s.HealthcheckHandler())
 	//s.Router.Get("/metrics", s.MetricsHandler())
+	s.Router.With(timeout, auth.Require(auth.ReportsRead)).Get("/debug/vars", expvar.Handler().ServeHTTP)
+
+	// API documentation, public
+	s.Router.With(timeout).Get("/openapi.json", openAPIHandler(openAPIDocument(s.Settings().Development())))
+	s.Router.With(timeout).Get("/docs", openapi.SwaggerUI("/openapi.json"))
+
+	// GraphQL, see internal/gql
+	s.Router.Group(func(r chi.Router) {
+		r.Use(timeout)
+		r.Use(CORS(func() CORSOptions { return s.Settings().CORSOptions() }))
+		r.Use(s.rateLimit("graphql"))
+		r.Use(auth.Require(auth.FilmsRead))
+		r.Use(s.idempotent)
+		graphql := gql.NewHandler(gql.Resolver{
+			Films:     s.FilmRepository,
+			Relations: s.RelationRepository,
+			Comments:  s.CommentRepository,
+		}, func() gql.Limits {
+			settings := s.Settings()
+			return gql.Limits{MaxDepth: settings.GraphQLMaxDepth, MaxComplexity: settings.GraphQLMaxComplexity}
+		})
+		r.Method(http.MethodGet, "/graphql", graphql)
+		r.Method(http.MethodPost, "/graphql", graphql)
+	})
+	if s.Settings().Development() {
+		s.Router.Get("/graphiql", gql.GraphiQL("/graphql"))
+	}
 
 	// API version 1.
 	s.Router.Route("/v1", func(v1 chi.Router) {
+		v1.Use(CORS(func() CORSOptions { return s.Settings().CORSOptions() }))
 		v1.Use(apiVersionCtx("v1"))
+		v1.Use(Deprecate(func() Deprecation { return s.Settings().APIV1Deprecation() }, s.v2Successor))
+		v1.With(s.rateLimit("events"), auth.Require(auth.FilmsRead), s.validate(eventsOp)).Get("/events", s.eventsHandler())
+		v1.With(s.rateLimit("films"), timeout, auth.Require(auth.FilmsRead), s.validate(batchGetFilmsByQueryOp)).Get("/films:batchGet", s.batchGetFilmsHandler())
+		v1.With(s.rateLimit("films"), timeout, EnsureJSONContentType, auth.Require(auth.FilmsRead), s.validate(batchGetFilmsOp)).Post("/films:batchGet", s.batchGetFilmsHandler())
+		v1.With(s.rateLimit("films"), timeout, auth.Require(auth.FilmsWrite), s.idempotent, s.validate(importFilmsOp)).Post("/admin/films:import", s.importFilmsHandler())
 		v1.Mount("/films", func() http.Handler {
 			v1Routes := chi.NewRouter()
-			v1Routes.Get("/", s.filmsHandler())
-			//v1Routes.With(EnsureJSONContentType).Post("/", s.CreateFilmCommentHandler())
-			v1Routes.Get("/{filmID}", s.getFilmHandler())
+			v1Routes.Use(s.rateLimit("films"))
+			v1Routes.Group(func(r chi.Router) {
+				r.Use(timeout)
+				r.With(auth.Require(auth.FilmsRead), s.validate(getFilmOp)).Get("/{filmID}", s.getFilmHandler())
+				r.With(auth.Require(auth.FilmsRead), s.validate(listFilmCommentsOp)).Get("/{filmID}/comments", s.filmCommentsHandler())
+				r.With(auth.Require(auth.FilmsRead), s.validate(getFilmCommentOp)).Get("/{filmID}/comments/{commentID:[0-9]+}", s.getFilmCommentHandler())
+				r.With(EnsureJSONContentType, auth.Require(auth.CommentsWrite), s.idempotent, s.validate(createFilmCommentOp)).Post("/{filmID}/comments", s.createFilmCommentHandler())
+			})
+			// the collection is streamed, see streamFilms
+			v1Routes.With(auth.Require(auth.FilmsRead), s.validate(listFilmsOp)).Get("/", s.filmsHandler())
+			v1Routes.With(auth.Require(auth.FilmsRead), s.validate(watchFilmCommentsOp)).Get("/{filmID}/comments/ws", s.filmCommentsSocketHandler())
 			return v1Routes
 		}())
 	})
 
+	// API version 2, the resources of v1 in envelopes, see internal/apiv2.
+	s.Router.Route("/v2", func(v2 chi.Router) {
+		v2.Use(CORS(func() CORSOptions { return s.Settings().CORSOptions() }))
+		v2.Use(apiVersionCtx("v2"))
+		v2.Use(apiV2Problems)
+		v2.Route("/films", func(r chi.Router) {
+			r.Use(s.rateLimit("films"))
+			r.Use(timeout)
+			r.With(auth.Require(auth.FilmsRead), s.validate(listFilmsV2Op)).Get("/", s.filmsV2Handler())
+			r.With(auth.Require(auth.FilmsRead), s.validate(getFilmV2Op)).Get("/{filmID}", s.getFilmV2Handler())
+			r.With(auth.Require(auth.FilmsRead), s.validate(listFilmCommentsV2Op)).Get("/{filmID}/comments", s.filmCommentsV2Handler())
+			r.With(auth.Require(auth.FilmsRead), s.validate(getFilmCommentV2Op)).Get("/{filmID}/comments/{commentID:[0-9]+}", s.getFilmCommentV2Handler())
+			r.With(auth.Require(auth.CommentsWrite), s.idempotent, s.validate(createFilmCommentV2Op)).Post("/{filmID}/comments", s.createFilmCommentV2Handler())
+		})
+	})
+
 	s.Logger.Info("Done setting up routing")
 }
 
@@ -125,163 +318,317 @@ func (s Server) PrintRoutes() {
 	s.Logger.Info("Done printing routes")
 }
 
-func (s Server) Start() {
-	s.Logger.Info("Starting server ...")
-	defer func() {
-		_ = s.Logger.Sync()
-	}()
+// ErrShutdown is wrapped by the errors Run returns when draining did not
+// complete cleanly.
+var ErrShutdown = errors.New("shutdown did not complete")
 
-	s.SetupRoutes()
-	s.PrintRoutes()
-	//s.SetupTracing()
+// runState is shared by the copies of Server that the value receivers see, so
+// the address bound by Run is visible to callers.
+type runState struct {
+	mu       sync.Mutex
+	listener net.Listener
+	ready    chan struct{}
+}
 
-	go func() {
-		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Println(err)
-			s.Logger.Fatal("Could not listen on", zap.String("addr", s.Addr), zap.Error(err))
-		}
-	}()
+// WithListener serves on an already bound listener instead of the port, e.g.
+// one bound to port 0 in tests.
+func WithListener(l net.Listener) func(*Server) *Server {
+	return func(s *Server) *Server {
+		s.run.listener = l
+		return s
+	}
+}
 
-	s.Logger.Info("Server is ready to handle requests", zap.String("addr", s.Addr))
+// Ready is closed once Run has bound its listener.
+func (s Server) Ready() <-chan struct{} {
+	return s.run.ready
+}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	sig := <-quit
-	s.Logger.Info("Server is shutting down", zap.String("reason", sig.String()))
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	if err := s.Shutdown(ctx); err != nil {
-		s.Logger.Fatal("Could not gracefuly shutdown the server", zap.Error(err))
+// ListenAddr is the address Run is serving on, or nil before it has bound.
+func (s Server) ListenAddr() net.Addr {
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	select {
+	case <-s.run.ready:
+		return s.run.listener.Addr()
+	default:
+		return nil
 	}
-	s.Logger.Info("Server stopped")
 }
 
-func (s Server) filterByRatingIfPresent(w http.ResponseWriter, r *http.Request) bool {
-	rating := r.URL.Query().Get("rating")
-	s.Logger.Info("Rating: " + rating)
-	if rating != "" {
-		films, err := s.FilmRepository.GetAllByRating(r.Context(), rating)
-		s.Logger.Info("Films: " + strconv.Itoa(len(films)))
+func (s Server) listen() (net.Listener, error) {
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	if s.run.listener == nil {
+		l, err := net.Listen("tcp", s.Addr)
 		if err != nil {
-			s.Logger.Error("Error getting films", zap.Error(err))
-			http.Error(w, "Error getting films", http.StatusInternalServerError)
-			return true
+			return nil, err
 		}
+		s.run.listener = l
+	}
+	close(s.run.ready)
+	return s.run.listener, nil
+}
 
-		res, err := json.MarshalIndent(films, "", "\t")
+// serveFunc returns Serve, or ServeTLS with a reloading certificate when TLS
+// is configured.
+func (s Server) serveFunc() (func(net.Listener) error, error) {
+	settings := s.Settings()
+	if !settings.TLSEnabled() {
+		return s.Serve, nil
+	}
+	reloader, err := newCertReloader(settings.TLSCertFile, settings.TLSKeyFile, s.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS certificate: %w", err)
+	}
+	tlsConfig, err := settings.tlsConfig(reloader)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure TLS: %w", err)
+	}
+	s.TLSConfig = tlsConfig
+	if settings.TLSReloadInterval > 0 {
+		s.Lifecycle.Go("tls-cert-reloader", reloader.Watch(settings.TLSReloadInterval))
+	}
+	return func(l net.Listener) error {
+		return s.ServeTLS(l, "", "")
+	}, nil
+}
 
-		if err != nil {
-			s.Logger.Error("Error marshalling films", zap.Error(err))
-			http.Error(w, "Error marshalling films", http.StatusInternalServerError)
-			return true
+// startWorkers starts the background work that lives as long as the server.
+func (s Server) startWorkers() {
+	// streams only end when their clients go away, so end them when
+	// shutting down rather than waiting out the timeout
+	s.RegisterOnShutdown(s.Events.Close)
+	// the same goes for comment sockets, which Shutdown does not track at
+	// all once hijacked, so wait for them to say goodbye
+	s.RegisterOnShutdown(s.CommentHub.Close)
+	hub := s.CommentHub
+	s.Lifecycle.Go("comment-sockets", func(ctx context.Context) error {
+		<-ctx.Done()
+		hub.Close()
+		return hub.Wait(context.Background())
+	})
+
+	s.Lifecycle.Go("idempotency-purge", s.purgeIdempotencyKeys)
+
+	if s.CatalogListener != nil {
+		s.CatalogListener.OnChange(s.publishCatalogChange)
+		if cache := s.filmCache; cache != nil {
+			s.CatalogListener.OnChange(func(c films.CatalogChange) {
+				if c.AffectsFilm() {
+					cache.Invalidate(c.FilmID)
+				}
+			})
+			// changes may have been missed while disconnected
+			s.CatalogListener.OnReconnect(cache.Purge)
+		}
+		s.Lifecycle.Go("catalog-listener", s.CatalogListener.Run)
+	}
+}
+
+// Run serves until ctx is done, then stops accepting connections and drains
+// in-flight requests and background work. It returns the listen or serve
+// error if the server stops on its own, and an error wrapping ErrShutdown if
+// draining fails.
+func (s Server) Run(ctx context.Context) error {
+	s.SetupRoutes()
+	s.PrintRoutes()
+	//s.SetupTracing()
+	s.startWorkers()
+
+	var runErr error
+	var redirect *http.Server
+	var grpcServer *rpc.Server
+	l, err := s.listen()
+	if err != nil {
+		runErr = fmt.Errorf("could not listen on %s: %w", s.Addr, err)
+	} else if serve, err := s.serveFunc(); err != nil {
+		_ = l.Close()
+		runErr = err
+	} else {
+		serveErr := make(chan error, 3)
+		if grpcServer = s.grpcServer(); grpcServer != nil && s.Settings().GRPCMultiplex {
+			s.multiplexGRPC(grpcServer)
+		}
+		go func() {
+			if err := serve(l); err != http.ErrServerClosed {
+				serveErr <- fmt.Errorf("serving on %s: %w", l.Addr(), err)
+			}
+		}()
+
+		if port := s.Settings().HTTPRedirectPort; port != "" {
+			_, tlsPort, _ := net.SplitHostPort(l.Addr().String())
+			redirect = &http.Server{
+				Addr:              ":" + port,
+				Handler:           redirectToHTTPS(tlsPort),
+				ReadHeaderTimeout: s.ReadTimeout,
+			}
+			go func() {
+				if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+					serveErr <- fmt.Errorf("redirecting on %s: %w", redirect.Addr, err)
+				}
+			}()
+			s.Logger.Info("Redirecting HTTP to HTTPS", zap.String("addr", redirect.Addr))
 		}
 
-		w.Header().Set("Content-Type", "application/json")
+		if grpcServer != nil && s.Settings().GRPCPort != "" {
+			if err := s.serveGRPC(grpcServer, serveErr); err != nil {
+				serveErr <- fmt.Errorf("could not serve gRPC: %w", err)
+			}
+		}
 
-		_, _ = w.Write(res)
+		s.Logger.Info("Server is ready to handle requests", zap.String("addr", l.Addr().String()))
 
-		w.WriteHeader(http.StatusOK)
-		return true
+		select {
+		case runErr = <-serveErr:
+		case <-ctx.Done():
+		}
 	}
-	return false
-}
 
-func (s Server) filterByCategoryIfPresent(w http.ResponseWriter, r *http.Request) bool {
-	category := r.URL.Query().Get("category")
-	s.Logger.Info("Category: " + category)
-	if category != "" {
-		films, err := s.FilmRepository.GetAllByCategory(r.Context(), category)
-		s.Logger.Info("Films: " + strconv.Itoa(len(films)))
-		if err != nil {
-			s.Logger.Error("Error getting films", zap.Error(err))
-			http.Error(w, "Error getting films", http.StatusInternalServerError)
-			return true
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Settings().ShutdownTimeout)
+	defer cancel()
+	var shutdownErrs []error
+	if redirect != nil {
+		if err := redirect.Shutdown(shutdownCtx); err != nil {
+			shutdownErrs = append(shutdownErrs, err)
 		}
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			s.Logger.Error("Could not gracefully stop gRPC", zap.Error(err))
+			shutdownErrs = append(shutdownErrs, err)
+		}
+	}
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		s.Logger.Error("Could not gracefuly shutdown the server", zap.Error(err))
+		shutdownErrs = append(shutdownErrs, err)
+	}
+	if err := s.Lifecycle.Shutdown(shutdownCtx); err != nil {
+		s.Logger.Error("Could not drain background work", zap.Error(err))
+		shutdownErrs = append(shutdownErrs, err)
+	}
+	s.Logger.Info("Server stopped")
 
-		res, err := json.MarshalIndent(films, "", "\t")
+	// a serve error is the more useful one to report, shutdown failures have
+	// been logged above
+	if runErr == nil && len(shutdownErrs) > 0 {
+		runErr = fmt.Errorf("%w: %v", ErrShutdown, errors.Join(shutdownErrs...))
+	}
+	return runErr
+}
 
-		if err != nil {
-			s.Logger.Error("Error marshalling films", zap.Error(err))
-			http.Error(w, "Error marshalling films", http.StatusInternalServerError)
-			return true
-		}
+// Start runs the server until SIGINT or SIGTERM and returns an exit code for
+// os.Exit. SIGHUP reloads the config.
+func (s Server) Start() int {
+	s.Logger.Info("Starting server ...")
+	defer func() {
+		_ = s.Logger.Sync()
+	}()
 
-		w.Header().Set("Content-Type", "application/json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		_, _ = w.Write(res)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(quit)
+	go func() {
+		for {
+			select {
+			case sig := <-quit:
+				if sig == syscall.SIGHUP {
+					if err := s.ReloadConfig(); err != nil {
+						s.Logger.Error("Could not reload config, keeping current settings", zap.Error(err))
+					}
+					continue
+				}
+				s.Logger.Info("Server is shutting down", zap.String("reason", sig.String()))
+				cancel()
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-		w.WriteHeader(http.StatusOK)
-		return true
+	err := s.Run(ctx)
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrShutdown):
+		s.Logger.Error("Server did not shut down cleanly", zap.Error(err))
+		return ExitShutdownError
+	default:
+		s.Logger.Error("Server failed", zap.Error(err))
+		return ExitServeError
 	}
-	return false
 }
 
+// filmsHandler lists films, of one rating or category if asked to. The list
+// is streamed rather than loaded, see streamFilms.
 func (s Server) filmsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if s.filterByRatingIfPresent(w, r) {
-			return
-		}
-
-		if s.filterByCategoryIfPresent(w, r) {
-			return
-		}
-
-		films, err := s.FilmRepository.GetAll(r.Context())
-		if err != nil {
-			s.Logger.Error("Error getting films", zap.Error(err))
-			http.Error(w, "Error getting films", http.StatusInternalServerError)
+		mediaType, ok := negotiate(w, r)
+		if !ok {
 			return
 		}
 
-		res, err := json.MarshalIndent(films, "", "\t")
-
-		if err != nil {
-			s.Logger.Error("Error marshalling films", zap.Error(err))
-			http.Error(w, "Error marshalling films", http.StatusInternalServerError)
+		sel := selectionParam(r)
+		if len(sel.Include) > 0 {
+			// film.last_update says nothing of the included relations, and a
+			// streamed body cannot be hashed up front: no validators
+			if cacheControl := s.Settings().CacheControlFilms; cacheControl != "" {
+				w.Header().Set("Cache-Control", cacheControl)
+			}
+		} else if lastModified, err := s.FilmRepository.LastModified(r.Context()); err != nil {
+			// serve the films anyway, just without validators
+			s.Logger.Error("Error getting films last modified", zap.Error(err))
+		} else if notModified(w, r, s.Settings().CacheControlFilms, collectionETag(r, mediaType, lastModified), lastModified) {
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-
-		_, _ = w.Write(res)
-
-		w.WriteHeader(http.StatusOK)
+		q := r.URL.Query()
+		filter := films.Filter{Rating: q.Get("rating"), Category: q.Get("category")}
+		s.streamFilms(w, r, mediaType, filter, sel)
 	}
 }
 
 func (s Server) getFilmHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		filmIDparam := chi.URLParam(r, "filmID")
-
-		filmID, err := strconv.Atoi(filmIDparam)
-		if err != nil {
-			s.Logger.Error("Error converting filmID to int", zap.Error(err))
-			http.Error(w, "Error converting filmID to int", http.StatusInternalServerError)
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+		mediaType, ok := negotiate(w, r)
+		if !ok {
 			return
 		}
 
-		film, err := s.FilmRepository.GetByID(r.Context(), filmID)
+		sel := selectionParam(r)
+		film, err := films.Get(r.Context(), s.FilmRepository, filmID, sel)
 		if err == films.ErrNotFound {
-			http.Error(w, "Film Not Found", http.StatusNotFound)
+			problem.Error(w, r, http.StatusNotFound, "Film Not Found")
+			return
+		} else if err == films.ErrNoRelations {
+			problem.Error(w, r, http.StatusBadRequest, "Relations cannot be included")
 			return
 		} else if err != nil {
 			s.Logger.Error("Error getting film", zap.Error(err))
-			http.Error(w, "Error getting film", http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusInternalServerError, "Error getting film")
 			return
 		}
 
-		res, err := json.MarshalIndent(film, "", "\t")
-
-		if err != nil {
-			s.Logger.Error("Error marshalling film", zap.Error(err))
-			http.Error(w, "Error marshalling film", http.StatusInternalServerError)
+		body, ok := s.encode(w, r, mediaType, film, sel.FieldNames()...)
+		if !ok {
 			return
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-
-		_, _ = w.Write(res)
-
-		w.WriteHeader(http.StatusOK)
+		// the ETag covers the included relations, film.last_update does not
+		lastModified := film.LastUpdate
+		if len(sel.Include) > 0 {
+			lastModified = time.Time{}
+		}
+		if notModified(w, r, s.Settings().CacheControlFilm, strongETag(body), lastModified) {
+			return
+		}
+		write(w, http.StatusOK, mediaType, body)
 	}
 }
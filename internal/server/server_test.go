@@ -1,14 +1,18 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/dhaskew/rx/internal/auth"
 	"github.com/dhaskew/rx/internal/films"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 func TestFilmsHandlerNoFilms(t *testing.T) {
@@ -68,3 +72,76 @@ func TestFilmsHandler(t *testing.T) {
 	actual := rr.Body.String()
 	assert.Equal(t, expected, actual)
 }
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := auth.ParseAPIKeys([]string{"test|secret|films:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := films.NewMemFilmRepository([]films.Film{})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(keys),
+		WithListener(l),
+	)
+	assert.Nil(t, srv.ListenAddr())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(ctx)
+	}()
+
+	<-srv.Ready()
+	assert.Equal(t, l.Addr().String(), srv.ListenAddr().String())
+
+	url := "http://" + srv.ListenAddr().String() + "/v1/films"
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set(auth.APIKeyHeader, "secret")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRunListenError(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	srv := NewServer(
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+	)
+	srv.Addr = l.Addr().String()
+
+	err = srv.Run(context.Background())
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrShutdown)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/render"
+)
+
+const (
+	// streamFlushEvery and streamFlushInterval bound how much of a streamed
+	// collection is buffered before it is flushed to the client.
+	streamFlushEvery    = 100
+	streamFlushInterval = 250 * time.Millisecond
+)
+
+// streamFilms writes the selection of the films matching filter as they are
+// read from the repository, so memory does not grow with the collection and
+// the first films go out before the last are read. Every flush extends the
+// write deadline by the server's WriteTimeout, which then bounds a stalled
+// client rather than the whole collection. A failure before the first film
+// is answered with a 500; after it the response is aborted, so that clients
+// see a truncated collection as an error rather than a short one.
+func (s Server) streamFilms(w http.ResponseWriter, r *http.Request, mediaType string, filter films.Filter, sel films.Selection) {
+	stream, err := render.NewStream(w, mediaType, films.Film{}, sel.FieldNames()...)
+	if err != nil {
+		problem.Error(w, r, http.StatusNotAcceptable, "Films cannot be rendered as "+mediaType)
+		return
+	}
+	w.Header().Set("Content-Type", render.ContentType(mediaType))
+
+	rc := http.NewResponseController(w)
+	extendDeadline := func() {
+		if s.WriteTimeout <= 0 {
+			return
+		}
+		if err := rc.SetWriteDeadline(time.Now().Add(s.WriteTimeout)); err != nil {
+			s.Logger.Debug("Could not extend the write deadline", zap.Error(err))
+		}
+	}
+	extendDeadline()
+	lastFlush := time.Now()
+	err = films.Stream(r.Context(), s.FilmRepository, filter, sel, func(film films.Film) error {
+		if err := stream.Encode(film); err != nil {
+			return err
+		}
+		if stream.Len()%streamFlushEvery == 0 || time.Since(lastFlush) >= streamFlushInterval {
+			lastFlush = time.Now()
+			// not every writer can flush, e.g. when buffered for validation
+			extendDeadline()
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		extendDeadline()
+		err = stream.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if err == films.ErrNoRelations {
+		problem.Error(w, r, http.StatusBadRequest, "Relations cannot be included")
+		return
+	}
+	if stream.Len() == 0 {
+		s.Logger.Error("Error getting films", zap.Error(err))
+		problem.Error(w, r, http.StatusInternalServerError, "Error getting films")
+		return
+	}
+	s.Logger.Error("Error streaming films, aborting the response", zap.Int("written", stream.Len()), zap.Error(err))
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// failingStreamer yields films and then fails.
+type failingStreamer struct {
+	films.FilmRepository
+	yield int
+}
+
+func (f failingStreamer) StreamFilms(ctx context.Context, filter films.Filter, sel films.Selection, fn func(films.Film) error) error {
+	for i := 1; i <= f.yield; i++ {
+		if err := fn(films.Film{FilmID: i, Title: "title"}); err != nil {
+			return err
+		}
+	}
+	return errors.New("connection reset")
+}
+
+func TestStreamFilmsFailure(t *testing.T) {
+	t.Parallel()
+
+	mem := films.NewMemFilmRepository(nil)
+	tests := []struct {
+		name  string
+		yield int
+	}{
+		{"before the first film", 0},
+		{"after the first film", streamFlushEvery + 1},
+	}
+	for _, tt := range tests {
+		var repo films.FilmRepository = failingStreamer{FilmRepository: mem, yield: tt.yield}
+		srv := NewServer(
+			WithFilmRepository(&repo),
+			WithLogger(zap.NewNop()),
+			WithRouterFunc(chi.NewRouter),
+		)
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/v1/films?format=ndjson", nil)
+		handler := srv.filmsHandler()
+
+		if tt.yield == 0 {
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusInternalServerError, rr.Code, tt.name)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), tt.name)
+			continue
+		}
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { handler.ServeHTTP(rr, req) }, tt.name)
+		assert.Equal(t, http.StatusOK, rr.Code, tt.name)
+		assert.True(t, rr.Flushed, tt.name)
+	}
+}
+
+// slowStreamer yields films a delay apart.
+type slowStreamer struct {
+	films.FilmRepository
+	yield int
+	delay time.Duration
+}
+
+func (f slowStreamer) StreamFilms(ctx context.Context, filter films.Filter, sel films.Selection, fn func(films.Film) error) error {
+	for i := 1; i <= f.yield; i++ {
+		time.Sleep(f.delay)
+		if err := fn(films.Film{FilmID: i, Title: "title"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStreamFilmsOutlivesWriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	mem := films.NewMemFilmRepository(nil)
+	var repo films.FilmRepository = slowStreamer{FilmRepository: mem, yield: 4, delay: streamFlushInterval + 50*time.Millisecond}
+	srv := NewServer(
+		WithFilmRepository(&repo),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+	)
+	ts := httptest.NewUnstartedServer(srv.filmsHandler())
+	ts.Config.WriteTimeout = 500 * time.Millisecond
+	srv.WriteTimeout = ts.Config.WriteTimeout
+	ts.Start()
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?format=ndjson")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err, "the response must not be cut off by the WriteTimeout")
+	assert.Equal(t, 4, strings.Count(string(body), "\n"))
+}
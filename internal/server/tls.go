@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// cipherPolicies maps TLS_CIPHER_POLICY to the TLS 1.0-1.2 suites offered.
+// TLS 1.3 suites are not configurable in Go. A nil list means Go's defaults.
+var cipherPolicies = map[string]func() []uint16{
+	"default": func() []uint16 { return nil },
+	// forward secret AEAD suites only
+	"modern": func() []uint16 {
+		return []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		}
+	},
+	// every suite Go does not consider insecure, for older clients
+	"compatible": func() []uint16 {
+		var ids []uint16
+		for _, suite := range tls.CipherSuites() {
+			ids = append(ids, suite.ID)
+		}
+		return ids
+	},
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"require":         tls.RequireAndVerifyClientCert,
+	"verify_if_given": tls.VerifyClientCertIfGiven,
+}
+
+// TLSEnabled reports whether the server should terminate TLS itself.
+func (s Settings) TLSEnabled() bool {
+	return s.TLSCertFile != ""
+}
+
+func (s Settings) validateTLS() error {
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if _, ok := tlsVersions[s.TLSMinVersion]; !ok {
+		return fmt.Errorf("TLS_MIN_VERSION: unknown version %q", s.TLSMinVersion)
+	}
+	if _, ok := cipherPolicies[s.TLSCipherPolicy]; !ok {
+		return fmt.Errorf("TLS_CIPHER_POLICY: unknown policy %q", s.TLSCipherPolicy)
+	}
+	if _, ok := clientAuthTypes[s.TLSClientAuth]; !ok {
+		return fmt.Errorf("TLS_CLIENT_AUTH: unknown mode %q", s.TLSClientAuth)
+	}
+	if !s.TLSEnabled() && (s.TLSClientCAFile != "" || s.HTTPRedirectPort != "") {
+		return errors.New("TLS_CLIENT_CA_FILE and HTTP_REDIRECT_PORT require TLS_CERT_FILE")
+	}
+	return nil
+}
+
+// tlsConfig builds the server TLS config around reloader.
+func (s Settings) tlsConfig(reloader *certReloader) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tlsVersions[s.TLSMinVersion],
+		CipherSuites:   cipherPolicies[s.TLSCipherPolicy](),
+		GetCertificate: reloader.GetCertificate,
+	}
+	if s.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(s.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.TLSClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = clientAuthTypes[s.TLSClientAuth]
+	}
+	return cfg, nil
+}
+
+// certReloader serves the certificate in certFile/keyFile and picks up new
+// files when their modification time changes, so certificates can be rotated
+// without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *zap.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string, logger *zap.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+	if _, err := r.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reloadIfChanged loads the key pair if either file changed since the last
+// load. A pair that fails to load leaves the current certificate in place.
+func (r *certReloader) reloadIfChanged() (bool, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && certInfo.ModTime().Equal(r.certTime) && keyInfo.ModTime().Equal(r.keyTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certTime = certInfo.ModTime()
+	r.keyTime = keyInfo.ModTime()
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Watch polls the files every interval until ctx is done.
+func (r *certReloader) Watch(interval time.Duration) Worker {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				reloaded, err := r.reloadIfChanged()
+				if err != nil {
+					r.logger.Error("Could not reload TLS certificate, keeping current one", zap.Error(err))
+					continue
+				}
+				if reloaded {
+					r.logger.Info("Reloaded TLS certificate", zap.String("cert", r.certFile))
+				}
+			}
+		}
+	}
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same path on the TLS port.
+func redirectToHTTPS(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// writeCert writes a self signed cert/key pair for cn and returns the paths.
+func writeCert(t *testing.T, dir string, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return certFile, keyFile
+}
+
+func leafCN(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+	r, err := newCertReloader(certFile, keyFile, zap.NewNop())
+	assert.NoError(t, err)
+
+	cert, _ := r.GetCertificate(nil)
+	assert.Equal(t, "first", leafCN(t, cert))
+
+	reloaded, err := r.reloadIfChanged()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeCert(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, later, later))
+	reloaded, err = r.reloadIfChanged()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	cert, _ = r.GetCertificate(nil)
+	assert.Equal(t, "second", leafCN(t, cert))
+
+	// a broken pair keeps the current certificate
+	writeFile(t, keyFile, "garbage")
+	even := later.Add(time.Minute)
+	assert.NoError(t, os.Chtimes(keyFile, even, even))
+	_, err = r.reloadIfChanged()
+	assert.Error(t, err)
+	cert, _ = r.GetCertificate(nil)
+	assert.Equal(t, "second", leafCN(t, cert))
+}
+
+func TestTLSSettings(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseSettings(map[string]string{"TLS_CERT_FILE": "cert.pem"})
+	assert.Error(t, err, "cert without key")
+
+	_, err = ParseSettings(map[string]string{"TLS_CERT_FILE": "c", "TLS_KEY_FILE": "k", "TLS_MIN_VERSION": "0.9"})
+	assert.Error(t, err)
+
+	_, err = ParseSettings(map[string]string{"TLS_CERT_FILE": "c", "TLS_KEY_FILE": "k", "TLS_CIPHER_POLICY": "weak"})
+	assert.Error(t, err)
+
+	_, err = ParseSettings(map[string]string{"HTTP_REDIRECT_PORT": "8081"})
+	assert.Error(t, err, "redirect without TLS")
+
+	settings, err := ParseSettings(map[string]string{"TLS_CERT_FILE": "c", "TLS_KEY_FILE": "k", "TLS_MIN_VERSION": "1.3"})
+	assert.NoError(t, err)
+	cfg, err := settings.tlsConfig(&certReloader{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.NotEmpty(t, cfg.CipherSuites)
+	assert.Equal(t, tls.NoClientCert, cfg.ClientAuth)
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		port     string
+		host     string
+		expected string
+	}{
+		{port: "8443", host: "example.com:8080", expected: "https://example.com:8443/v1/films?rating=PG"},
+		{port: "443", host: "example.com", expected: "https://example.com/v1/films?rating=PG"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/films?rating=PG", nil)
+		req.Host = tt.host
+		rr := httptest.NewRecorder()
+		redirectToHTTPS(tt.port).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusPermanentRedirect, rr.Code)
+		assert.Equal(t, tt.expected, rr.Header().Get("Location"))
+	}
+}
+
+// testCert is a certificate issued for the handshake tests, written to
+// certFile and keyFile.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	pair     tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// issue writes a certificate for name signed by parent, or self signed when
+// parent is nil. CAs can sign others; other certificates are for 127.0.0.1.
+func issue(t *testing.T, name string, parent *testCert, ca bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if ca {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	tc := &testCert{cert: cert, key: key, certFile: filepath.Join(dir, "cert.pem"), keyFile: filepath.Join(dir, "key.pem")}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	writeFile(t, tc.certFile, string(certPEM))
+	writeFile(t, tc.keyFile, string(keyPEM))
+	if tc.pair, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+// runTLS runs a server with the TLS settings of env on a listener of its own
+// and returns its address.
+func runTLS(t *testing.T, env map[string]string) string {
+	t.Helper()
+	settings, err := ParseSettings(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithListener(l),
+	)
+	srv.settings.current.Store(settings)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	select {
+	case <-srv.Ready():
+	case err := <-done:
+		t.Fatal(err)
+	}
+	return srv.ListenAddr().String()
+}
+
+// get pings addr over TLS with cfg.
+func get(addr string, cfg *tls.Config) error {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+	res, err := client.Get("https://" + addr + "/ping")
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func TestTLSHandshake(t *testing.T) {
+	t.Parallel()
+
+	ca := issue(t, "ca", nil, true)
+	server := issue(t, "server", ca, false)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	addr := runTLS(t, map[string]string{
+		"TLS_CERT_FILE":     server.certFile,
+		"TLS_KEY_FILE":      server.keyFile,
+		"TLS_MIN_VERSION":   "1.2",
+		"TLS_CIPHER_POLICY": "modern",
+	})
+
+	tests := []struct {
+		name    string
+		version uint16
+		suites  []uint16
+		ok      bool
+	}{
+		{"tls 1.3", tls.VersionTLS13, nil, true},
+		{"tls 1.2 with a modern suite", tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, true},
+		{"tls 1.2 with a CBC suite", tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}, false},
+		{"tls 1.1", tls.VersionTLS11, nil, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, MaxVersion: tt.version, CipherSuites: tt.suites})
+			if !tt.ok {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			state := conn.ConnectionState()
+			assert.Equal(t, tt.version, state.Version)
+			if tt.suites != nil {
+				assert.Equal(t, tt.suites[0], state.CipherSuite)
+			}
+			assert.Equal(t, "server", state.PeerCertificates[0].Subject.CommonName)
+		})
+	}
+
+	assert.NoError(t, get(addr, &tls.Config{RootCAs: roots}))
+	assert.Error(t, get(addr, &tls.Config{}), "the server's CA is not trusted by default")
+}
+
+func TestTLSClientCertificates(t *testing.T) {
+	t.Parallel()
+
+	ca := issue(t, "ca", nil, true)
+	server := issue(t, "server", ca, false)
+	client := issue(t, "client", ca, false)
+	stranger := issue(t, "stranger", issue(t, "other ca", nil, true), false)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	addr := runTLS(t, map[string]string{
+		"TLS_CERT_FILE":      server.certFile,
+		"TLS_KEY_FILE":       server.keyFile,
+		"TLS_CLIENT_CA_FILE": ca.certFile,
+		"TLS_CLIENT_AUTH":    "require",
+	})
+
+	tests := []struct {
+		name string
+		cert *testCert
+		ok   bool
+	}{
+		{"trusted client", client, true},
+		{"no client certificate", nil, false},
+		{"client of another CA", stranger, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &tls.Config{RootCAs: roots}
+			// sends the certificate whatever CAs the server asks for, unlike Certificates
+			cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if tt.cert == nil {
+					return &tls.Certificate{}, nil
+				}
+				return &tt.cert.pair, nil
+			}
+			// TLS 1.3 clients learn of a rejected certificate on their first read
+			err := get(addr, cfg)
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
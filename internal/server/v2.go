@@ -0,0 +1,222 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/apiv2"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/problem"
+	"github.com/dhaskew/rx/internal/render"
+)
+
+// Film collections of the v2 routes are paged, by default and at most this
+// many films at a time.
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// apiV2Problems renders the problems of the routes it wraps, including those
+// of the shared middleware, as v2 error documents.
+func apiV2Problems(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(problem.WithRenderer(r.Context(), apiv2.WriteProblem)))
+	})
+}
+
+// pageParams reads the limit and offset query parameters, validated against
+// the route's description.
+func pageParams(r *http.Request) (limit, offset int) {
+	q := r.URL.Query()
+	limit = defaultPageLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 && n <= maxPageLimit {
+		limit = n
+	}
+	if n, err := strconv.Atoi(q.Get("offset")); err == nil && n > 0 {
+		offset = n
+	}
+	return limit, offset
+}
+
+// pageLinks links a page of a collection of total items to itself and the
+// pages around it, keeping the other query parameters.
+func pageLinks(r *http.Request, total, limit, offset int) apiv2.Links {
+	at := func(offset int) string {
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		return r.URL.Path + "?" + q.Encode()
+	}
+	links := apiv2.Links{"self": r.URL.RequestURI()}
+	if offset+limit < total {
+		links["next"] = at(offset + limit)
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links["prev"] = at(prev)
+	}
+	return links
+}
+
+// encodeV2 renders doc, answering 500 if encoding fails.
+func (s Server) encodeV2(w http.ResponseWriter, r *http.Request, doc apiv2.Document) ([]byte, bool) {
+	body, err := apiv2.Encode(doc)
+	if err != nil {
+		s.Logger.Error("Error rendering response", zap.Error(err))
+		problem.Error(w, r, http.StatusInternalServerError, "Error rendering response")
+		return nil, false
+	}
+	return body, true
+}
+
+// filmsV2Handler lists a page of films, of one rating or category if asked
+// to.
+func (s Server) filmsV2Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lastModified, err := s.FilmRepository.LastModified(r.Context())
+		if err != nil {
+			// serve the films anyway, just without validators
+			s.Logger.Error("Error getting films last modified", zap.Error(err))
+		} else if notModified(w, r, s.Settings().CacheControlFilms, collectionETag(r, render.JSON, lastModified), lastModified) {
+			return
+		}
+
+		q := r.URL.Query()
+		list, err := films.Collection(r.Context(), s.FilmRepository, films.Filter{Rating: q.Get("rating"), Category: q.Get("category")})
+		if err != nil {
+			s.Logger.Error("Error getting films", zap.Error(err))
+			problem.Error(w, r, http.StatusInternalServerError, "Error getting films")
+			return
+		}
+
+		limit, offset := pageParams(r)
+		page := []apiv2.Film{}
+		for i := offset; i < len(list) && i < offset+limit; i++ {
+			page = append(page, apiv2.FilmOf(list[i]))
+		}
+		body, ok := s.encodeV2(w, r, apiv2.Document{
+			Data:  page,
+			Meta:  &apiv2.Meta{Total: len(list), Limit: limit, Offset: offset},
+			Links: pageLinks(r, len(list), limit, offset),
+		})
+		if !ok {
+			return
+		}
+		apiv2.Write(w, http.StatusOK, body)
+	}
+}
+
+func (s Server) getFilmV2Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		film, err := s.FilmRepository.GetByID(r.Context(), filmID)
+		if err == films.ErrNotFound {
+			problem.Error(w, r, http.StatusNotFound, "Film Not Found")
+			return
+		} else if err != nil {
+			s.Logger.Error("Error getting film", zap.Error(err))
+			problem.Error(w, r, http.StatusInternalServerError, "Error getting film")
+			return
+		}
+
+		body, ok := s.encodeV2(w, r, apiv2.Document{
+			Data:  apiv2.FilmOf(film),
+			Links: apiv2.Links{"self": r.URL.Path, "comments": r.URL.Path + "/comments"},
+		})
+		if !ok {
+			return
+		}
+		if notModified(w, r, s.Settings().CacheControlFilm, strongETag(body), film.LastUpdate) {
+			return
+		}
+		apiv2.Write(w, http.StatusOK, body)
+	}
+}
+
+func (s Server) filmCommentsV2Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		list, err := s.CommentRepository.GetByFilm(r.Context(), filmID)
+		if err != nil {
+			s.Logger.Error("Error getting comments", zap.Error(err))
+			problem.Error(w, r, http.StatusInternalServerError, "Error getting comments")
+			return
+		}
+
+		data := make([]apiv2.Comment, len(list))
+		for i, c := range list {
+			data[i] = apiv2.CommentOf(c)
+		}
+		body, ok := s.encodeV2(w, r, apiv2.Document{
+			Data:  data,
+			Links: apiv2.Links{"self": r.URL.Path, "film": strings.TrimSuffix(r.URL.Path, "/comments")},
+		})
+		if !ok {
+			return
+		}
+		apiv2.Write(w, http.StatusOK, body)
+	}
+}
+
+// getFilmCommentV2Handler is getFilmCommentHandler answering with a v2
+// document.
+func (s Server) getFilmCommentV2Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		comment, ok := s.getComment(w, r)
+		if !ok {
+			return
+		}
+
+		body, ok := s.encodeV2(w, r, apiv2.Document{
+			Data:  apiv2.CommentOf(comment),
+			Links: apiv2.Links{"self": r.URL.Path, "film": path.Dir(path.Dir(r.URL.Path))},
+		})
+		if !ok {
+			return
+		}
+		apiv2.Write(w, http.StatusOK, body)
+	}
+}
+
+// createFilmCommentV2Handler is createFilmCommentHandler answering with a v2
+// document.
+func (s Server) createFilmCommentV2Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filmID, ok := filmIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		comment, ok := s.createComment(w, r, filmID)
+		if !ok {
+			return
+		}
+
+		location := r.URL.Path + "/" + strconv.Itoa(comment.CommentID)
+		body, ok := s.encodeV2(w, r, apiv2.Document{
+			Data:  apiv2.CommentOf(comment),
+			Links: apiv2.Links{"self": location, "film": strings.TrimSuffix(r.URL.Path, "/comments")},
+		})
+		if !ok {
+			return
+		}
+		w.Header().Set("Location", location)
+		apiv2.Write(w, http.StatusCreated, body)
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+)
+
+func v2Server(t *testing.T, authenticators ...auth.Authenticator) *Server {
+	t.Helper()
+	mem := films.NewMemFilmRepository([]films.Film{
+		{FilmID: 1, Title: "ACADEMY DINOSAUR", Rating: "PG", Length: 86, RentalDuration: 6, RentalRate: 99, ReplacementCost: 2099},
+		{FilmID: 2, Title: "ACE GOLDFINGER", Rating: "G", RentalDuration: 3, RentalRate: 499, ReplacementCost: 1299},
+		{FilmID: 3, Title: "ADAPTATION HOLES", Rating: "NC-17", Length: 50, RentalDuration: 7, RentalRate: 299, ReplacementCost: 1899},
+	})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(authenticators...),
+	)
+	srv.SetupRoutes()
+	return srv
+}
+
+func getV2(t *testing.T, srv *Server, url string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("%s: %v in %s", url, err, rr.Body.String())
+	}
+	return rr, doc
+}
+
+func TestFilmsV2(t *testing.T) {
+	t.Parallel()
+	srv := v2Server(t, auth.Anonymous(auth.AllScopes...))
+
+	rr, doc := getV2(t, srv, "/v2/films/1")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, map[string]interface{}{
+		"id":              float64(1),
+		"title":           "ACADEMY DINOSAUR",
+		"description":     "",
+		"releaseYear":     float64(0),
+		"rating":          "PG",
+		"length":          "PT1H26M",
+		"rentalDuration":  "P6D",
+		"rentalRate":      map[string]interface{}{"amount": "0.99", "currency": "USD"},
+		"replacementCost": map[string]interface{}{"amount": "20.99", "currency": "USD"},
+	}, doc["data"])
+	assert.Equal(t, map[string]interface{}{"self": "/v2/films/1", "comments": "/v2/films/1/comments"}, doc["links"])
+	assert.NotContains(t, doc, "errors")
+
+	// a film of unknown length has none
+	_, doc = getV2(t, srv, "/v2/films/2")
+	assert.NotContains(t, doc["data"], "length")
+}
+
+func TestFilmCommentV2(t *testing.T) {
+	t.Parallel()
+	srv := v2Server(t, auth.Anonymous(auth.AllScopes...))
+
+	req := httptest.NewRequest("POST", "/v2/films/1/comments", strings.NewReader(`{"body": "great"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	location := rr.Header().Get("Location")
+	assert.Equal(t, "/v2/films/1/comments/1", location)
+
+	rr, doc := getV2(t, srv, location)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "great", doc["data"].(map[string]interface{})["body"])
+	assert.Equal(t, map[string]interface{}{"self": location, "film": "/v2/films/1"}, doc["links"])
+
+	rr, _ = getV2(t, srv, "/v2/films/2/comments/1")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestFilmsV2Pages(t *testing.T) {
+	t.Parallel()
+	srv := v2Server(t, auth.Anonymous(auth.AllScopes...))
+
+	tests := []struct {
+		url   string
+		ids   []interface{}
+		meta  map[string]interface{}
+		links map[string]interface{}
+	}{
+		{
+			url:   "/v2/films",
+			ids:   []interface{}{float64(1), float64(2), float64(3)},
+			meta:  map[string]interface{}{"total": float64(3), "limit": float64(defaultPageLimit), "offset": float64(0)},
+			links: map[string]interface{}{"self": "/v2/films"},
+		},
+		{
+			url:  "/v2/films?limit=1&offset=1",
+			ids:  []interface{}{float64(2)},
+			meta: map[string]interface{}{"total": float64(3), "limit": float64(1), "offset": float64(1)},
+			links: map[string]interface{}{
+				"self": "/v2/films?limit=1&offset=1",
+				"next": "/v2/films?limit=1&offset=2",
+				"prev": "/v2/films?limit=1&offset=0",
+			},
+		},
+		{
+			url:   "/v2/films?rating=G&limit=2",
+			ids:   []interface{}{float64(2)},
+			meta:  map[string]interface{}{"total": float64(1), "limit": float64(2), "offset": float64(0)},
+			links: map[string]interface{}{"self": "/v2/films?rating=G&limit=2"},
+		},
+		{
+			url:   "/v2/films?offset=5",
+			ids:   []interface{}{},
+			meta:  map[string]interface{}{"total": float64(3), "limit": float64(defaultPageLimit), "offset": float64(5)},
+			links: map[string]interface{}{"self": "/v2/films?offset=5", "prev": "/v2/films?limit=100&offset=0"},
+		},
+	}
+	for _, tt := range tests {
+		rr, doc := getV2(t, srv, tt.url)
+		assert.Equal(t, http.StatusOK, rr.Code, tt.url)
+		ids := []interface{}{}
+		for _, film := range doc["data"].([]interface{}) {
+			ids = append(ids, film.(map[string]interface{})["id"])
+		}
+		assert.Equal(t, tt.ids, ids, tt.url)
+		assert.Equal(t, tt.meta, doc["meta"], tt.url)
+		assert.Equal(t, tt.links, doc["links"], tt.url)
+	}
+}
+
+func TestV2Errors(t *testing.T) {
+	t.Parallel()
+	keys, err := auth.ParseAPIKeys([]string{"kiosk|secret|films:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := v2Server(t, keys)
+
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		instance string
+		detail   string
+	}{
+		{"unauthenticated", "/v2/films", http.StatusUnauthorized, "/v2/films", ""},
+		{"invalid parameter", "/v2/films?limit=0", http.StatusBadRequest, "/v2/films", ""},
+		{"not found", "/v2/films/9", http.StatusNotFound, "/v2/films/9", "Film Not Found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.status != http.StatusUnauthorized {
+			req.Header.Set(auth.APIKeyHeader, "secret")
+		}
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, tt.status, rr.Code, tt.name)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"), tt.name)
+
+		var doc struct {
+			Data   interface{}
+			Errors []struct {
+				Status        int
+				Detail        string
+				Instance      string
+				InvalidParams []map[string]string `json:"invalidParams"`
+			}
+		}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc), tt.name)
+		assert.Nil(t, doc.Data, tt.name)
+		if assert.Len(t, doc.Errors, 1, tt.name) {
+			assert.Equal(t, tt.status, doc.Errors[0].Status, tt.name)
+			assert.Equal(t, tt.instance, doc.Errors[0].Instance, tt.name)
+			if tt.detail != "" {
+				assert.Equal(t, tt.detail, doc.Errors[0].Detail, tt.name)
+			}
+			if tt.status == http.StatusBadRequest {
+				assert.Equal(t, []map[string]string{{"in": "query", "name": "limit", "reason": "must be at least 1"}}, doc.Errors[0].InvalidParams)
+			}
+		}
+	}
+}
+
+func TestV1Deprecation(t *testing.T) {
+	t.Parallel()
+	srv := v2Server(t, auth.Anonymous(auth.AllScopes...))
+
+	// nothing is announced until configured
+	rr := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/films", nil))
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+	assert.Empty(t, rr.Header().Get("Sunset"))
+
+	settings := srv.Settings()
+	settings.APIV1DeprecatedAt = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	settings.APIV1SunsetAt = time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	settings.APIV1DeprecationLink = "https://example.com/v2"
+	srv.settings.current.Store(settings)
+
+	tests := []struct {
+		method, url string
+		status      int
+		successor   string
+	}{
+		{"GET", "/v1/films", http.StatusOK, "/v2/films"},
+		{"GET", "/v1/films/1/comments", http.StatusOK, "/v2/films/1/comments"},
+		{"GET", "/v1/films/9", http.StatusNotFound, "/v2/films/9"},
+		{"GET", "/v1/films/1/comments/ws", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.url, nil))
+		assert.Equal(t, tt.status, rr.Code, tt.url)
+		assert.Equal(t, "@1767225600", rr.Header().Get("Deprecation"), tt.url)
+		assert.Equal(t, "Thu, 31 Dec 2026 00:00:00 GMT", rr.Header().Get("Sunset"), tt.url)
+		links := []string{`<https://example.com/v2>; rel="deprecation"; type="text/html"`}
+		if tt.successor != "" {
+			links = append(links, "<"+tt.successor+`>; rel="successor-version"`)
+		}
+		assert.Equal(t, links, rr.Header().Values("Link"), tt.url)
+	}
+
+	// v2 is not deprecated
+	rr = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/v2/films", nil))
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+}
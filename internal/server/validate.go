@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/openapi"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// maxValidatedBody is the largest request body validate reads.
+const maxValidatedBody = 1 << 20
+
+// apiValidator checks requests and responses against the operations in
+// openapi.go.
+var apiValidator = openapi.NewValidator(apiComponents)
+
+// validate rejects requests whose parameters or body do not match op with a
+// 400 listing every invalid one, so handlers can rely on them parsing. In the
+// test environment responses are checked against op too, and replaced by a
+// 500 when they drift from it.
+func (s Server) validate(op *openapi.Operation) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			invalid := apiValidator.Params(op, r, func(name string) string { return chi.URLParam(r, name) })
+
+			if op.RequestBody != nil && r.Body != nil {
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValidatedBody))
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					problem.Error(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+					return
+				} else if err != nil {
+					problem.Error(w, r, http.StatusBadRequest, "Could not read the request body")
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				invalid = append(invalid, apiValidator.Body(op, r.Header.Get("Content-Type"), body)...)
+			}
+
+			if len(invalid) > 0 {
+				p := problem.New(http.StatusBadRequest, "The request has invalid parameters")
+				for _, i := range invalid {
+					p.InvalidParams = append(p.InvalidParams, problem.InvalidParam{In: i.In, Name: i.Name, Reason: i.Reason})
+				}
+				problem.Write(w, r, p)
+				return
+			}
+
+			if s.Settings().Environment != EnvTest || !checksResponses(op) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if invalid := apiValidator.Response(op, rec.status, rec.header.Get("Content-Type"), rec.body.Bytes()); len(invalid) > 0 {
+				s.Logger.Error("Response does not match the API description",
+					zap.String("operation", op.OperationID), zap.Int("status", rec.status), zap.Errors("invalid", invalidErrors(invalid)))
+				problem.Error(w, r, http.StatusInternalServerError, "Response does not match the API description: "+invalid[0].Error())
+				return
+			}
+			rec.writeTo(w)
+		})
+	}
+}
+
+// checksResponses reports whether the responses of op can be buffered for
+// checking, which streams and WebSockets cannot.
+func checksResponses(op *openapi.Operation) bool {
+	for status, res := range op.Responses {
+		if status == "101" {
+			return false
+		}
+		if _, ok := res.Content["text/event-stream"]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func invalidErrors(invalid []openapi.Invalid) []error {
+	errs := make([]error, len(invalid))
+	for i := range invalid {
+		errs[i] = invalid[i]
+	}
+	return errs
+}
+
+// responseRecorder buffers a response to check it before it is sent.
+type responseRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+func (rec *responseRecorder) writeTo(w http.ResponseWriter) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.status)
+	_, _ = w.Write(rec.body.Bytes())
+}
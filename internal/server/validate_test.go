@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/dhaskew/rx/internal/auth"
+	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/openapi"
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+func TestValidateRequests(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{{FilmID: 1, Title: "title", Rating: "PG"}})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	srv.SetupRoutes()
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		want   []problem.InvalidParam
+	}{
+		{name: "valid", method: "GET", url: "/v1/films?rating=PG"},
+		{name: "rating", method: "GET", url: "/v1/films?rating=XXX", want: []problem.InvalidParam{
+			{In: "query", Name: "rating", Reason: "must be one of G, PG, PG-13, R, NC-17"},
+		}},
+		{name: "film ID", method: "GET", url: "/v1/films/one", want: []problem.InvalidParam{
+			{In: "path", Name: "filmID", Reason: "must be an integer"},
+		}},
+		{name: "every parameter", method: "GET", url: "/v1/events?store=0&last_event_id=x", want: []problem.InvalidParam{
+			{In: "query", Name: "store", Reason: "must be at least 1"},
+			{In: "query", Name: "last_event_id", Reason: "must be an integer"},
+		}},
+		{name: "path and body", method: "POST", url: "/v1/films/0/comments", body: `{"body": 1}`, want: []problem.InvalidParam{
+			{In: "path", Name: "filmID", Reason: "must be at least 1"},
+			{In: "body", Name: "/body", Reason: "must be a string"},
+		}},
+		{name: "missing body field", method: "POST", url: "/v1/films/1/comments", body: `{}`, want: []problem.InvalidParam{
+			{In: "body", Name: "/body", Reason: "is required"},
+		}},
+		{name: "ids", method: "GET", url: "/v1/films:batchGet?ids=1,0,x", want: []problem.InvalidParam{
+			{In: "query", Name: "ids", Reason: "must be at least 1"},
+			{In: "query", Name: "ids", Reason: "must be an integer"},
+		}},
+		{name: "batch get", method: "POST", url: "/v1/films:batchGet", body: `{"ids": [1, "2"]}`, want: []problem.InvalidParam{
+			{In: "body", Name: "/ids/1", Reason: "must be an integer"},
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if tt.body != "" {
+				req = httptest.NewRequest(tt.method, tt.url, stringsReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rr, req)
+
+			if tt.want == nil {
+				assert.Equal(t, http.StatusOK, rr.Code)
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+			var p problem.Problem
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+			assert.Equal(t, tt.want, p.InvalidParams)
+		})
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	t.Parallel()
+	op := &openapi.Operation{
+		Responses: map[string]openapi.Response{
+			"200": {Content: openapi.JSON(openapi.ArrayOf(openapi.Ref("Film")))},
+		},
+	}
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}
+	}
+
+	for _, env := range []string{EnvTest, EnvProduction} {
+		srv := NewServer(WithLogger(zap.NewNop()), WithRouterFunc(chi.NewRouter))
+		settings := srv.Settings()
+		settings.Environment = env
+		srv.settings.current.Store(settings)
+		srv.Router.With(srv.validate(op)).Get("/valid", handler(`[{"film_id": 1}]`))
+		srv.Router.With(srv.validate(op)).Get("/drifted", handler(`[{"id": 1}]`))
+
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/valid", nil))
+		assert.Equal(t, http.StatusOK, rr.Code, env)
+		assert.Equal(t, `[{"film_id": 1}]`, rr.Body.String(), env)
+
+		rr = httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/drifted", nil))
+		if env == EnvTest {
+			assert.Equal(t, http.StatusInternalServerError, rr.Code)
+			assert.Contains(t, rr.Body.String(), "/0/film_id")
+		} else {
+			assert.Equal(t, http.StatusOK, rr.Code, "responses are only checked in tests")
+		}
+	}
+}
+
+// TestRoutesMatchDescription runs requests through the routes in the test
+// environment, where responses that drift from the description fail.
+func TestRoutesMatchDescription(t *testing.T) {
+	t.Parallel()
+	mem := films.NewMemFilmRepository([]films.Film{{FilmID: 1, Title: "title", Rating: "PG"}})
+	srv := NewServer(
+		WithFilmRepository(&mem),
+		WithImporter(fakeImporter{}),
+		WithLogger(zap.NewNop()),
+		WithRouterFunc(chi.NewRouter),
+		WithAuthenticators(auth.Anonymous(auth.AllScopes...)),
+	)
+	settings := srv.Settings()
+	settings.Environment = EnvTest
+	srv.settings.current.Store(settings)
+	srv.SetupRoutes()
+
+	tests := []struct {
+		method, url, body string
+		want              int
+	}{
+		{"GET", "/v1/films", "", http.StatusOK},
+		{"GET", "/v1/films?rating=G", "", http.StatusOK},
+		{"GET", "/v1/films:batchGet?ids=2,1", "", http.StatusOK},
+		{"POST", "/v1/films:batchGet", `{"ids": [1, 2]}`, http.StatusOK},
+		{"POST", "/v1/admin/films:import", `[{"title": "NEW RELEASE", "language": "English"}]`, http.StatusOK},
+		{"POST", "/v1/admin/films:import?dry_run=1", `[{"language": "English"}]`, http.StatusUnprocessableEntity},
+		{"GET", "/v1/films/1", "", http.StatusOK},
+		{"GET", "/v1/films/2", "", http.StatusNotFound},
+		{"GET", "/v1/films/1/comments", "", http.StatusOK},
+		{"POST", "/v1/films/1/comments", `{"body": "great"}`, http.StatusCreated},
+		{"POST", "/v1/films/1/comments", `{"body": " "}`, http.StatusBadRequest},
+		{"GET", "/v1/films/1/comments/1", "", http.StatusOK},
+		{"GET", "/v1/films/1/comments/9", "", http.StatusNotFound},
+		{"GET", "/v2/films?limit=1", "", http.StatusOK},
+		{"GET", "/v2/films/1", "", http.StatusOK},
+		{"GET", "/v2/films/2", "", http.StatusNotFound},
+		{"GET", "/v2/films/1/comments", "", http.StatusOK},
+		{"POST", "/v2/films/1/comments", `{"body": "great"}`, http.StatusCreated},
+		{"POST", "/v2/films/1/comments", `{"body": 1}`, http.StatusBadRequest},
+		{"GET", "/v2/films/1/comments/2", "", http.StatusOK},
+		{"GET", "/v2/films/1/comments/9", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.url, stringsReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		assert.Equal(t, tt.want, rr.Code, "%s %s: %s", tt.method, tt.url, rr.Body.String())
+	}
+}
+
+func stringsReader(s string) *strings.Reader {
+	return strings.NewReader(s)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/dhaskew/rx/internal/problem"
+)
+
+// APIVersionHeader selects the API version of a request, e.g. "2", for
+// clients that cannot change their base URL.
+const APIVersionHeader = "API-Version"
+
+// apiVersions are the API versions served, each under its /<version>
+// prefix. Unprefixed paths asking for no version are served by the first.
+var apiVersions = []string{"v1", "v2"}
+
+// versionedJSON matches the JSON media types of the API versions, e.g.
+// application/vnd.mockbuster.v2+json.
+var versionedJSON = regexp.MustCompile(`^application/vnd\.mockbuster\.([^+]+)\+json$`)
+
+// versionRequested is the version a request asks for with its headers,
+// API-Version taking precedence over Accept, and whether it is one served.
+// It is "" when the headers ask for none.
+func versionRequested(h http.Header) (version, raw string, ok bool) {
+	raw = strings.TrimSpace(h.Get(APIVersionHeader))
+	if raw == "" {
+		for _, part := range strings.Split(strings.Join(h.Values("Accept"), ","), ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || params["q"] == "0" {
+				continue
+			}
+			if m := versionedJSON.FindStringSubmatch(mediaType); m != nil {
+				raw = m[1]
+				break
+			}
+		}
+	}
+	if raw == "" {
+		return "", "", true
+	}
+	version = strings.ToLower(raw)
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version, raw, containsFold(apiVersions, version)
+}
+
+// versionPrefix splits the version prefix off path, returning "" and path
+// when it has none.
+func versionPrefix(path string) (version, rest string) {
+	for _, v := range apiVersions {
+		if path == "/"+v || strings.HasPrefix(path, "/"+v+"/") {
+			return v, strings.TrimPrefix(path, "/"+v)
+		}
+	}
+	return "", path
+}
+
+// jsonAccept is h with the versioned JSON media types in Accept replaced by
+// application/json, which the routes of every version render.
+func jsonAccept(h http.Header) http.Header {
+	if len(h.Values("Accept")) == 0 {
+		return h
+	}
+	h = h.Clone()
+	parts := strings.Split(strings.Join(h.Values("Accept"), ","), ",")
+	for i, part := range parts {
+		mediaType, params, _ := strings.Cut(part, ";")
+		if versionedJSON.MatchString(strings.ToLower(strings.TrimSpace(mediaType))) {
+			parts[i] = "application/json"
+			if params != "" {
+				parts[i] += ";" + params
+			}
+		}
+	}
+	h.Set("Accept", strings.Join(parts, ","))
+	return h
+}
+
+// negotiateVersion routes a request to the API version it asks for, the
+// first of:
+//
+//  1. the API-Version header, e.g. "API-Version: 2" (or "v2"),
+//  2. a versioned media type in Accept, e.g. application/vnd.mockbuster.v2+json,
+//  3. the version prefix of the URL, e.g. /v2/films,
+//  4. the first of apiVersions, for unprefixed paths such as /films.
+//
+// Requests asking for an unknown version are answered with 400. The version
+// is set in the context as ApiVersion, like the route groups do, and echoed
+// in the API-Version response header. Unprefixed paths no version serves,
+// e.g. /ping, are left alone.
+func (s Server) negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested, raw, ok := versionRequested(r.Header)
+		if !ok {
+			problem.Error(w, r, http.StatusBadRequest, fmt.Sprintf("Unknown API version %q, want one of %s", raw, strings.Join(apiVersions, ", ")))
+			return
+		}
+
+		prefix, rest := versionPrefix(r.URL.Path)
+		version := requested
+		if version == "" {
+			version = prefix
+		}
+		if version == "" {
+			version = apiVersions[0]
+		}
+		path := "/" + version + rest
+		if prefix == "" {
+			method := r.Method
+			if isPreflight(r) {
+				method = r.Header.Get("Access-Control-Request-Method")
+			}
+			if !s.Router.Match(chi.NewRouteContext(), method, path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		h := w.Header()
+		h.Set(APIVersionHeader, strings.TrimPrefix(version, "v"))
+		addVary(h, "Accept")
+		addVary(h, APIVersionHeader)
+
+		r = r.WithContext(context.WithValue(r.Context(), ApiVersion{}, version))
+		u := *r.URL
+		u.Path, u.RawPath = path, ""
+		r.URL = &u
+		r.Header = jsonAccept(r.Header)
+		next.ServeHTTP(w, r)
+	})
+}
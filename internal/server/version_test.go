@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dhaskew/rx/internal/auth"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	t.Parallel()
+	srv := v2Server(t, auth.Anonymous(auth.AllScopes...))
+
+	tests := []struct {
+		name       string
+		url        string
+		apiVersion string
+		accept     string
+		status     int
+		// version is the API-Version response header, "" for routes of no
+		// version
+		version string
+	}{
+		{"prefix", "/v1/films", "", "", http.StatusOK, "1"},
+		{"v2 prefix", "/v2/films", "", "", http.StatusOK, "2"},
+		{"no prefix", "/films", "", "", http.StatusOK, "1"},
+		{"header", "/films", "2", "", http.StatusOK, "2"},
+		{"header with v", "/films/1", "v2", "", http.StatusOK, "2"},
+		{"header over prefix", "/v1/films", "2", "", http.StatusOK, "2"},
+		{"accept", "/films", "", "application/vnd.mockbuster.v2+json", http.StatusOK, "2"},
+		{"accept over prefix", "/v2/films", "", "application/vnd.mockbuster.v1+json", http.StatusOK, "1"},
+		{"header over accept", "/films", "1", "application/vnd.mockbuster.v2+json", http.StatusOK, "1"},
+		{"accept among others", "/films", "", "text/csv;q=0.5, application/vnd.mockbuster.v2+json", http.StatusOK, "2"},
+		{"unknown header", "/films", "3", "", http.StatusBadRequest, ""},
+		{"unknown accept", "/v1/films", "", "application/vnd.mockbuster.v9+json", http.StatusBadRequest, ""},
+		{"route of another version", "/v1/films/1/comments/ws", "2", "", http.StatusNotFound, "2"},
+		{"unversioned route", "/openapi.json", "2", "", http.StatusOK, ""},
+		{"unknown route", "/actors", "", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.apiVersion != "" {
+				req.Header.Set(APIVersionHeader, tt.apiVersion)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.version, rr.Header().Get(APIVersionHeader))
+			if tt.status != http.StatusOK || tt.version == "" {
+				return
+			}
+
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Header().Values("Vary"), APIVersionHeader)
+			if strings.HasSuffix(tt.url, "/films") {
+				// v1 lists films, v2 envelopes them
+				v2 := strings.HasPrefix(rr.Body.String(), "{")
+				assert.Equal(t, tt.version == "2", v2, rr.Body.String())
+			}
+		})
+	}
+
+	// the versions of a resource are cached separately
+	etag := func(apiVersion string) string {
+		req := httptest.NewRequest("GET", "/films", nil)
+		req.Header.Set(APIVersionHeader, apiVersion)
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		return rr.Header().Get("ETag")
+	}
+	assert.NotEqual(t, etag("1"), etag("2"))
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
-	"fmt"
-	"strconv"
+	"os"
 
 	"github.com/go-chi/chi/v5"
 	_ "github.com/lib/pq"
+	"go.uber.org/zap"
 
+	"github.com/dhaskew/rx/internal/catalog"
+	"github.com/dhaskew/rx/internal/comments"
 	"github.com/dhaskew/rx/internal/films"
+	"github.com/dhaskew/rx/internal/idempotency"
+	"github.com/dhaskew/rx/internal/migrate"
 	"github.com/dhaskew/rx/internal/server"
 )
 
@@ -18,29 +23,34 @@ func main() {
 	envfile := flag.String("envfile", server.DefaultEnvPath, "an environment config file path")
 	flag.Parse()
 
+	level := zap.NewAtomicLevelAt(zap.DebugLevel)
 	cfg := server.Config{}
-	cfg.Logger = server.NewLogger()
-	ops, err := cfg.Load(*envfile)
+	cfg.Logger = server.NewLeveledLogger(level)
+	_, err := cfg.Load(*envfile)
 	if err != nil {
 		panic(err)
 	}
 
-	db_host := ops["DB_HOST"]
-	db_port, err := strconv.Atoi(ops["DB_PORT"])
+	settings, err := cfg.Settings()
+	if err != nil {
+		panic(err)
+	}
+
+	authenticators, err := settings.Authenticators()
 	if err != nil {
 		panic(err)
 	}
-	db_user := ops["DB_USER"]
-	db_password := ops["DB_PASSWORD"]
-	db_name := ops["DB_NAME"]
 
 	// open database
-	psqlconn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", db_host, db_port, db_user, db_password, db_name)
-	db, err := sql.Open("postgres", psqlconn)
+	db, err := sql.Open("postgres", settings.DSN())
 	if err != nil {
 		panic(err)
 	}
-	defer db.Close()
+
+	// Start exits through os.Exit, so resources are closed by the lifecycle
+	// rather than deferred here.
+	lc := server.NewLifecycle(cfg.Logger)
+	lc.OnShutdown("database", db.Close)
 
 	// check db
 	err = db.Ping()
@@ -48,21 +58,53 @@ func main() {
 		panic(err)
 	}
 
-	rep := films.NewPostgresFilmRepository(db)
+	if settings.DBMigrate {
+		err = migrate.Up(context.Background(), db, cfg.Logger)
+		if err != nil {
+			panic(err)
+		}
+	}
 
-	http_port := ops["HTTP_PORT"]
+	if flag.Arg(0) == "import" {
+		status := runImport(db, flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr)
+		db.Close()
+		os.Exit(status)
+	}
+
+	rep := films.NewPostgresFilmRepository(db)
 
-	server.NewServer(
+	options := []func(*server.Server) *server.Server{
 		server.WithLogger(cfg.Logger),
+		server.WithLifecycle(lc),
+		server.WithConfig(&cfg),
+		server.WithLogLevel(level),
 
 		server.WithRouterFunc(chi.NewRouter),
 		server.WithFilmRepository(&rep),
-		server.WithPort(http_port)).Start()
+		server.WithCommentRepository(comments.NewPostgresCommentRepository(db)),
+		server.WithRelationRepository(films.NewPostgresRelationRepository(db)),
+		server.WithIdempotencyStore(idempotency.NewPostgresStore(db)),
+		server.WithImporter(catalog.NewPostgresImporter(db)),
+		server.WithAuthenticators(authenticators...),
+		server.WithPort(settings.HTTPPort),
+	}
+	if settings.FilmCacheSize > 0 {
+		cache := films.NewLRUCache(settings.FilmCacheSize)
+		options = append(options, server.WithFilmCache(films.NewCachedRepository(rep, cache, settings.FilmCacheTTLs())))
+	}
+	if settings.DBNotify {
+		listener := films.NewChangeListener(settings.DSN(), cfg.Logger)
+		listener.MinReconnect = settings.DBNotifyMinReconnect
+		listener.MaxReconnect = settings.DBNotifyMaxReconnect
+		options = append(options, server.WithCatalogListener(listener))
+	}
+
+	srv := server.NewServer(options...)
 
+	os.Exit(srv.Start())
 }
 
 //NOTE: probably should move eval slog / replace zap, now part of standard lib
 //NOTE: telementry and jaeger? tracing can be crazy useful
-//TODO: caching
 //TODO: code coverage
 //TODO: integration tests